@@ -0,0 +1,230 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxRunRequestBytes bounds POST /run, which carries the scenario, CSV and body files.
+const maxRunRequestBytes = 64 << 20
+
+// agent serves the share of a distributed test a coordinator assigns to it, one
+// test at a time.
+type agent struct {
+	token string
+	busy  sync.Mutex
+
+	mu sync.Mutex
+	// starts delivers the start time to the run with the given ID, between its
+	// "ready" and the coordinator's POST /start.
+	starts map[string]chan time.Time
+}
+
+// runAgent is "load-tester agent": it listens for coordinators until killed.
+func runAgent(args []string) error {
+	fs := flag.NewFlagSet("load-tester agent", flag.ExitOnError)
+	listen := fs.String("listen", ":9090", "Endereço em que o agente aguarda o coordenador")
+	token := fs.String("token", "", "Token exigido do coordenador (--agent-token); obrigatório salvo com --insecure")
+	insecure := fs.Bool("insecure", false, "Aceita coordenadores sem token: qualquer um que alcance o agente pode disparar carga contra qualquer URL")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *token == "" && !*insecure {
+		return fmt.Errorf("informe --token (ou --insecure para aceitar qualquer coordenador)")
+	}
+	if *token == "" {
+		fmt.Fprintln(os.Stderr, "Aviso: agente sem token; qualquer um que alcance este endereço pode iniciar testes")
+	}
+	fmt.Fprintf(os.Stderr, "Agente aguardando o coordenador em %s\n", *listen)
+	return http.ListenAndServe(*listen, newAgent(*token).handler())
+}
+
+func newAgent(token string) *agent {
+	return &agent{token: token, starts: map[string]chan time.Time{}}
+}
+
+func (a *agent) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /run", a.run)
+	mux.HandleFunc("POST /start", a.start)
+	return mux
+}
+
+func (a *agent) authorized(w http.ResponseWriter, r *http.Request) bool {
+	if a.token != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+a.token)) != 1 {
+		http.Error(w, "token inválido", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+func (a *agent) run(w http.ResponseWriter, r *http.Request) {
+	if !a.authorized(w, r) {
+		return
+	}
+	if !a.busy.TryLock() {
+		http.Error(w, "agente ocupado com outro teste", http.StatusConflict)
+		return
+	}
+	defer a.busy.Unlock()
+
+	var req runRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRunRequestBytes)).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("requisição inválida: %v", err), http.StatusBadRequest)
+		return
+	}
+	if req.Count <= 0 || req.Index < 0 || req.Index >= req.Count {
+		http.Error(w, fmt.Sprintf("parte %d de %d inválida", req.Index, req.Count), http.StatusBadRequest)
+		return
+	}
+	dir, err := os.MkdirTemp("", "load-tester-agent")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer os.RemoveAll(dir)
+	args, err := agentArgs(req, dir)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	fs := flag.NewFlagSet("load-tester", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	cfg, err := parseFlags(fs, args)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	cfg, ok := cfg.share(req.Index, req.Count)
+	cfg.progress = false
+
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+	send := func(m agentMessage) {
+		_ = enc.Encode(m)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	start := make(chan time.Time, 1)
+	a.mu.Lock()
+	a.starts[req.ID] = start
+	a.mu.Unlock()
+	defer func() {
+		a.mu.Lock()
+		delete(a.starts, req.ID)
+		a.mu.Unlock()
+	}()
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	send(agentMessage{Type: msgReady})
+
+	ctx := r.Context()
+	wait := time.NewTimer(agentReadyTimeout)
+	defer wait.Stop()
+	var startAt time.Time
+	select {
+	case startAt = <-start:
+	case <-wait.C:
+		fmt.Fprintln(os.Stderr, "Coordenador não enviou o início; teste cancelado")
+		return
+	case <-ctx.Done():
+		return
+	}
+	if !sleepUntil(ctx, startAt) {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Executando parte %d de %d\n", req.Index+1, req.Count)
+	stats := newTestStats(len(cfg.stages), cfg.journeys)
+	var elapsed time.Duration
+	if ok {
+		done := make(chan struct{})
+		streamed := make(chan struct{})
+		go func() {
+			defer close(streamed)
+			tick := time.NewTicker(dashboardInterval)
+			defer tick.Stop()
+			for {
+				select {
+				case <-tick.C:
+					snap := stats.snapshot()
+					send(agentMessage{Type: msgProgress, Progress: &progressWire{
+						Total: snap.total, Iterations: snap.iterations, Failed: snap.failed,
+						StatusCounts: snap.statusCounts, Window: snap.window,
+					}})
+				case <-done:
+					return
+				}
+			}
+		}()
+		elapsed = runLoadTest(ctx, cfg, stats, nil)
+		close(done)
+		<-streamed
+	}
+	if ctx.Err() != nil {
+		fmt.Fprintln(os.Stderr, "Coordenador desconectou; teste interrompido")
+		return
+	}
+	send(agentMessage{Type: msgResult, Elapsed: elapsed, Stats: stats.wire()})
+	fmt.Fprintf(os.Stderr, "Parte %d de %d concluída: %d requests em %s\n", req.Index+1, req.Count, stats.total, elapsed.Round(time.Millisecond))
+}
+
+// start is POST /start: it releases a run that answered "ready" at the time
+// the coordinator chose once every agent was ready.
+func (a *agent) start(w http.ResponseWriter, r *http.Request) {
+	if !a.authorized(w, r) {
+		return
+	}
+	var req startRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<10)).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("requisição inválida: %v", err), http.StatusBadRequest)
+		return
+	}
+	a.mu.Lock()
+	start, ok := a.starts[req.ID]
+	a.mu.Unlock()
+	if !ok {
+		http.Error(w, "nenhum teste aguardando início com esse id", http.StatusNotFound)
+		return
+	}
+	select {
+	case start <- req.StartAt:
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "teste já iniciado", http.StatusConflict)
+	}
+}
+
+// agentArgs checks the forwarded arguments and writes the files that came with
+// them into dir. Only "-name=value" arguments are accepted, and flags that would
+// make the agent read or write its own files are refused.
+func agentArgs(req runRequest, dir string) ([]string, error) {
+	args := make([]string, 0, len(req.Args)+len(req.Files))
+	for _, a := range req.Args {
+		name, _, ok := strings.Cut(strings.TrimPrefix(a, "-"), "=")
+		if !ok || !strings.HasPrefix(a, "-") || strings.HasPrefix(a, "--") || coordinatorFlags[name] || slices.Contains(fileFlags, name) {
+			return nil, fmt.Errorf("argumento não permitido: %q", a)
+		}
+		args = append(args, a)
+	}
+	for name, data := range req.Files {
+		if !slices.Contains(fileFlags, name) {
+			return nil, fmt.Errorf("arquivo não permitido: %q", name)
+		}
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			return nil, err
+		}
+		args = append(args, "-"+name+"="+path)
+	}
+	return args, nil
+}
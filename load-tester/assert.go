@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// checks are the assertions of a step; the zero value accepts any response.
+type checks struct {
+	status       []int
+	bodyContains string
+	json         []jsonCheck
+	// maxLatency fails responses slower than this; zero disables it.
+	maxLatency time.Duration
+}
+
+// jsonCheck requires the value at path, rendered like an extracted value, to equal want.
+type jsonCheck struct {
+	path *jsonPath
+	want string
+}
+
+func (c checks) needsBody() bool {
+	return c.bodyContains != "" || len(c.json) > 0
+}
+
+func (c checks) empty() bool {
+	return len(c.status) == 0 && !c.needsBody() && c.maxLatency == 0
+}
+
+// verify returns why a response does not pass, or nil. Latency is checked
+// separately by verifyLatency, once the exchange is over.
+func (c checks) verify(status int, body []byte) error {
+	if len(c.status) > 0 && !slices.Contains(c.status, status) {
+		return fmt.Errorf("status %d, esperado %v", status, c.status)
+	}
+	if c.bodyContains != "" && !bytes.Contains(body, []byte(c.bodyContains)) {
+		return fmt.Errorf("corpo não contém %q", c.bodyContains)
+	}
+	if len(c.json) == 0 {
+		return nil
+	}
+	doc, err := decodeJSON(body)
+	if err != nil {
+		return err
+	}
+	for _, jc := range c.json {
+		v, err := jc.path.lookup(doc)
+		if err != nil {
+			return err
+		}
+		if got := jsonString(v); got != jc.want {
+			return fmt.Errorf("%s = %q, esperado %q", jc.path.expr, got, jc.want)
+		}
+	}
+	return nil
+}
+
+func (c checks) verifyLatency(latency time.Duration) error {
+	if c.maxLatency > 0 && latency > c.maxLatency {
+		return fmt.Errorf("latência %s acima de %s", fmtLatency(latency), c.maxLatency)
+	}
+	return nil
+}
+
+// parseAssert adds an --assert expression to c:
+//
+//	status=200 or status=200|201   status code is one of the values
+//	body~texto                     body contains texto
+//	$.campo=valor                  JSON field equals valor
+//	latency<300ms                  response took less than 300ms
+func parseAssert(expr string, c *checks) error {
+	switch {
+	case strings.HasPrefix(expr, "status="):
+		for _, v := range strings.Split(strings.TrimPrefix(expr, "status="), "|") {
+			code, err := strconv.Atoi(strings.TrimSpace(v))
+			if err != nil {
+				return fmt.Errorf("--assert %q: status inválido", expr)
+			}
+			c.status = append(c.status, code)
+		}
+	case strings.HasPrefix(expr, "body~"):
+		c.bodyContains = strings.TrimPrefix(expr, "body~")
+	case strings.HasPrefix(expr, "$"):
+		path, want, ok := strings.Cut(expr, "=")
+		if !ok {
+			return fmt.Errorf("--assert %q deve ter o formato $.campo=valor", expr)
+		}
+		p, err := parseJSONPath(path)
+		if err != nil {
+			return err
+		}
+		c.json = append(c.json, jsonCheck{path: p, want: want})
+	case strings.HasPrefix(expr, "latency<"):
+		d, err := time.ParseDuration(strings.TrimPrefix(expr, "latency<"))
+		if err != nil || d <= 0 {
+			return fmt.Errorf("--assert %q: duração inválida", expr)
+		}
+		c.maxLatency = d
+	default:
+		return fmt.Errorf("--assert %q não reconhecido (use status=, body~, $.campo= ou latency<)", expr)
+	}
+	return nil
+}
+
+// repeatedFlag collects the values of a flag given several times.
+type repeatedFlag []string
+
+func (r *repeatedFlag) String() string { return strings.Join(*r, ", ") }
+
+func (r *repeatedFlag) Set(v string) error {
+	*r = append(*r, v)
+	return nil
+}
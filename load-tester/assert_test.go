@@ -0,0 +1,68 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseAssert(t *testing.T) {
+	var c checks
+	for _, expr := range []string{"status=200|201", "body~\"ok\"", "$.data.active=true", "$.data.items[0].cep=01001000", "latency<300ms"} {
+		if err := parseAssert(expr, &c); err != nil {
+			t.Fatalf("%q: unexpected err: %v", expr, err)
+		}
+	}
+	if !slices.Equal(c.status, []int{200, 201}) || c.bodyContains != `"ok"` || len(c.json) != 2 || c.maxLatency != 300*time.Millisecond {
+		t.Fatalf("unexpected checks %+v", c)
+	}
+	if c.json[0].want != "true" || c.json[1].path.expr != "$.data.items[0].cep" {
+		t.Fatalf("unexpected JSON checks %+v", c.json)
+	}
+	if c.empty() || !c.needsBody() {
+		t.Fatalf("checks with body assertions should need the body")
+	}
+}
+
+func TestParseAssert_Invalid(t *testing.T) {
+	for _, expr := range []string{"status=ok", "status=200|", "$.data", "$data=1", "latency<fast", "latency<0s", "header=x", ""} {
+		var c checks
+		if err := parseAssert(expr, &c); err == nil {
+			t.Fatalf("%q: expected error", expr)
+		}
+	}
+}
+
+func TestChecksVerify(t *testing.T) {
+	var c checks
+	for _, expr := range []string{"status=200", "body~tok", "$.data.token=tok-123"} {
+		if err := parseAssert(expr, &c); err != nil {
+			t.Fatalf("%q: %v", expr, err)
+		}
+	}
+	cases := []struct {
+		name   string
+		status int
+		body   string
+		ok     bool
+	}{
+		{"passes", 200, `{"data": {"token": "tok-123"}}`, true},
+		{"wrong status", 500, `{"data": {"token": "tok-123"}}`, false},
+		{"missing text", 200, `{"data": {"t": 1}}`, false},
+		{"wrong value", 200, `{"data": {"token": "tok-999"}}`, false},
+		{"not JSON", 200, `tok`, false},
+	}
+	for _, tc := range cases {
+		if err := c.verify(tc.status, []byte(tc.body)); (err == nil) != tc.ok {
+			t.Fatalf("%s: verify = %v, want ok=%v", tc.name, err, tc.ok)
+		}
+	}
+
+	c = checks{maxLatency: 100 * time.Millisecond}
+	if c.verifyLatency(100*time.Millisecond) != nil || c.verifyLatency(101*time.Millisecond) == nil {
+		t.Fatalf("latency check should accept up to and including the limit")
+	}
+	if !(checks{}).empty() {
+		t.Fatalf("zero checks should be empty")
+	}
+}
@@ -0,0 +1,564 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A distributed run has one coordinator (the usual command line plus --agents)
+// and any number of agents (load-tester agent). The coordinator posts its own
+// flags to every agent's /run together with the agent's index and a run ID; each
+// agent answers with a stream of NDJSON messages: "ready" once the test is
+// parsed. When every agent is ready, however long the upload took, the
+// coordinator posts a common start time to each agent's /start, and the agents
+// generate their share of the load and go on with "progress" every second with
+// the latencies of the last interval, and a final "result" with the full stats,
+// which the coordinator merges into a single report.
+//
+// Agents start by their own clock, so hosts should be kept in sync (NTP).
+
+const (
+	// agentReadyTimeout bounds the upload of the test to the agents, which may
+	// carry up to maxRunRequestBytes of files, and the wait for /start after it.
+	agentReadyTimeout = 5 * time.Minute
+	// agentStartDelay is how far ahead of the last "ready" the coordinator
+	// schedules the start, which gives every agent time to receive /start.
+	agentStartDelay = 2 * time.Second
+)
+
+const (
+	msgReady    = "ready"
+	msgProgress = "progress"
+	msgResult   = "result"
+)
+
+// coordinatorFlags only apply on the coordinator and are not forwarded.
+var coordinatorFlags = map[string]bool{
+	"agents": true, "agent-token": true, "output": true, "raw-log": true, "progress": true, "threshold": true,
+}
+
+// fileFlags name files the agents may not have; their contents travel in
+// runRequest.Files instead of the path.
+var fileFlags = []string{"scenario", "csv", "body-file"}
+
+// runRequest is the body of POST /run.
+type runRequest struct {
+	// Args are the coordinator's flags, one "-name=value" per element.
+	Args  []string          `json:"args"`
+	Files map[string][]byte `json:"files"`
+	// Index and Count select the share of the load this agent generates.
+	Index int `json:"index"`
+	Count int `json:"count"`
+	// ID names the run in the later POST /start.
+	ID string `json:"id"`
+}
+
+// startRequest is the body of POST /start.
+type startRequest struct {
+	ID      string    `json:"id"`
+	StartAt time.Time `json:"start_at"`
+}
+
+// agentMessage is one line of the stream an agent sends back.
+type agentMessage struct {
+	Type     string        `json:"type"`
+	Progress *progressWire `json:"progress,omitempty"`
+	Elapsed  time.Duration `json:"elapsed_ns,omitempty"`
+	Stats    *statsWire    `json:"stats,omitempty"`
+}
+
+type progressWire struct {
+	Total        int         `json:"total"`
+	Iterations   int         `json:"iterations"`
+	Failed       int         `json:"failed"`
+	StatusCounts map[int]int `json:"status_counts"`
+	Window       *histogram  `json:"window"`
+}
+
+// statsWire mirrors testStats with exported fields.
+type statsWire struct {
+	Total         int                  `json:"total"`
+	Success200    int                  `json:"success_200"`
+	StatusCounts  map[int]int          `json:"status_counts"`
+	Latency       *histogram           `json:"latency"`
+	ByStatus      map[int]*histogram   `json:"by_status"`
+	Late          int                  `json:"late"`
+	MaxSendDelay  time.Duration        `json:"max_send_delay_ns"`
+	Stages        []stageWire          `json:"stages"`
+	Errors        map[string]errorWire `json:"errors"`
+	CheckFailures int                  `json:"check_failures"`
+	Failed        int                  `json:"failed"`
+	Steps         [][]stepWire         `json:"steps"`
+	Journeys      []journeyWire        `json:"journeys"`
+}
+
+type stageWire struct {
+	Total   int        `json:"total"`
+	Success int        `json:"success"`
+	Errors  int        `json:"errors"`
+	Latency *histogram `json:"latency"`
+}
+
+type errorWire struct {
+	Count   int      `json:"count"`
+	Samples []string `json:"samples"`
+}
+
+type stepWire struct {
+	Total   int        `json:"total"`
+	Passed  int        `json:"passed"`
+	Failed  int        `json:"failed"`
+	Errors  int        `json:"errors"`
+	Latency *histogram `json:"latency"`
+	Samples []string   `json:"samples"`
+}
+
+type journeyWire struct {
+	Started   int `json:"started"`
+	Completed int `json:"completed"`
+}
+
+// wire exports the stats of a finished run.
+func (s *testStats) wire() *statsWire {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w := &statsWire{
+		Total:         s.total,
+		Success200:    s.success200,
+		StatusCounts:  s.statusCounts,
+		Latency:       s.latency,
+		ByStatus:      s.byStatus,
+		Late:          s.late,
+		MaxSendDelay:  s.maxSendDelay,
+		Errors:        make(map[string]errorWire, len(s.errors)),
+		CheckFailures: s.checkFailures,
+		Failed:        s.failed,
+	}
+	for _, st := range s.stages {
+		w.Stages = append(w.Stages, stageWire{Total: st.total, Success: st.success, Errors: st.errors, Latency: st.latency})
+	}
+	for kind, e := range s.errors {
+		w.Errors[kind] = errorWire{Count: e.count, Samples: e.samples}
+	}
+	for _, steps := range s.steps {
+		var ws []stepWire
+		for _, st := range steps {
+			ws = append(ws, stepWire{Total: st.total, Passed: st.passed, Failed: st.failed, Errors: st.errors, Latency: st.latency, Samples: st.samples})
+		}
+		w.Steps = append(w.Steps, ws)
+	}
+	for _, j := range s.journeys {
+		w.Journeys = append(w.Journeys, journeyWire{Started: j.started, Completed: j.completed})
+	}
+	return w
+}
+
+// merge adds the results of one agent. Its stages and steps must match the
+// coordinator's, which they do unless the agent read a different test.
+func (s *testStats) merge(w *statsWire) error {
+	if len(w.Stages) != len(s.stages) || len(w.Journeys) != len(s.journeys) || len(w.Steps) != len(s.steps) {
+		return fmt.Errorf("resultado com estágios ou jornadas diferentes dos do coordenador")
+	}
+	for i, steps := range w.Steps {
+		if len(steps) != len(s.steps[i]) {
+			return fmt.Errorf("resultado com passos diferentes dos do coordenador")
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.total += w.Total
+	s.success200 += w.Success200
+	for code, n := range w.StatusCounts {
+		s.statusCounts[code] += n
+	}
+	mergeHistogram(s.latency, w.Latency)
+	for code, h := range w.ByStatus {
+		if s.byStatus[code] == nil {
+			s.byStatus[code] = newHistogram()
+		}
+		mergeHistogram(s.byStatus[code], h)
+	}
+	s.late += w.Late
+	s.maxSendDelay = max(s.maxSendDelay, w.MaxSendDelay)
+	for i, st := range w.Stages {
+		s.stages[i].total += st.Total
+		s.stages[i].success += st.Success
+		s.stages[i].errors += st.Errors
+		mergeHistogram(s.stages[i].latency, st.Latency)
+	}
+	for kind, e := range w.Errors {
+		if s.errors[kind] == nil {
+			s.errors[kind] = &errorStats{}
+		}
+		s.errors[kind].count += e.Count
+		s.errors[kind].samples = mergeSamples(s.errors[kind].samples, e.Samples)
+	}
+	s.checkFailures += w.CheckFailures
+	s.failed += w.Failed
+	for i, steps := range w.Steps {
+		for k, st := range steps {
+			dst := s.steps[i][k]
+			dst.total += st.Total
+			dst.passed += st.Passed
+			dst.failed += st.Failed
+			dst.errors += st.Errors
+			mergeHistogram(dst.latency, st.Latency)
+			dst.samples = mergeSamples(dst.samples, st.Samples)
+		}
+	}
+	for i, j := range w.Journeys {
+		s.journeys[i].started += j.Started
+		s.journeys[i].completed += j.Completed
+	}
+	return nil
+}
+
+func mergeHistogram(dst, src *histogram) {
+	if src != nil {
+		dst.merge(src)
+	}
+}
+
+// mergeSamples adds the distinct messages of src while there is room.
+func mergeSamples(dst, src []string) []string {
+	for _, msg := range src {
+		if len(dst) < maxErrorSamples && !slices.Contains(dst, msg) {
+			dst = append(dst, msg)
+		}
+	}
+	return dst
+}
+
+// share narrows cfg to the part of the load agent i of n generates: request
+// counts and closed-model workers are divided with the remainder going to the
+// first agents, rates and stage targets evenly. ok is false when nothing is left
+// for this agent.
+func (cfg testConfig) share(i, n int) (_ testConfig, ok bool) {
+	if cfg.rate == 0 && len(cfg.stages) == 0 && cfg.concurrency < n {
+		// An agent without workers could not send its requests, so only the
+		// first --concurrency agents take part.
+		if n = cfg.concurrency; i >= n {
+			return cfg, false
+		}
+	}
+	split := func(total int) int {
+		q := total / n
+		if i < total%n {
+			q++
+		}
+		return q
+	}
+	if cfg.totalRequests > 0 {
+		if cfg.totalRequests = split(cfg.totalRequests); cfg.totalRequests == 0 {
+			return cfg, false
+		}
+	}
+	cfg.rate /= float64(n)
+	stages := make([]stage, len(cfg.stages))
+	for k, st := range cfg.stages {
+		st.target /= float64(n)
+		stages[k] = st
+	}
+	cfg.stages = stages
+	switch {
+	case cfg.rate > 0 || (len(cfg.stages) > 0 && cfg.model == modelOpen):
+		// Here concurrency only caps in-flight requests.
+		cfg.concurrency = int(math.Ceil(float64(cfg.concurrency) / float64(n)))
+	case len(cfg.stages) == 0:
+		if cfg.concurrency = split(cfg.concurrency); cfg.concurrency == 0 {
+			return cfg, false
+		}
+	}
+	cfg.seqOffset, cfg.seqStride = i, n
+	return cfg, true
+}
+
+// forwardedArgs turns the flags given to the coordinator into the arguments of
+// runRequest, reading the files they name.
+func forwardedArgs(fs *flag.FlagSet) (args []string, files map[string][]byte, err error) {
+	files = make(map[string][]byte)
+	fs.Visit(func(f *flag.Flag) {
+		if err != nil || coordinatorFlags[f.Name] {
+			return
+		}
+		if slices.Contains(fileFlags, f.Name) {
+			data, rerr := os.ReadFile(f.Value.String())
+			if rerr != nil {
+				err = fmt.Errorf("não foi possível ler --%s: %v", f.Name, rerr)
+				return
+			}
+			files[f.Name] = data
+			return
+		}
+		switch v := f.Value.(type) {
+		case *headerFlags:
+			for _, h := range *v {
+				args = append(args, "-"+f.Name+"="+h)
+			}
+		case *repeatedFlag:
+			for _, a := range *v {
+				args = append(args, "-"+f.Name+"="+a)
+			}
+		default:
+			args = append(args, "-"+f.Name+"="+f.Value.String())
+		}
+	})
+	return args, files, err
+}
+
+// remoteStats merges the progress the agents stream so the coordinator can
+// show the usual live view.
+type remoteStats struct {
+	mu     sync.Mutex
+	latest []progressWire
+	window *histogram
+}
+
+func newRemoteStats(agents int) *remoteStats {
+	return &remoteStats{latest: make([]progressWire, agents), window: newHistogram()}
+}
+
+func (r *remoteStats) update(agent int, p *progressWire) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if p.Window != nil {
+		r.window.merge(p.Window)
+	}
+	p.Window = nil
+	r.latest[agent] = *p
+}
+
+func (r *remoteStats) snapshot() progressSnapshot {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	snap := progressSnapshot{statusCounts: make(map[int]int), window: r.window}
+	for _, p := range r.latest {
+		snap.total += p.Total
+		snap.iterations += p.Iterations
+		snap.failed += p.Failed
+		for code, n := range p.StatusCounts {
+			snap.statusCounts[code] += n
+		}
+	}
+	r.window = newHistogram()
+	return snap
+}
+
+// agentResult is what one agent returned, or why it failed.
+type agentResult struct {
+	elapsed time.Duration
+	stats   *statsWire
+	err     error
+}
+
+// runDistributed splits the test between cfg.agents, starts them together and
+// merges their results. Every agent must take part: if one fails, the others
+// are stopped and the run is reported as an error.
+func runDistributed(ctx context.Context, cfg testConfig, fs *flag.FlagSet) (time.Duration, *testStats, error) {
+	args, files, err := forwardedArgs(fs)
+	if err != nil {
+		return 0, nil, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return 0, nil, err
+	}
+	live := newRemoteStats(len(cfg.agents))
+	ready := make(chan error, len(cfg.agents))
+	results := make([]agentResult, len(cfg.agents))
+	var wg sync.WaitGroup
+	for i, addr := range cfg.agents {
+		req := runRequest{Args: args, Files: files, Index: i, Count: len(cfg.agents), ID: hex.EncodeToString(id)}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = runOnAgent(ctx, cfg, addr, i, req, live, ready)
+			if results[i].err != nil {
+				cancel()
+			}
+		}()
+	}
+
+	deadline := time.NewTimer(agentReadyTimeout)
+	defer deadline.Stop()
+	for range cfg.agents {
+		select {
+		case err = <-ready:
+		case <-deadline.C:
+			err = fmt.Errorf("nem todos os agentes ficaram prontos em %s", agentReadyTimeout)
+		}
+		if err != nil {
+			cancel()
+			wg.Wait()
+			return 0, nil, firstAgentError(cfg, results, err)
+		}
+	}
+	// The start is only scheduled now, so a slow upload cannot eat into it.
+	startAt := time.Now().Add(agentStartDelay)
+	if err := startAgents(ctx, cfg, startRequest{ID: hex.EncodeToString(id), StartAt: startAt}); err != nil {
+		cancel()
+		wg.Wait()
+		return 0, nil, firstAgentError(cfg, results, err)
+	}
+	fmt.Fprintf(os.Stderr, "%d agentes prontos; início em %s\n", len(cfg.agents), startAt.Format("15:04:05.000"))
+
+	if sleepUntil(ctx, startAt) && cfg.progress {
+		stop := startProgress(cfg, live, startAt)
+		defer stop()
+	}
+	wg.Wait()
+	if err := firstAgentError(cfg, results, nil); err != nil {
+		return 0, nil, err
+	}
+
+	stats := newTestStats(len(cfg.stages), cfg.journeys)
+	var elapsed time.Duration
+	for i, r := range results {
+		if err := stats.merge(r.stats); err != nil {
+			return 0, nil, fmt.Errorf("agente %s: %v", cfg.agents[i], err)
+		}
+		elapsed = max(elapsed, r.elapsed)
+	}
+	return elapsed, stats, nil
+}
+
+// firstAgentError reports the agent that failed on its own rather than one
+// that was only stopped because of it.
+func firstAgentError(cfg testConfig, results []agentResult, fallback error) error {
+	for i, r := range results {
+		if r.err != nil && !errors.Is(r.err, context.Canceled) {
+			return fmt.Errorf("agente %s: %v", cfg.agents[i], r.err)
+		}
+	}
+	return fallback
+}
+
+// runOnAgent posts req to the agent at addr and follows its stream, signalling
+// on ready once the agent has accepted the test (or failed before that).
+func runOnAgent(ctx context.Context, cfg testConfig, addr string, idx int, req runRequest, live *remoteStats, ready chan<- error) agentResult {
+	accepted := false
+	fail := func(err error) agentResult {
+		if !accepted {
+			ready <- fmt.Errorf("agente %s: %v", addr, err)
+		}
+		return agentResult{err: err}
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return fail(err)
+	}
+	hreq, err := http.NewRequestWithContext(ctx, http.MethodPost, agentURL(addr, "/run"), bytes.NewReader(body))
+	if err != nil {
+		return fail(err)
+	}
+	hreq.Header.Set("Content-Type", "application/json")
+	if cfg.agentToken != "" {
+		hreq.Header.Set("Authorization", "Bearer "+cfg.agentToken)
+	}
+	// No client timeout: the response lasts as long as the test.
+	resp, err := http.DefaultClient.Do(hreq)
+	if err != nil {
+		return fail(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fail(fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg))))
+	}
+
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var m agentMessage
+		if err := dec.Decode(&m); err != nil {
+			if errors.Is(err, io.EOF) {
+				err = fmt.Errorf("conexão encerrada antes do resultado")
+			}
+			return fail(err)
+		}
+		switch m.Type {
+		case msgReady:
+			accepted = true
+			ready <- nil
+		case msgProgress:
+			if m.Progress != nil {
+				live.update(idx, m.Progress)
+			}
+		case msgResult:
+			if m.Stats == nil {
+				return fail(fmt.Errorf("resultado sem estatísticas"))
+			}
+			return agentResult{elapsed: m.Elapsed, stats: m.Stats}
+		}
+	}
+}
+
+// startAgents posts the start time to every agent, failing if any of them
+// does not accept it before the start.
+func startAgents(ctx context.Context, cfg testConfig, req startRequest) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithDeadline(ctx, req.StartAt)
+	defer cancel()
+	errs := make([]error, len(cfg.agents))
+	var wg sync.WaitGroup
+	for i, addr := range cfg.agents {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := postStart(ctx, cfg, addr, body); err != nil {
+				errs[i] = fmt.Errorf("agente %s: início: %v", addr, err)
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+func postStart(ctx context.Context, cfg testConfig, addr string, body []byte) error {
+	hreq, err := http.NewRequestWithContext(ctx, http.MethodPost, agentURL(addr, "/start"), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	hreq.Header.Set("Content-Type", "application/json")
+	if cfg.agentToken != "" {
+		hreq.Header.Set("Authorization", "Bearer "+cfg.agentToken)
+	}
+	resp, err := http.DefaultClient.Do(hreq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// agentURL joins path to addr, which is host:port or a full base URL.
+func agentURL(addr, path string) string {
+	if !strings.Contains(addr, "://") {
+		addr = "http://" + addr
+	}
+	return strings.TrimSuffix(addr, "/") + path
+}
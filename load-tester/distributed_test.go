@@ -0,0 +1,335 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShareSplitsRemainder(t *testing.T) {
+	cases := []struct {
+		name        string
+		cfg         testConfig
+		agents      int
+		requests    []int
+		concurrency []int
+		skipped     []bool
+		stride      int
+	}{
+		{
+			name:        "closed",
+			cfg:         testConfig{totalRequests: 1001, concurrency: 5},
+			agents:      3,
+			requests:    []int{334, 334, 333},
+			concurrency: []int{2, 2, 1},
+			skipped:     []bool{false, false, false},
+			stride:      3,
+		},
+		{
+			name:        "fewer requests than agents",
+			cfg:         testConfig{totalRequests: 2, concurrency: 2},
+			agents:      3,
+			requests:    []int{1, 1, 0},
+			concurrency: []int{1, 1, 0},
+			skipped:     []bool{false, false, true},
+			stride:      2,
+		},
+		{
+			name:        "fewer workers than agents",
+			cfg:         testConfig{duration: time.Minute, concurrency: 1},
+			agents:      2,
+			requests:    []int{0, 0},
+			concurrency: []int{1, 0},
+			skipped:     []bool{false, true},
+			stride:      1,
+		},
+		{
+			// The requests of an agent without workers go to the others.
+			name:        "fewer workers than agents with requests",
+			cfg:         testConfig{totalRequests: 21, concurrency: 2},
+			agents:      3,
+			requests:    []int{11, 10, 0},
+			concurrency: []int{1, 1, 0},
+			skipped:     []bool{false, false, true},
+			stride:      2,
+		},
+		{
+			// In the open model concurrency is only a cap, rounded up.
+			name:        "open",
+			cfg:         testConfig{duration: time.Minute, rate: 100, concurrency: 5},
+			agents:      2,
+			requests:    []int{0, 0},
+			concurrency: []int{3, 3},
+			skipped:     []bool{false, false},
+			stride:      2,
+		},
+	}
+	for _, tc := range cases {
+		total := 0
+		for i := 0; i < tc.agents; i++ {
+			got, ok := tc.cfg.share(i, tc.agents)
+			if ok == tc.skipped[i] {
+				t.Fatalf("%s: agent %d ok = %v", tc.name, i, ok)
+			}
+			if !ok {
+				continue
+			}
+			if got.totalRequests != tc.requests[i] || got.concurrency != tc.concurrency[i] {
+				t.Fatalf("%s: agent %d got %d requests, %d workers; want %d, %d", tc.name, i, got.totalRequests, got.concurrency, tc.requests[i], tc.concurrency[i])
+			}
+			if got.seqOffset != i || got.seqStride != tc.stride {
+				t.Fatalf("%s: agent %d seq %d/%d", tc.name, i, got.seqOffset, got.seqStride)
+			}
+			total += got.totalRequests
+		}
+		if total != tc.cfg.totalRequests {
+			t.Fatalf("%s: agents send %d requests, want %d", tc.name, total, tc.cfg.totalRequests)
+		}
+	}
+}
+
+func TestShareDividesRatesAndStages(t *testing.T) {
+	cfg := testConfig{
+		rate:        90,
+		stages:      []stage{{duration: time.Second, target: 30}, {duration: time.Second, target: 0}},
+		model:       modelOpen,
+		concurrency: 256,
+		seqStride:   1,
+	}
+	got, ok := cfg.share(1, 3)
+	if !ok || got.rate != 30 || got.stages[0].target != 10 || got.stages[1].target != 0 {
+		t.Fatalf("unexpected share %+v", got)
+	}
+	if cfg.stages[0].target != 30 {
+		t.Fatalf("share must not modify the coordinator's stages")
+	}
+}
+
+func TestGlobalSeqInterleavesAgents(t *testing.T) {
+	var seen []int64
+	for i := 0; i < 3; i++ {
+		cfg, _ := testConfig{totalRequests: 9, concurrency: 3}.share(i, 3)
+		for n := int64(1); n <= 3; n++ {
+			seen = append(seen, cfg.globalSeq(n))
+		}
+	}
+	slices.Sort(seen)
+	if !slices.Equal(seen, []int64{1, 2, 3, 4, 5, 6, 7, 8, 9}) {
+		t.Fatalf("agents should cover every sequence number once, got %v", seen)
+	}
+	if (testConfig{seqStride: 1}).globalSeq(7) != 7 {
+		t.Fatalf("a single process keeps its own numbering")
+	}
+}
+
+func TestMergeAddsAgentStats(t *testing.T) {
+	journeys := []journey{{name: "a", weight: 1, steps: []step{{name: "s1"}, {name: "s2"}}}}
+	agents := []*testStats{newTestStats(1, journeys), newTestStats(1, journeys)}
+	want := newTestStats(1, journeys)
+	record := func(agent int, r result) {
+		agents[agent].record(r)
+		want.record(r)
+	}
+	for i := 0; i < 20; i++ {
+		record(i%2, result{stage: 0, status: 200, latency: time.Duration(i+1) * time.Millisecond})
+	}
+	record(0, result{stage: 0, step: 1, status: 0, latency: time.Second, err: &requestError{kind: errReset, err: errors.New("reset a")}})
+	record(1, result{stage: 0, step: 1, status: 0, latency: time.Second, err: &requestError{kind: errReset, err: errors.New("reset b")}})
+	record(1, result{stage: 0, status: 503, latency: 5 * time.Millisecond, check: errors.New("status 503")})
+	for _, s := range agents {
+		s.recordJourney(0, true)
+		s.recordSendDelay(20 * time.Millisecond)
+	}
+
+	merged := newTestStats(1, journeys)
+	for _, s := range agents {
+		// Go through JSON like the coordinator does.
+		data, err := json.Marshal(s.wire())
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+		var w statsWire
+		if err := json.Unmarshal(data, &w); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		if err := merged.merge(&w); err != nil {
+			t.Fatalf("merge: %v", err)
+		}
+	}
+
+	if merged.total != want.total || merged.success200 != want.success200 || merged.failed != want.failed ||
+		merged.checkFailures != want.checkFailures || merged.statusCounts[503] != 1 || merged.statusCounts[0] != 2 {
+		t.Fatalf("merged counts %+v, want %+v", merged, want)
+	}
+	if merged.latency.percentile(99) != want.latency.percentile(99) || merged.byStatus[200].total != 20 {
+		t.Fatalf("merged latencies differ")
+	}
+	if merged.stages[0].total != 23 || merged.stages[0].errors != 2 || merged.steps[0][1].errors != 2 || merged.steps[0][0].failed != 1 {
+		t.Fatalf("merged breakdowns: stage %+v, steps %+v %+v", merged.stages[0], merged.steps[0][0], merged.steps[0][1])
+	}
+	if e := merged.errors[errReset]; e.count != 2 || !slices.Equal(e.samples, []string{"reset a", "reset b"}) {
+		t.Fatalf("merged errors %+v", e)
+	}
+	if merged.journeys[0].started != 2 || merged.late != 2 || merged.maxSendDelay != 20*time.Millisecond {
+		t.Fatalf("merged journeys %+v, late %d, delay %s", merged.journeys[0], merged.late, merged.maxSendDelay)
+	}
+
+	other := newTestStats(2, journeys)
+	if err := other.merge(agents[0].wire()); err == nil {
+		t.Fatalf("stats from a different test should not merge")
+	}
+}
+
+func TestHistogramJSONRoundTrip(t *testing.T) {
+	h := newHistogram()
+	for _, d := range []time.Duration{0, 50 * time.Microsecond, 3 * time.Millisecond, 3 * time.Millisecond, 2 * time.Second} {
+		h.record(d)
+	}
+	data, err := json.Marshal(h)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var got histogram
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if got.total != h.total || got.sum != h.sum || got.min != h.min || got.max != h.max {
+		t.Fatalf("round trip %+v, want %+v", got, h)
+	}
+	for _, q := range []float64{10, 50, 90, 100} {
+		if got.percentile(q) != h.percentile(q) {
+			t.Fatalf("p%v = %s, want %s", q, got.percentile(q), h.percentile(q))
+		}
+	}
+
+	for name, raw := range map[string]string{
+		"bucket out of range": `{"buckets": [[99999, 1]], "total": 1}`,
+		"total mismatch":      `{"buckets": [[3, 1]], "total": 2}`,
+	} {
+		if err := json.Unmarshal([]byte(raw), &got); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+}
+
+func TestForwardedArgs(t *testing.T) {
+	dir := t.TempDir()
+	body := filepath.Join(dir, "body.json")
+	if err := os.WriteFile(body, []byte(`{"seq": {{.Seq}}}`), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	fs := flag.NewFlagSet("load-tester", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	if _, err := parseFlags(fs, []string{
+		"--url", "http://localhost:8080/", "--method", "POST", "--header", "A: 1", "--header", "B: 2",
+		"--body-file", body, "--requests", "10", "--threshold", "p95<1s", "--output", "json",
+		"--agents", "a:9090,b:9090", "--agent-token", "secret",
+	}); err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	args, files, err := forwardedArgs(fs)
+	if err != nil {
+		t.Fatalf("forwardedArgs: %v", err)
+	}
+	want := []string{"-header=A: 1", "-header=B: 2", "-method=POST", "-requests=10", "-url=http://localhost:8080/"}
+	if !slices.Equal(args, want) {
+		t.Fatalf("args %q, want %q", args, want)
+	}
+	if string(files["body-file"]) != `{"seq": {{.Seq}}}` || len(files) != 1 {
+		t.Fatalf("files %q", files)
+	}
+
+	// The agent takes the arguments back, with the files in its own directory.
+	agentDir := t.TempDir()
+	got, err := agentArgs(runRequest{Args: args, Files: files, Count: 1}, agentDir)
+	if err != nil {
+		t.Fatalf("agentArgs: %v", err)
+	}
+	if got[len(got)-1] != "-body-file="+filepath.Join(agentDir, "body-file") {
+		t.Fatalf("body file not rewritten: %q", got)
+	}
+}
+
+func TestAgentArgsRejectsLocalFiles(t *testing.T) {
+	cases := map[string]runRequest{
+		"file flag":        {Args: []string{"-body-file=/etc/passwd"}},
+		"raw log":          {Args: []string{"-raw-log=/tmp/x"}},
+		"nested agents":    {Args: []string{"-agents=a:1"}},
+		"double dash":      {Args: []string{"--body-file=/etc/passwd"}},
+		"separate value":   {Args: []string{"-csv", "/etc/passwd"}},
+		"unknown file key": {Files: map[string][]byte{"../x": []byte("x")}},
+	}
+	for name, req := range cases {
+		if _, err := agentArgs(req, t.TempDir()); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+}
+
+func TestRunDistributedStartsAgentsTogether(t *testing.T) {
+	target, hits := countingServer(t, 0)
+	var agents []string
+	for range 2 {
+		srv := httptest.NewServer(newAgent("secret").handler())
+		t.Cleanup(srv.Close)
+		agents = append(agents, srv.URL)
+	}
+	fs := flag.NewFlagSet("load-tester", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	cfg, err := parseFlags(fs, []string{
+		"--url", target.URL, "--requests", "21", "--concurrency", "4", "--progress=false",
+		"--agents", strings.Join(agents, ","), "--agent-token", "secret",
+	})
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	start := time.Now()
+	_, stats, err := runDistributed(context.Background(), cfg, fs)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if stats.total != 21 || stats.success200 != 21 || hits.Load() != 21 {
+		t.Fatalf("stats %d total %d ok, server %d; want 21", stats.total, stats.success200, hits.Load())
+	}
+	// The start is scheduled once every agent is ready, agentStartDelay later.
+	if elapsed := time.Since(start); elapsed < agentStartDelay {
+		t.Fatalf("run finished after %s, before the scheduled start", elapsed)
+	}
+}
+
+func TestAgentStartRequiresAWaitingRun(t *testing.T) {
+	srv := httptest.NewServer(newAgent("secret").handler())
+	defer srv.Close()
+	cases := []struct {
+		token string
+		want  int
+	}{
+		{"", http.StatusUnauthorized},
+		{"secret", http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+"/start", strings.NewReader(`{"id": "nope", "start_at": "2030-01-01T00:00:00Z"}`))
+		if tc.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("post: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.want {
+			t.Fatalf("token %q: status %d, want %d", tc.token, resp.StatusCode, tc.want)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// Error categories, as they appear in machine-readable output.
+const (
+	errTimeout  = "timeout"
+	errRefused  = "connection_refused"
+	errReset    = "connection_reset"
+	errDNS      = "dns"
+	errTLS      = "tls"
+	errBodyRead = "body_read"
+	errRequest  = "request"
+	errOther    = "other"
+)
+
+// errorLabels are the category names shown in the text report.
+var errorLabels = map[string]string{
+	errTimeout:  "timeout do cliente",
+	errRefused:  "conexão recusada",
+	errReset:    "conexão encerrada pelo servidor",
+	errDNS:      "DNS",
+	errTLS:      "TLS",
+	errBodyRead: "leitura do corpo",
+	errRequest:  "montagem da request",
+	errOther:    "outros",
+}
+
+// maxErrorSamples is how many distinct messages are kept per category.
+const maxErrorSamples = 3
+
+// requestError is a failed exchange and the category it was classified into.
+type requestError struct {
+	kind string
+	err  error
+}
+
+func (e *requestError) Error() string { return e.err.Error() }
+
+func (e *requestError) Unwrap() error { return e.err }
+
+// classify names the cause of err. reading is true when the response status was
+// already received and the body could not be read.
+func classify(err error, reading bool) *requestError {
+	return &requestError{kind: errorKind(err, reading), err: err}
+}
+
+func errorKind(err error, reading bool) string {
+	var (
+		dnsErr *net.DNSError
+		netErr net.Error
+	)
+	switch {
+	case errors.As(err, &dnsErr):
+		return errDNS
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, os.ErrDeadlineExceeded),
+		errors.As(err, &netErr) && netErr.Timeout():
+		// The deadline is always the client's; a slow server shows up as a timeout.
+		return errTimeout
+	case reading:
+		return errBodyRead
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return errRefused
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE),
+		errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return errReset
+	case isTLS(err):
+		return errTLS
+	default:
+		return errOther
+	}
+}
+
+func isTLS(err error) bool {
+	var (
+		header    tls.RecordHeaderError
+		verify    *tls.CertificateVerificationError
+		alert     tls.AlertError
+		authority x509.UnknownAuthorityError
+		hostname  x509.HostnameError
+		invalid   x509.CertificateInvalidError
+	)
+	if errors.As(err, &header) || errors.As(err, &verify) || errors.As(err, &alert) ||
+		errors.As(err, &authority) || errors.As(err, &hostname) || errors.As(err, &invalid) {
+		return true
+	}
+	// net/http reports a plain-HTTP server behind an https:// URL and most
+	// handshake failures only as text.
+	msg := err.Error()
+	return strings.Contains(msg, "tls: ") || strings.Contains(msg, "HTTP response to HTTPS client")
+}
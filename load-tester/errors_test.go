@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// wrap nests err the way net/http reports a failed request.
+func wrap(err error) error {
+	return &url.Error{Op: "Get", URL: "http://example.test/", Err: &net.OpError{Op: "dial", Net: "tcp", Err: err}}
+}
+
+func TestErrorKind(t *testing.T) {
+	cases := []struct {
+		name    string
+		err     error
+		reading bool
+		want    string
+	}{
+		{"dns", &url.Error{Op: "Get", URL: "http://nx.test/", Err: &net.DNSError{Err: "no such host", Name: "nx.test", IsNotFound: true}}, false, errDNS},
+		{"client deadline", &url.Error{Op: "Get", URL: "http://example.test/", Err: context.DeadlineExceeded}, false, errTimeout},
+		{"socket deadline", wrap(os.ErrDeadlineExceeded), false, errTimeout},
+		{"timeout while reading", os.ErrDeadlineExceeded, true, errTimeout},
+		{"body cut short", io.ErrUnexpectedEOF, true, errBodyRead},
+		{"refused", wrap(os.NewSyscallError("connect", syscall.ECONNREFUSED)), false, errRefused},
+		{"reset", wrap(os.NewSyscallError("read", syscall.ECONNRESET)), false, errReset},
+		{"broken pipe", wrap(syscall.EPIPE), false, errReset},
+		{"closed before response", &url.Error{Op: "Get", URL: "http://example.test/", Err: io.EOF}, false, errReset},
+		{"tls record", wrap(tls.RecordHeaderError{Msg: "first record does not look like a TLS handshake"}), false, errTLS},
+		{"unknown authority", &url.Error{Op: "Get", URL: "https://example.test/", Err: x509.UnknownAuthorityError{}}, false, errTLS},
+		{"plain http server", errors.New("http: server gave HTTP response to HTTPS client"), false, errTLS},
+		{"other", fmt.Errorf("boom"), false, errOther},
+	}
+	for _, tc := range cases {
+		if got := errorKind(tc.err, tc.reading); got != tc.want {
+			t.Fatalf("%s: errorKind = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestClassifyRealFailures(t *testing.T) {
+	// A port that was just released refuses connections.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	_, err = http.Get("http://" + addr + "/")
+	if err == nil {
+		t.Fatalf("expected connection error")
+	}
+	if e := classify(err, false); e.kind != errRefused || e.Error() != err.Error() {
+		t.Fatalf("closed port classified as %q: %v", e.kind, e)
+	}
+
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer slow.Close()
+	client := &http.Client{Timeout: 20 * time.Millisecond}
+	_, err = client.Get(slow.URL)
+	if err == nil {
+		t.Fatalf("expected timeout")
+	}
+	if kind := classify(err, false).kind; kind != errTimeout {
+		t.Fatalf("client timeout classified as %q: %v", kind, err)
+	}
+}
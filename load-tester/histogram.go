@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/bits"
+	"time"
+)
+
+// subBucketBits sets the histogram precision: every power of two is split into
+// 2^subBucketBits linear sub-buckets, so recorded values are within ~0.8% (HDR style).
+const subBucketBits = 7
+
+const subBuckets = 1 << subBucketBits
+
+// histogram records latencies in microseconds with bounded relative error and
+// constant memory per order of magnitude. It is not safe for concurrent use.
+type histogram struct {
+	counts []uint64
+	total  uint64
+	sum    int64
+	min    int64
+	max    int64
+}
+
+func newHistogram() *histogram {
+	return &histogram{counts: make([]uint64, 0, 32*subBuckets)}
+}
+
+func bucketIndex(v int64) int {
+	if v < subBuckets {
+		return int(v)
+	}
+	shift := bits.Len64(uint64(v)) - subBucketBits - 1
+	sub := v >> shift
+	return (shift+1)<<subBucketBits + int(sub-subBuckets)
+}
+
+// bucketHigh is the highest value that falls into bucket i.
+func bucketHigh(i int) int64 {
+	if i < subBuckets {
+		return int64(i)
+	}
+	shift := i>>subBucketBits - 1
+	sub := int64(i&(subBuckets-1)) + subBuckets
+	return (sub+1)<<shift - 1
+}
+
+func (h *histogram) record(d time.Duration) {
+	v := d.Microseconds()
+	if v < 0 {
+		v = 0
+	}
+	i := bucketIndex(v)
+	if i >= len(h.counts) {
+		h.counts = append(h.counts, make([]uint64, i+1-len(h.counts))...)
+	}
+	h.counts[i]++
+	if h.total == 0 || v < h.min {
+		h.min = v
+	}
+	if v > h.max {
+		h.max = v
+	}
+	h.total++
+	h.sum += v
+}
+
+// merge adds every value recorded in o.
+func (h *histogram) merge(o *histogram) {
+	if o.total == 0 {
+		return
+	}
+	if len(o.counts) > len(h.counts) {
+		h.counts = append(h.counts, make([]uint64, len(o.counts)-len(h.counts))...)
+	}
+	for i, c := range o.counts {
+		h.counts[i] += c
+	}
+	if h.total == 0 || o.min < h.min {
+		h.min = o.min
+	}
+	if o.max > h.max {
+		h.max = o.max
+	}
+	h.total += o.total
+	h.sum += o.sum
+}
+
+// maxBucket bounds the bucket index of any int64 value; decoding rejects larger
+// ones instead of allocating for them.
+const maxBucket = 64 << subBucketBits
+
+// histogramJSON is how histograms travel between agents and the coordinator:
+// only the non-empty buckets, as [index, count] pairs.
+type histogramJSON struct {
+	Buckets [][2]uint64 `json:"buckets"`
+	Total   uint64      `json:"total"`
+	Sum     int64       `json:"sum"`
+	Min     int64       `json:"min"`
+	Max     int64       `json:"max"`
+}
+
+func (h *histogram) MarshalJSON() ([]byte, error) {
+	out := histogramJSON{Buckets: [][2]uint64{}, Total: h.total, Sum: h.sum, Min: h.min, Max: h.max}
+	for i, c := range h.counts {
+		if c > 0 {
+			out.Buckets = append(out.Buckets, [2]uint64{uint64(i), c})
+		}
+	}
+	return json.Marshal(out)
+}
+
+func (h *histogram) UnmarshalJSON(data []byte) error {
+	var in histogramJSON
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	*h = *newHistogram()
+	var total uint64
+	for _, b := range in.Buckets {
+		i, c := b[0], b[1]
+		if i >= maxBucket {
+			return fmt.Errorf("histograma: bucket %d fora do intervalo", i)
+		}
+		if int(i) >= len(h.counts) {
+			h.counts = append(h.counts, make([]uint64, int(i)+1-len(h.counts))...)
+		}
+		h.counts[i] += c
+		total += c
+	}
+	if total != in.Total {
+		return fmt.Errorf("histograma: total %d difere da soma dos buckets %d", in.Total, total)
+	}
+	h.total, h.sum, h.min, h.max = in.Total, in.Sum, in.Min, in.Max
+	return nil
+}
+
+// percentile returns the latency below which q percent (0-100) of the values fall.
+func (h *histogram) percentile(q float64) time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+	rank := uint64(q/100*float64(h.total) + 0.5)
+	if rank < 1 {
+		rank = 1
+	}
+	var seen uint64
+	for i, c := range h.counts {
+		seen += c
+		if seen >= rank {
+			return time.Duration(min(bucketHigh(i), h.max)) * time.Microsecond
+		}
+	}
+	return h.maxDuration()
+}
+
+func (h *histogram) mean() time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+	return time.Duration(h.sum/int64(h.total)) * time.Microsecond
+}
+
+func (h *histogram) minDuration() time.Duration {
+	return time.Duration(h.min) * time.Microsecond
+}
+
+func (h *histogram) maxDuration() time.Duration {
+	return time.Duration(h.max) * time.Microsecond
+}
+
+// histogramBar is one row of the ASCII histogram.
+type histogramBar struct {
+	from, to time.Duration
+	count    uint64
+}
+
+// bars groups the values into rows with geometrically growing ranges between min
+// and max, which keeps both the fast bulk and the slow tail readable.
+func (h *histogram) bars(rows int) []histogramBar {
+	if h.total == 0 || rows < 1 {
+		return nil
+	}
+	lo, hi := float64(max(h.min, 1)), float64(max(h.max, 1))
+	if hi <= lo {
+		return []histogramBar{{from: h.minDuration(), to: h.maxDuration(), count: h.total}}
+	}
+	ratio := math.Pow(hi/lo, 1/float64(rows))
+	out := make([]histogramBar, rows)
+	edge := lo
+	for r := range out {
+		next := edge * ratio
+		out[r] = histogramBar{from: time.Duration(edge) * time.Microsecond, to: time.Duration(next) * time.Microsecond}
+		edge = next
+	}
+	out[0].from = h.minDuration()
+	out[rows-1].to = h.maxDuration()
+	for i, c := range h.counts {
+		if c == 0 {
+			continue
+		}
+		v := float64(min(bucketHigh(i), h.max))
+		r := 0
+		for r < rows-1 && v >= float64(out[r].to.Microseconds()) {
+			r++
+		}
+		out[r].count += c
+	}
+	return out
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBucketIndex(t *testing.T) {
+	cases := []struct {
+		v    int64
+		want int
+	}{
+		{0, 0},
+		{1, 1},
+		{subBuckets - 1, subBuckets - 1},
+		{subBuckets, subBuckets},
+		{2*subBuckets - 1, 2*subBuckets - 1},
+		// Above 2*subBuckets every bucket spans two values, then four, ...
+		{2 * subBuckets, 2 * subBuckets},
+		{2*subBuckets + 1, 2 * subBuckets},
+		{2*subBuckets + 2, 2*subBuckets + 1},
+		{4 * subBuckets, 3 * subBuckets},
+	}
+	for _, tc := range cases {
+		if got := bucketIndex(tc.v); got != tc.want {
+			t.Fatalf("bucketIndex(%d) = %d, want %d", tc.v, got, tc.want)
+		}
+	}
+}
+
+func TestBucketHighBoundsItsValues(t *testing.T) {
+	for _, v := range []int64{0, 1, 127, 128, 255, 256, 257, 1000, 12_345, 1_000_000, 3_600_000_000} {
+		i := bucketIndex(v)
+		high := bucketHigh(i)
+		if high < v {
+			t.Fatalf("bucketHigh(%d) = %d below recorded value %d", i, high, v)
+		}
+		if bucketIndex(high) != i {
+			t.Fatalf("bucketHigh(%d) = %d belongs to bucket %d", i, high, bucketIndex(high))
+		}
+		// HDR precision: the bucket is at most 1/subBuckets of its value wide.
+		if v >= subBuckets && float64(high-v) > float64(v)/subBuckets {
+			t.Fatalf("bucket of %d too wide: high %d", v, high)
+		}
+	}
+}
+
+func TestHistogramPercentile(t *testing.T) {
+	h := newHistogram()
+	for i := 1; i <= 100; i++ {
+		h.record(time.Duration(i) * time.Millisecond)
+	}
+	cases := []struct {
+		q    float64
+		want time.Duration
+	}{
+		{0, time.Millisecond},
+		{50, 50 * time.Millisecond},
+		{90, 90 * time.Millisecond},
+		{99, 99 * time.Millisecond},
+		{100, 100 * time.Millisecond},
+	}
+	for _, tc := range cases {
+		got := h.percentile(tc.q)
+		// Values are reported as the top of their bucket, within ~0.8%.
+		if got < tc.want || float64(got-tc.want) > float64(tc.want)/subBuckets {
+			t.Fatalf("p%v = %s, want ~%s", tc.q, got, tc.want)
+		}
+	}
+	if h.minDuration() != time.Millisecond || h.maxDuration() != 100*time.Millisecond {
+		t.Fatalf("min/max = %s/%s", h.minDuration(), h.maxDuration())
+	}
+	if h.mean() != 50500*time.Microsecond {
+		t.Fatalf("mean = %s", h.mean())
+	}
+	if newHistogram().percentile(99) != 0 {
+		t.Fatalf("empty histogram should report 0")
+	}
+}
+
+func TestHistogramMerge(t *testing.T) {
+	a, b, all := newHistogram(), newHistogram(), newHistogram()
+	for i := 1; i <= 50; i++ {
+		d := time.Duration(i) * time.Millisecond
+		a.record(d)
+		all.record(d)
+	}
+	for i := 51; i <= 300; i++ {
+		d := time.Duration(i) * time.Millisecond
+		b.record(d)
+		all.record(d)
+	}
+	merged := newHistogram()
+	merged.merge(a)
+	merged.merge(b)
+	merged.merge(newHistogram())
+	if merged.total != all.total || merged.sum != all.sum || merged.min != all.min || merged.max != all.max {
+		t.Fatalf("merged %+v, want %+v", merged, all)
+	}
+	for _, q := range []float64{1, 50, 95, 99.9} {
+		if merged.percentile(q) != all.percentile(q) {
+			t.Fatalf("p%v = %s, want %s", q, merged.percentile(q), all.percentile(q))
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// maxBodyBytes caps how much of a response is kept for checks and extraction;
+// the rest is drained and only counted.
+const maxBodyBytes = 1 << 20
+
+// journey is a sequence of steps a simulated client performs per iteration.
+// Without a scenario file the command line request is a one-step journey.
+type journey struct {
+	name   string
+	weight int
+	steps  []step
+}
+
+type step struct {
+	name    string
+	request *requestSpec
+	checks  checks
+	extract []extraction
+	// think is the pause before the next step.
+	think time.Duration
+}
+
+// extraction stores the value at path in the journey variable name.
+type extraction struct {
+	name string
+	path *jsonPath
+}
+
+func (s step) needsBody() bool {
+	return len(s.extract) > 0 || s.checks.needsBody()
+}
+
+// stepLabel names a step in reports; the journey is omitted when there is only one.
+func stepLabel(journeys []journey, ji, si int) string {
+	if len(journeys) == 1 {
+		return journeys[ji].steps[si].name
+	}
+	return journeys[ji].name + "/" + journeys[ji].steps[si].name
+}
+
+// pickJourney spreads iterations over journeys in proportion to their weights.
+func pickJourney(journeys []journey, seq int64) int {
+	total := 0
+	for _, j := range journeys {
+		total += j.weight
+	}
+	r := int((seq - 1) % int64(total))
+	if r < 0 {
+		r += total
+	}
+	for i, j := range journeys {
+		if r < j.weight {
+			return i
+		}
+		r -= j.weight
+	}
+	return len(journeys) - 1
+}
+
+// runJourney performs one iteration. It stops at the first failed step, since
+// later steps may depend on the values it should have extracted.
+func runJourney(client *http.Client, cfg testConfig, j job, seq int64, stats *testStats, raw *rawLog) {
+	ji := pickJourney(cfg.journeys, seq)
+	jr := cfg.journeys[ji]
+	vars := make(map[string]string)
+	for si, st := range jr.steps {
+		// Latency covers the whole exchange, including reading the body.
+		sent := time.Now()
+		if si == 0 && !j.intended.IsZero() {
+			stats.recordSendDelay(sent.Sub(j.intended))
+			sent = j.intended
+		}
+		r := st.send(client, seq, vars)
+		r.latency = time.Since(sent)
+		if r.err == nil && r.check == nil {
+			r.check = st.checks.verifyLatency(r.latency)
+		}
+		r.stage, r.journey, r.step = j.stage, ji, si
+		stats.record(r)
+		raw.record(cfg, seq, sent, r)
+		if r.err != nil || r.check != nil {
+			stats.recordJourney(ji, false)
+			return
+		}
+		if st.think > 0 && si < len(jr.steps)-1 {
+			time.Sleep(st.think)
+		}
+	}
+	stats.recordJourney(ji, true)
+}
+
+// send performs the step and applies its checks and extractions. Failed
+// exchanges are reported as status code 0 with the classified cause; a body that
+// cannot be read fails the request even though a status was received.
+func (s step) send(client *http.Client, seq int64, vars map[string]string) result {
+	req, err := s.request.build(s.request.data(seq, vars))
+	if err != nil {
+		// Only runtime template data (e.g. a short CSV row) can fail here.
+		return result{err: &requestError{kind: errRequest, err: err}}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return result{err: classify(err, false)}
+	}
+	defer resp.Body.Close()
+
+	var (
+		body []byte
+		size int64
+	)
+	if s.needsBody() {
+		body, err = io.ReadAll(io.LimitReader(resp.Body, maxBodyBytes))
+		size = int64(len(body))
+	}
+	if err == nil {
+		var n int64
+		n, err = io.Copy(io.Discard, resp.Body)
+		size += n
+	}
+	if err != nil {
+		return result{size: size, err: classify(err, true)}
+	}
+
+	r := result{status: resp.StatusCode, size: size}
+	if err := s.checks.verify(resp.StatusCode, body); err != nil {
+		r.check = err
+		return r
+	}
+	if len(s.extract) == 0 {
+		return r
+	}
+	doc, err := decodeJSON(body)
+	if err != nil {
+		r.check = fmt.Errorf("extract: %v", err)
+		return r
+	}
+	for _, x := range s.extract {
+		v, err := x.path.lookup(doc)
+		if err != nil {
+			r.check = fmt.Errorf("extract %s: %v", x.name, err)
+			return r
+		}
+		vars[x.name] = jsonString(v)
+	}
+	return r
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// jsonPath is the subset of JSONPath used by extract and assertions: a root $
+// followed by .campo, ['campo'] and [índice] segments, e.g. $.data.items[0].cep.
+type jsonPath struct {
+	expr     string
+	segments []any // string field names and int indexes
+}
+
+func parseJSONPath(expr string) (*jsonPath, error) {
+	p := &jsonPath{expr: expr}
+	rest, ok := strings.CutPrefix(strings.TrimSpace(expr), "$")
+	if !ok {
+		return nil, fmt.Errorf("JSONPath %q deve começar com $", expr)
+	}
+	for rest != "" {
+		switch {
+		case rest[0] == '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("JSONPath %q tem um campo vazio", expr)
+			}
+			p.segments = append(p.segments, rest[:end])
+			rest = rest[end:]
+		case rest[0] == '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("JSONPath %q tem [ sem ]", expr)
+			}
+			inner := strings.TrimSpace(rest[1:end])
+			rest = rest[end+1:]
+			if len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0] {
+				p.segments = append(p.segments, inner[1:len(inner)-1])
+				continue
+			}
+			i, err := strconv.Atoi(inner)
+			if err != nil || i < 0 {
+				return nil, fmt.Errorf("JSONPath %q tem índice inválido %q", expr, inner)
+			}
+			p.segments = append(p.segments, i)
+		default:
+			return nil, fmt.Errorf("JSONPath %q inválido perto de %q", expr, rest)
+		}
+	}
+	return p, nil
+}
+
+// lookup returns the value at the path in a document decoded with UseNumber.
+func (p *jsonPath) lookup(doc any) (any, error) {
+	v := doc
+	for _, seg := range p.segments {
+		switch seg := seg.(type) {
+		case string:
+			obj, ok := v.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("%s: %q não é um objeto", p.expr, seg)
+			}
+			if v, ok = obj[seg]; !ok {
+				return nil, fmt.Errorf("%s: campo %q ausente", p.expr, seg)
+			}
+		case int:
+			arr, ok := v.([]any)
+			if !ok || seg >= len(arr) {
+				return nil, fmt.Errorf("%s: índice %d fora do array", p.expr, seg)
+			}
+			v = arr[seg]
+		}
+	}
+	return v, nil
+}
+
+// decodeJSON decodes a response body keeping numbers as written.
+func decodeJSON(body []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("corpo não é JSON: %v", err)
+	}
+	return doc, nil
+}
+
+// jsonString renders an extracted value for use in templates: strings and
+// numbers as written, anything else as JSON.
+func jsonString(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case nil:
+		return "null"
+	default:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
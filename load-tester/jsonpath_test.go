@@ -0,0 +1,80 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseJSONPath(t *testing.T) {
+	cases := []struct {
+		expr string
+		want []any
+	}{
+		{"$", nil},
+		{"$.data", []any{"data"}},
+		{"$.data.items[0].cep", []any{"data", "items", 0, "cep"}},
+		{"$['data']['a.b']", []any{"data", "a.b"}},
+		{`$["x y"][12]`, []any{"x y", 12}},
+		{" $.a ", []any{"a"}},
+	}
+	for _, tc := range cases {
+		p, err := parseJSONPath(tc.expr)
+		if err != nil {
+			t.Fatalf("%q: unexpected err: %v", tc.expr, err)
+		}
+		if !reflect.DeepEqual(p.segments, tc.want) {
+			t.Fatalf("%q: segments %#v, want %#v", tc.expr, p.segments, tc.want)
+		}
+	}
+}
+
+func TestParseJSONPath_Invalid(t *testing.T) {
+	for _, expr := range []string{"", "data", "$.", "$..a", "$[0", "$[-1]", "$[x]", "$a"} {
+		if _, err := parseJSONPath(expr); err == nil {
+			t.Fatalf("%q: expected error", expr)
+		}
+	}
+}
+
+func TestJSONPathLookup(t *testing.T) {
+	doc, err := decodeJSON([]byte(`{"data": {"token": "tok-123", "count": 10.50, "active": true,
+		"items": [{"cep": "01001000"}, {"cep": null}], "a.b": {"c": [1, 2]}}}`))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	cases := []struct {
+		expr string
+		want string
+	}{
+		{"$.data.token", "tok-123"},
+		// Numbers keep the text of the response.
+		{"$.data.count", "10.50"},
+		{"$.data.active", "true"},
+		{"$.data.items[0].cep", "01001000"},
+		{"$.data.items[1].cep", "null"},
+		{"$.data['a.b'].c", "[1,2]"},
+	}
+	for _, tc := range cases {
+		p, err := parseJSONPath(tc.expr)
+		if err != nil {
+			t.Fatalf("%q: %v", tc.expr, err)
+		}
+		v, err := p.lookup(doc)
+		if err != nil {
+			t.Fatalf("%q: unexpected err: %v", tc.expr, err)
+		}
+		if got := jsonString(v); got != tc.want {
+			t.Fatalf("%q = %q, want %q", tc.expr, got, tc.want)
+		}
+	}
+
+	for _, expr := range []string{"$.data.missing", "$.data.token.x", "$.data.items[2]", "$.data[0]", "$.data.items.cep"} {
+		p, err := parseJSONPath(expr)
+		if err != nil {
+			t.Fatalf("%q: %v", expr, err)
+		}
+		if _, err := p.lookup(doc); err == nil {
+			t.Fatalf("%q: expected lookup error", expr)
+		}
+	}
+}
@@ -1,173 +1,338 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
-	"io"
 	"net/http"
-	neturl "net/url"
 	"os"
-	"sort"
-	"sync"
+	"strings"
 	"time"
 )
 
+// defaultOpenConcurrency is the in-flight cap of the open model when
+// --concurrency is not given.
+const defaultOpenConcurrency = 256
+
 type testConfig struct {
-	targetURL       string
-	totalRequests   int
-	concurrency     int
+	// journeys are what each iteration runs; the command line request is a
+	// single one-step journey.
+	journeys []journey
+	// scenarioJourneys is true when journeys come from a scenario file, which
+	// adds per-step metrics to the report.
+	scenarioJourneys bool
+	totalRequests    int
+	concurrency      int
+	// duration bounds the run by time; with totalRequests also set, whichever
+	// ends first stops the test.
+	duration time.Duration
+	// rate switches to the open model: requests are scheduled at this many per
+	// second regardless of how fast responses come back.
+	rate float64
+	// stages, when set, replace rate/duration with a load profile; model says
+	// whether stage targets are arrival rates or worker counts.
+	stages          []stage
+	model           string
+	thresholds      []threshold
+	progress        bool
+	output          string
+	rawLogPath      string
 	requestTimeout  time.Duration
 	keepAliveIdle   time.Duration
 	maxIdleConns    int
 	maxConnsPerHost int
+	// agents, when set, make this process a coordinator that splits the load
+	// between them instead of sending requests itself.
+	agents     []string
+	agentToken string
+	// seqOffset and seqStride interleave the sequence numbers of agents so
+	// that {{.Seq}}, CSV rows and journey picks continue across processes:
+	// local iteration n is global iteration (n-1)*seqStride + seqOffset + 1.
+	seqOffset int
+	seqStride int
 }
 
-type testStats struct {
-	mu           sync.Mutex
-	total        int
-	success200   int
-	statusCounts map[int]int
-}
-
-func (s *testStats) record(statusCode int) {
-	s.mu.Lock()
-	s.total++
-	if statusCode == http.StatusOK {
-		s.success200++
-	}
-	s.statusCounts[statusCode] = s.statusCounts[statusCode] + 1
-	s.mu.Unlock()
-}
-
-func parseFlags() (testConfig, error) {
+// parseFlags reads the test flags from args. Agents parse the arguments the
+// coordinator forwards with their own flag set.
+func parseFlags(fs *flag.FlagSet, args []string) (testConfig, error) {
 	var (
 		urlFlag         string
 		requestsFlag    int
 		concurrencyFlag int
 		timeoutFlag     time.Duration
+		durationFlag    time.Duration
+		rateFlag        float64
+		stagesFlag      string
+		modelFlag       string
+		scenarioFlag    string
+		methodFlag      string
+		headerFlag      headerFlags
+		bodyFlag        string
+		bodyFileFlag    string
+		csvFlag         string
+		outputFlag      string
+		rawLogFlag      string
+		assertFlag      repeatedFlag
+		thresholdFlag   repeatedFlag
+		progressFlag    bool
+		agentsFlag      string
+		agentTokenFlag  string
 	)
 
-	flag.StringVar(&urlFlag, "url", "", "URL do serviço a ser testado")
-	flag.IntVar(&requestsFlag, "requests", 0, "Número total de requests")
-	flag.IntVar(&concurrencyFlag, "concurrency", 1, "Número de chamadas simultâneas")
-	flag.DurationVar(&timeoutFlag, "timeout", 10*time.Second, "Timeout por request (ex: 5s, 1m)")
-	flag.Parse()
-
-	if urlFlag == "" {
-		return testConfig{}, fmt.Errorf("parâmetro --url é obrigatório")
+	fs.StringVar(&urlFlag, "url", "", "URL do serviço a ser testado (aceita template); com jornadas de cenário, URL base")
+	fs.StringVar(&methodFlag, "method", http.MethodGet, "Método HTTP")
+	fs.Var(&headerFlag, "header", "Header \"Nome: valor\" (repetível, aceita template)")
+	fs.StringVar(&bodyFlag, "body", "", "Corpo da request (aceita template)")
+	fs.StringVar(&bodyFileFlag, "body-file", "", "Arquivo com o corpo da request (aceita template)")
+	fs.StringVar(&csvFlag, "csv", "", "CSV com cabeçalho cujas linhas alimentam {{.CSV.coluna}}, uma por request em rodízio")
+	fs.IntVar(&requestsFlag, "requests", 0, "Número total de requests (com jornadas de cenário: de iterações)")
+	fs.IntVar(&concurrencyFlag, "concurrency", 1, "Número de chamadas simultâneas (no modelo aberto: máximo de requests em andamento, padrão 256)")
+	fs.DurationVar(&timeoutFlag, "timeout", 10*time.Second, "Timeout por request (ex: 5s, 1m)")
+	fs.DurationVar(&durationFlag, "duration", 0, "Duração do teste (ex: 30s, 5m); com --requests, para no que terminar primeiro")
+	fs.Float64Var(&rateFlag, "rate", 0, "Taxa de chegada constante em requests/s (modelo aberto)")
+	fs.StringVar(&stagesFlag, "stages", "", "Estágios de carga duração:alvo separados por vírgula (ex: 30s:200,2m:200,10s:1000,30s:0)")
+	fs.StringVar(&modelFlag, "stage-model", modelOpen, "Alvo dos estágios: open (requests/s) ou closed (workers simultâneos)")
+	fs.StringVar(&scenarioFlag, "scenario", "", "Arquivo YAML ou JSON de cenário com estágios e jornadas")
+	fs.StringVar(&outputFlag, "output", outputText, "Formato do relatório: text, json, csv ou markdown")
+	fs.StringVar(&rawLogFlag, "raw-log", "", "Arquivo NDJSON com uma linha por request (timestamp, latência, status, bytes, erro)")
+	fs.Var(&assertFlag, "assert", "Verificação da resposta, repetível: status=200|201, body~texto, $.campo=valor, latency<300ms")
+	fs.Var(&thresholdFlag, "threshold", "Limite do teste, repetível (ex: p95<300ms, error_rate<1%, rps>100); se violado, sai com código 3")
+	fs.BoolVar(&progressFlag, "progress", true, "Mostra o progresso em stderr durante o teste: painel no terminal ou uma linha de log a cada 5s")
+	fs.StringVar(&agentsFlag, "agents", "", "Coordena o teste entre agentes (load-tester agent) em host:porta separados por vírgula, dividindo a carga entre eles")
+	fs.StringVar(&agentTokenFlag, "agent-token", "", "Token enviado aos agentes; deve ser igual ao --token deles")
+	if err := fs.Parse(args); err != nil {
+		return testConfig{}, err
 	}
-	if _, err := neturl.ParseRequestURI(urlFlag); err != nil {
-		return testConfig{}, fmt.Errorf("--url inválida: %v", err)
+
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	switch outputFlag {
+	case outputText, outputJSON, outputCSV, outputMarkdown:
+	default:
+		return testConfig{}, fmt.Errorf("--output deve ser text, json, csv ou markdown")
 	}
-	if requestsFlag <= 0 {
-		return testConfig{}, fmt.Errorf("--requests deve ser > 0")
+	if stagesFlag != "" && scenarioFlag != "" {
+		return testConfig{}, fmt.Errorf("use --stages ou --scenario, não ambos")
 	}
-	if concurrencyFlag <= 0 {
-		return testConfig{}, fmt.Errorf("--concurrency deve ser > 0")
+	var scenario *scenarioFile
+	if scenarioFlag != "" {
+		f, err := loadScenario(scenarioFlag)
+		if err != nil {
+			return testConfig{}, err
+		}
+		scenario = f
 	}
-	if concurrencyFlag > requestsFlag {
-		concurrencyFlag = requestsFlag
+	var rows []map[string]string
+	if csvFlag != "" {
+		r, err := readCSV(csvFlag)
+		if err != nil {
+			return testConfig{}, err
+		}
+		rows = r
 	}
 
-	cfg := testConfig{
-		targetURL:       urlFlag,
-		totalRequests:   requestsFlag,
-		concurrency:     concurrencyFlag,
-		requestTimeout:  timeoutFlag,
-		keepAliveIdle:   30 * time.Second,
-		maxIdleConns:    2048,
-		maxConnsPerHost: 0, // 0 means no limit; tuned by concurrency via client.
+	var journeys []journey
+	if scenario != nil && len(scenario.Journeys) > 0 {
+		if set["method"] || set["body"] || set["body-file"] || set["assert"] {
+			return testConfig{}, fmt.Errorf("--method, --body, --body-file e --assert não se aplicam a cenários com jornadas")
+		}
+		j, err := scenario.journeys(urlFlag, headerFlag, rows)
+		if err != nil {
+			return testConfig{}, err
+		}
+		journeys = j
+	} else {
+		if urlFlag == "" {
+			return testConfig{}, fmt.Errorf("parâmetro --url é obrigatório")
+		}
+		if bodyFlag != "" && bodyFileFlag != "" {
+			return testConfig{}, fmt.Errorf("use --body ou --body-file, não ambos")
+		}
+		if bodyFileFlag != "" {
+			data, err := os.ReadFile(bodyFileFlag)
+			if err != nil {
+				return testConfig{}, fmt.Errorf("não foi possível ler --body-file: %v", err)
+			}
+			bodyFlag = string(data)
+		}
+		request, err := newRequestSpec(methodFlag, urlFlag, headerFlag, bodyFlag, set["body"] || bodyFileFlag != "", rows)
+		if err != nil {
+			return testConfig{}, err
+		}
+		if err := request.validate(nil); err != nil {
+			return testConfig{}, err
+		}
+		var c checks
+		for _, a := range assertFlag {
+			if err := parseAssert(a, &c); err != nil {
+				return testConfig{}, err
+			}
+		}
+		journeys = []journey{{name: "request", weight: 1, steps: []step{{name: "request", request: request, checks: c}}}}
 	}
-	return cfg, nil
-}
 
-func buildHTTPClient(cfg testConfig) *http.Client {
-	transport := &http.Transport{
-		Proxy:                 http.ProxyFromEnvironment,
-		DisableCompression:    false,
-		MaxIdleConns:          cfg.maxIdleConns,
-		MaxIdleConnsPerHost:   cfg.maxIdleConns,
-		MaxConnsPerHost:       cfg.maxConnsPerHost,
-		IdleConnTimeout:       cfg.keepAliveIdle,
-		TLSHandshakeTimeout:   10 * time.Second,
-		ExpectContinueTimeout: 1 * time.Second,
-	}
-	return &http.Client{
-		Transport: transport,
-		Timeout:   cfg.requestTimeout,
+	exprs := []string(thresholdFlag)
+	if scenario != nil {
+		exprs = append(scenario.Thresholds, exprs...)
+	}
+	var thresholds []threshold
+	for _, e := range exprs {
+		t, err := parseThreshold(e)
+		if err != nil {
+			return testConfig{}, err
+		}
+		thresholds = append(thresholds, t)
+	}
+	if requestsFlag < 0 {
+		return testConfig{}, fmt.Errorf("--requests deve ser > 0")
+	}
+	if durationFlag < 0 {
+		return testConfig{}, fmt.Errorf("--duration deve ser > 0")
+	}
+	if rateFlag < 0 {
+		return testConfig{}, fmt.Errorf("--rate deve ser > 0")
 	}
-}
-
-func runLoadTest(cfg testConfig) (time.Duration, *testStats) {
-	client := buildHTTPClient(cfg)
-	defer client.CloseIdleConnections()
-
-	jobs := make(chan struct{})
-	var wg sync.WaitGroup
-
-	stats := &testStats{statusCounts: make(map[int]int)}
 
-	worker := func() {
-		defer wg.Done()
-		for range jobs {
-			resp, err := client.Get(cfg.targetURL)
-			if err != nil {
-				// Treat network/timeout errors as status code 0
-				stats.record(0)
-				continue
+	var stages []stage
+	switch {
+	case stagesFlag != "":
+		s, err := parseStages(stagesFlag)
+		if err != nil {
+			return testConfig{}, err
+		}
+		stages = s
+	case scenario != nil:
+		s, err := scenario.stages()
+		if err != nil {
+			return testConfig{}, err
+		}
+		if scenario.Model != "" && !set["stage-model"] {
+			modelFlag = scenario.Model
+		}
+		stages = s
+	}
+	if len(stages) > 0 {
+		if set["rate"] || set["duration"] {
+			return testConfig{}, fmt.Errorf("estágios não podem ser combinados com --rate ou --duration")
+		}
+		if modelFlag != modelOpen && modelFlag != modelClosed {
+			return testConfig{}, fmt.Errorf("--stage-model deve ser open ou closed")
+		}
+		if peakTarget(stages) <= 0 {
+			return testConfig{}, fmt.Errorf("ao menos um estágio deve ter alvo > 0")
+		}
+	} else if requestsFlag == 0 && durationFlag == 0 {
+		return testConfig{}, fmt.Errorf("informe --requests e/ou --duration")
+	}
+	var agents []string
+	if agentsFlag != "" {
+		for _, a := range strings.Split(agentsFlag, ",") {
+			if a = strings.TrimSpace(a); a != "" {
+				agents = append(agents, a)
 			}
-			io.Copy(io.Discard, resp.Body)
-			resp.Body.Close()
-			stats.record(resp.StatusCode)
+		}
+		if len(agents) == 0 {
+			return testConfig{}, fmt.Errorf("--agents deve listar ao menos um agente")
+		}
+		if rawLogFlag != "" {
+			return testConfig{}, fmt.Errorf("--raw-log não é suportado com --agents")
 		}
 	}
+	openModel := rateFlag > 0 || (len(stages) > 0 && modelFlag == modelOpen)
 
-	start := time.Now()
-	wg.Add(cfg.concurrency)
-	for i := 0; i < cfg.concurrency; i++ {
-		go worker()
+	if openModel && !set["concurrency"] {
+		// In the open model workers only cap in-flight requests; one worker
+		// would turn it back into a closed test.
+		concurrencyFlag = defaultOpenConcurrency
 	}
-
-	for i := 0; i < cfg.totalRequests; i++ {
-		jobs <- struct{}{}
+	if concurrencyFlag <= 0 {
+		return testConfig{}, fmt.Errorf("--concurrency deve ser > 0")
+	}
+	if requestsFlag > 0 && concurrencyFlag > requestsFlag {
+		concurrencyFlag = requestsFlag
 	}
-	close(jobs)
-	wg.Wait()
-	elapsed := time.Since(start)
 
-	return elapsed, stats
+	cfg := testConfig{
+		journeys:         journeys,
+		scenarioJourneys: scenario != nil && len(scenario.Journeys) > 0,
+		totalRequests:    requestsFlag,
+		concurrency:      concurrencyFlag,
+		duration:         durationFlag,
+		rate:             rateFlag,
+		stages:           stages,
+		model:            modelFlag,
+		thresholds:       thresholds,
+		progress:         progressFlag,
+		output:           outputFlag,
+		rawLogPath:       rawLogFlag,
+		requestTimeout:   timeoutFlag,
+		keepAliveIdle:    30 * time.Second,
+		maxIdleConns:     2048,
+		maxConnsPerHost:  0, // 0 means no limit; tuned by concurrency via client.
+		agents:           agents,
+		agentToken:       agentTokenFlag,
+		seqStride:        1,
+	}
+	return cfg, nil
 }
 
-func printReport(elapsed time.Duration, stats *testStats) {
-	fmt.Println("==== Relatório de Teste de Carga ====")
-	fmt.Printf("Tempo total: %s\n", elapsed)
-	fmt.Printf("Total de requests: %d\n", stats.total)
-	fmt.Printf("HTTP 200: %d\n", stats.success200)
-
-	fmt.Println("Distribuição de códigos de status:")
-	// Sort keys for stable output
-	keys := make([]int, 0, len(stats.statusCounts))
-	for k := range stats.statusCounts {
-		keys = append(keys, k)
-	}
-	sort.Ints(keys)
-	for _, code := range keys {
-		label := fmt.Sprintf("%d", code)
-		if code == 0 {
-			label = "erro (timeout/conexão)"
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "agent" {
+		if err := runAgent(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, "Erro:", err)
+			os.Exit(1)
 		}
-		fmt.Printf("  %s: %d\n", label, stats.statusCounts[code])
+		return
 	}
-}
 
-func main() {
-	cfg, err := parseFlags()
+	cfg, err := parseFlags(flag.CommandLine, os.Args[1:])
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "Erro:", err)
 		flag.Usage()
 		os.Exit(2)
 	}
 
-	elapsed, stats := runLoadTest(cfg)
-	printReport(elapsed, stats)
+	raw, err := openRawLog(cfg.rawLogPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Erro:", err)
+		os.Exit(1)
+	}
+
+	var (
+		elapsed time.Duration
+		stats   *testStats
+	)
+	if len(cfg.agents) > 0 {
+		elapsed, stats, err = runDistributed(context.Background(), cfg, flag.CommandLine)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Erro:", err)
+			os.Exit(1)
+		}
+	} else {
+		stats = newTestStats(len(cfg.stages), cfg.journeys)
+		elapsed = runLoadTest(context.Background(), cfg, stats, raw)
+	}
+	if err := raw.Close(); err != nil {
+		fmt.Fprintln(os.Stderr, "Erro ao gravar --raw-log:", err)
+	}
+
+	switch cfg.output {
+	case outputJSON:
+		err = writeJSON(os.Stdout, summarize(cfg, elapsed, stats))
+	case outputCSV:
+		err = writeCSV(os.Stdout, summarize(cfg, elapsed, stats))
+	case outputMarkdown:
+		err = writeMarkdown(os.Stdout, summarize(cfg, elapsed, stats))
+	default:
+		printReport(cfg, elapsed, stats)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Erro ao escrever o relatório:", err)
+		os.Exit(1)
+	}
+	if !thresholdsPassed(evaluateThresholds(cfg.thresholds, elapsed, stats)) {
+		fmt.Fprintln(os.Stderr, "Limites violados; veja o relatório.")
+		os.Exit(exitThresholdFailed)
+	}
 }
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	outputText     = "text"
+	outputJSON     = "json"
+	outputCSV      = "csv"
+	outputMarkdown = "markdown"
+)
+
+// summary is the machine-readable form of the report. Latencies are in
+// milliseconds so runs can be diffed and plotted without parsing durations.
+type summary struct {
+	Model             string                    `json:"model"`
+	TargetRate        float64                   `json:"target_rate,omitempty"`
+	Concurrency       int                       `json:"concurrency"`
+	Agents            int                       `json:"agents,omitempty"`
+	ElapsedSeconds    float64                   `json:"elapsed_seconds"`
+	Requests          int                       `json:"requests"`
+	Status200         int                       `json:"status_200"`
+	RequestsPerSecond float64                   `json:"requests_per_second"`
+	LateSends         int                       `json:"late_sends,omitempty"`
+	MaxSendDelayMs    float64                   `json:"max_send_delay_ms,omitempty"`
+	StatusCounts      map[string]int            `json:"status_counts"`
+	ClientTimeouts    int                       `json:"client_timeouts"`
+	ServerErrors      int                       `json:"server_errors_5xx"`
+	Errors            map[string]errorSummary   `json:"errors,omitempty"`
+	Latency           latencySummary            `json:"latency"`
+	LatencyByStatus   map[string]latencySummary `json:"latency_by_status"`
+	Stages            []stageSummary            `json:"stages,omitempty"`
+	Journeys          []journeySummary          `json:"journeys,omitempty"`
+	CheckFailures     int                       `json:"check_failures"`
+	Thresholds        []thresholdResult         `json:"thresholds,omitempty"`
+	// Passed is false when any threshold was breached.
+	Passed bool `json:"passed"`
+}
+
+type journeySummary struct {
+	Name      string        `json:"name"`
+	Weight    int           `json:"weight"`
+	Started   int           `json:"started"`
+	Completed int           `json:"completed"`
+	Steps     []stepSummary `json:"steps"`
+}
+
+type stepSummary struct {
+	Name     string         `json:"name"`
+	Requests int            `json:"requests"`
+	Passed   int            `json:"passed"`
+	Failed   int            `json:"failed_checks"`
+	Errors   int            `json:"errors"`
+	Failures []string       `json:"failure_samples,omitempty"`
+	Latency  latencySummary `json:"latency"`
+}
+
+type latencySummary struct {
+	Count       uint64             `json:"count"`
+	MinMs       float64            `json:"min_ms"`
+	MeanMs      float64            `json:"mean_ms"`
+	MaxMs       float64            `json:"max_ms"`
+	Percentiles map[string]float64 `json:"percentiles_ms"`
+}
+
+type errorSummary struct {
+	Count   int      `json:"count"`
+	Samples []string `json:"samples"`
+}
+
+type stageSummary struct {
+	Name              string         `json:"name"`
+	DurationSeconds   float64        `json:"duration_seconds"`
+	From              float64        `json:"from"`
+	Target            float64        `json:"target"`
+	Requests          int            `json:"requests"`
+	RequestsPerSecond float64        `json:"requests_per_second"`
+	Success           int            `json:"success_2xx"`
+	Errors            int            `json:"errors"`
+	Latency           latencySummary `json:"latency"`
+}
+
+func summarize(cfg testConfig, elapsed time.Duration, stats *testStats) summary {
+	s := summary{
+		Model:           modelClosed,
+		Concurrency:     cfg.concurrency,
+		Agents:          len(cfg.agents),
+		ElapsedSeconds:  elapsed.Seconds(),
+		Requests:        stats.total,
+		Status200:       stats.success200,
+		LateSends:       stats.late,
+		MaxSendDelayMs:  millis(stats.maxSendDelay),
+		StatusCounts:    make(map[string]int, len(stats.statusCounts)),
+		Latency:         summarizeLatency(stats.latency),
+		LatencyByStatus: make(map[string]latencySummary, len(stats.byStatus)),
+		ServerErrors:    serverErrors(stats.statusCounts),
+		CheckFailures:   stats.checkFailures,
+		Thresholds:      evaluateThresholds(cfg.thresholds, elapsed, stats),
+	}
+	s.Passed = thresholdsPassed(s.Thresholds)
+	for kind, e := range stats.errors {
+		if s.Errors == nil {
+			s.Errors = make(map[string]errorSummary, len(stats.errors))
+		}
+		s.Errors[kind] = errorSummary{Count: e.count, Samples: e.samples}
+	}
+	s.ClientTimeouts = s.Errors[errTimeout].Count
+	switch {
+	case len(cfg.stages) > 0:
+		s.Model = cfg.model
+	case cfg.rate > 0:
+		s.Model, s.TargetRate = modelOpen, cfg.rate
+	}
+	if elapsed > 0 {
+		s.RequestsPerSecond = float64(stats.total) / elapsed.Seconds()
+	}
+	for code, n := range stats.statusCounts {
+		s.StatusCounts[statusKey(code)] = n
+		s.LatencyByStatus[statusKey(code)] = summarizeLatency(stats.byStatus[code])
+	}
+	var from float64
+	for i, st := range cfg.stages {
+		ss := stats.stages[i]
+		s.Stages = append(s.Stages, stageSummary{
+			Name:              st.label(i),
+			DurationSeconds:   st.duration.Seconds(),
+			From:              from,
+			Target:            st.target,
+			Requests:          ss.total,
+			RequestsPerSecond: float64(ss.total) / st.duration.Seconds(),
+			Success:           ss.success,
+			Errors:            ss.errors,
+			Latency:           summarizeLatency(ss.latency),
+		})
+		from = st.target
+	}
+	if !cfg.scenarioJourneys {
+		return s
+	}
+	for i, j := range cfg.journeys {
+		js := journeySummary{Name: j.name, Weight: j.weight, Started: stats.journeys[i].started, Completed: stats.journeys[i].completed}
+		for k, st := range j.steps {
+			ss := stats.steps[i][k]
+			js.Steps = append(js.Steps, stepSummary{
+				Name:     st.name,
+				Requests: ss.total,
+				Passed:   ss.passed,
+				Failed:   ss.failed,
+				Errors:   ss.errors,
+				Failures: ss.samples,
+				Latency:  summarizeLatency(ss.latency),
+			})
+		}
+		s.Journeys = append(s.Journeys, js)
+	}
+	return s
+}
+
+func summarizeLatency(h *histogram) latencySummary {
+	l := latencySummary{Count: h.total, Percentiles: make(map[string]float64, len(reportPercentiles))}
+	if h.total == 0 {
+		return l
+	}
+	l.MinMs, l.MeanMs, l.MaxMs = millis(h.minDuration()), millis(h.mean()), millis(h.maxDuration())
+	for _, q := range reportPercentiles {
+		l.Percentiles[percentileKey(q)] = millis(h.percentile(q))
+	}
+	return l
+}
+
+// statusKey names a status code in machine-readable output; 0 is "error".
+func statusKey(code int) string {
+	if code == 0 {
+		return "error"
+	}
+	return strconv.Itoa(code)
+}
+
+func percentileKey(q float64) string {
+	return "p" + strconv.FormatFloat(q, 'f', -1, 64)
+}
+
+// sortedStatus returns the status keys of s in numeric order, errors first.
+func sortedStatus(s summary) []string {
+	keys := make([]string, 0, len(s.StatusCounts))
+	for k := range s.StatusCounts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, _ := strconv.Atoi(keys[i])
+		b, _ := strconv.Atoi(keys[j])
+		return a < b
+	})
+	return keys
+}
+
+func writeJSON(w io.Writer, s summary) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
+
+// writeCSV emits one row per latency scope: the whole run, each status code,
+// each stage and each journey step.
+func writeCSV(w io.Writer, s summary) error {
+	cw := csv.NewWriter(w)
+	header := []string{"scope", "name", "requests", "requests_per_second", "min_ms", "mean_ms"}
+	for _, q := range reportPercentiles {
+		header = append(header, percentileKey(q)+"_ms")
+	}
+	header = append(header, "max_ms")
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	row := func(scope, name string, rps float64, l latencySummary) []string {
+		r := []string{scope, name, strconv.FormatUint(l.Count, 10), formatFloat(rps), formatFloat(l.MinMs), formatFloat(l.MeanMs)}
+		for _, q := range reportPercentiles {
+			r = append(r, formatFloat(l.Percentiles[percentileKey(q)]))
+		}
+		return append(r, formatFloat(l.MaxMs))
+	}
+	rows := [][]string{row("total", "", s.RequestsPerSecond, s.Latency)}
+	for _, k := range sortedStatus(s) {
+		rps := 0.0
+		if s.ElapsedSeconds > 0 {
+			rps = float64(s.StatusCounts[k]) / s.ElapsedSeconds
+		}
+		rows = append(rows, row("status", k, rps, s.LatencyByStatus[k]))
+	}
+	for _, st := range s.Stages {
+		rows = append(rows, row("stage", st.Name, st.RequestsPerSecond, st.Latency))
+	}
+	for _, j := range s.Journeys {
+		for _, st := range j.Steps {
+			rps := 0.0
+			if s.ElapsedSeconds > 0 {
+				rps = float64(st.Requests) / s.ElapsedSeconds
+			}
+			rows = append(rows, row("step", j.Name+"/"+st.Name, rps, st.Latency))
+		}
+	}
+	// Error rows only carry a count; their latency is that of the "error" status.
+	for _, kind := range sortedErrors(s) {
+		r := make([]string, len(header))
+		r[0], r[1], r[2] = "error", kind, strconv.Itoa(s.Errors[kind].Count)
+		rows = append(rows, r)
+	}
+	if err := cw.WriteAll(rows); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+func writeMarkdown(w io.Writer, s summary) error {
+	var b strings.Builder
+	b.WriteString("## Relatório de Teste de Carga\n\n")
+	b.WriteString("| Métrica | Valor |\n|---|---|\n")
+	fmt.Fprintf(&b, "| Modelo | %s |\n", s.Model)
+	if s.Agents > 0 {
+		fmt.Fprintf(&b, "| Agentes | %d |\n", s.Agents)
+	}
+	fmt.Fprintf(&b, "| Tempo total | %.3fs |\n", s.ElapsedSeconds)
+	fmt.Fprintf(&b, "| Total de requests | %d |\n", s.Requests)
+	fmt.Fprintf(&b, "| HTTP 200 | %d |\n", s.Status200)
+	fmt.Fprintf(&b, "| Requests por segundo | %.1f |\n", s.RequestsPerSecond)
+	if s.LateSends > 0 {
+		fmt.Fprintf(&b, "| Envios atrasados | %d |\n", s.LateSends)
+	}
+
+	b.WriteString("\n### Latência (ms)\n\n")
+	b.WriteString("| Escopo | Requests | mín | média |")
+	for _, q := range reportPercentiles {
+		fmt.Fprintf(&b, " %s |", percentileKey(q))
+	}
+	b.WriteString(" máx |\n|---|---|---|---|")
+	for range reportPercentiles {
+		b.WriteString("---|")
+	}
+	b.WriteString("---|\n")
+	line := func(name string, l latencySummary) {
+		fmt.Fprintf(&b, "| %s | %d | %s | %s |", name, l.Count, formatFloat(l.MinMs), formatFloat(l.MeanMs))
+		for _, q := range reportPercentiles {
+			fmt.Fprintf(&b, " %s |", formatFloat(l.Percentiles[percentileKey(q)]))
+		}
+		fmt.Fprintf(&b, " %s |\n", formatFloat(l.MaxMs))
+	}
+	line("total", s.Latency)
+	for _, k := range sortedStatus(s) {
+		line("status "+k, s.LatencyByStatus[k])
+	}
+
+	if len(s.Errors) > 0 {
+		b.WriteString("\n### Erros\n\n")
+		fmt.Fprintf(&b, "Timeouts do cliente: %d · respostas 5xx do servidor: %d\n\n", s.ClientTimeouts, s.ServerErrors)
+		b.WriteString("| Categoria | Requests | Exemplo |\n|---|---|---|\n")
+		for _, kind := range sortedErrors(s) {
+			e := s.Errors[kind]
+			sample := ""
+			if len(e.Samples) > 0 {
+				sample = "`" + strings.ReplaceAll(e.Samples[0], "|", "\\|") + "`"
+			}
+			fmt.Fprintf(&b, "| %s | %d | %s |\n", kind, e.Count, sample)
+		}
+	}
+
+	if len(s.Stages) > 0 {
+		b.WriteString("\n### Estágios\n\n")
+		b.WriteString("| Estágio | Duração (s) | Alvo | Requests | req/s | 2xx | Erros | p50 | p95 | p99 |\n")
+		b.WriteString("|---|---|---|---|---|---|---|---|---|---|\n")
+		for _, st := range s.Stages {
+			fmt.Fprintf(&b, "| %s | %g | %g→%g | %d | %.1f | %d | %d | %s | %s | %s |\n",
+				st.Name, st.DurationSeconds, st.From, st.Target, st.Requests, st.RequestsPerSecond, st.Success, st.Errors,
+				formatFloat(st.Latency.Percentiles["p50"]), formatFloat(st.Latency.Percentiles["p95"]), formatFloat(st.Latency.Percentiles["p99"]))
+		}
+	}
+	if len(s.Thresholds) > 0 {
+		b.WriteString("\n### Limites\n\n| Limite | Medido | Resultado |\n|---|---|---|\n")
+		for _, r := range s.Thresholds {
+			verdict := "✅ ok"
+			if !r.Passed {
+				verdict = "❌ violado"
+			}
+			fmt.Fprintf(&b, "| `%s` | %s | %s |\n", r.Expr, r.display, verdict)
+		}
+	}
+
+	if len(s.Journeys) > 0 {
+		b.WriteString("\n### Passos\n\n")
+		b.WriteString("| Jornada | Passo | Requests | Ok | Falhas | Erros | p50 | p95 | p99 |\n")
+		b.WriteString("|---|---|---|---|---|---|---|---|---|\n")
+		for _, j := range s.Journeys {
+			for _, st := range j.Steps {
+				fmt.Fprintf(&b, "| %s | %s | %d | %d | %d | %d | %s | %s | %s |\n",
+					j.Name, st.Name, st.Requests, st.Passed, st.Failed, st.Errors,
+					formatFloat(st.Latency.Percentiles["p50"]), formatFloat(st.Latency.Percentiles["p95"]), formatFloat(st.Latency.Percentiles["p99"]))
+			}
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// sortedErrors returns the error categories of s, most frequent first.
+func sortedErrors(s summary) []string {
+	kinds := make([]string, 0, len(s.Errors))
+	for k := range s.Errors {
+		kinds = append(kinds, k)
+	}
+	sort.Slice(kinds, func(i, j int) bool {
+		if s.Errors[kinds[i]].Count != s.Errors[kinds[j]].Count {
+			return s.Errors[kinds[i]].Count > s.Errors[kinds[j]].Count
+		}
+		return kinds[i] < kinds[j]
+	})
+	return kinds
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', 3, 64)
+}
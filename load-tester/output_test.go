@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// oneStep is the journey of a command-line test.
+var oneStep = []journey{{name: "request", weight: 1, steps: []step{{name: "request"}}}}
+
+// sampleSummary is a staged open-model run: three 200s, a 503 and a transport error.
+func sampleSummary() summary {
+	cfg := testConfig{concurrency: 2, model: modelOpen, stages: []stage{{name: "pico", duration: 2 * time.Second, target: 10}}}
+	stats := newTestStats(1, oneStep)
+	for _, r := range []struct {
+		status  int
+		latency time.Duration
+	}{{200, 10 * time.Millisecond}, {200, 20 * time.Millisecond}, {200, 30 * time.Millisecond}, {503, 50 * time.Millisecond}, {0, time.Second}} {
+		var rerr *requestError
+		if r.status == 0 {
+			rerr = &requestError{kind: errReset, err: errors.New("connection reset by peer")}
+		}
+		stats.record(result{stage: 0, status: r.status, latency: r.latency, err: rerr})
+	}
+	return summarize(cfg, 2*time.Second, stats)
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeJSON(&buf, sampleSummary()); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	var got struct {
+		Model             string         `json:"model"`
+		Requests          int            `json:"requests"`
+		Status200         int            `json:"status_200"`
+		RequestsPerSecond float64        `json:"requests_per_second"`
+		StatusCounts      map[string]int `json:"status_counts"`
+		Latency           struct {
+			Count       int                `json:"count"`
+			MaxMs       float64            `json:"max_ms"`
+			Percentiles map[string]float64 `json:"percentiles_ms"`
+		} `json:"latency"`
+		LatencyByStatus map[string]json.RawMessage `json:"latency_by_status"`
+		Stages          []struct {
+			Name     string `json:"name"`
+			Requests int    `json:"requests"`
+			Success  int    `json:"success_2xx"`
+			Errors   int    `json:"errors"`
+		} `json:"stages"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, buf.String())
+	}
+	if got.Model != modelOpen || got.Requests != 5 || got.Status200 != 3 || got.RequestsPerSecond != 2.5 {
+		t.Fatalf("unexpected totals %+v", got)
+	}
+	if got.StatusCounts["200"] != 3 || got.StatusCounts["503"] != 1 || got.StatusCounts["error"] != 1 || len(got.LatencyByStatus) != 3 {
+		t.Fatalf("unexpected status breakdown %v", got.StatusCounts)
+	}
+	if got.Latency.Count != 5 || got.Latency.MaxMs < 1000 || len(got.Latency.Percentiles) != len(reportPercentiles) || got.Latency.Percentiles["p99.9"] == 0 {
+		t.Fatalf("unexpected latency %+v", got.Latency)
+	}
+	if len(got.Stages) != 1 || got.Stages[0].Name != "pico" || got.Stages[0].Requests != 5 || got.Stages[0].Success != 3 || got.Stages[0].Errors != 1 {
+		t.Fatalf("unexpected stages %+v", got.Stages)
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeCSV(&buf, sampleSummary()); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("output is not CSV: %v", err)
+	}
+	header := records[0]
+	if header[0] != "scope" || !slices.Contains(header, "p99.9_ms") || header[len(header)-1] != "max_ms" {
+		t.Fatalf("unexpected header %q", header)
+	}
+	var scopes []string
+	for _, r := range records[1:] {
+		scopes = append(scopes, r[0]+":"+r[1])
+	}
+	// Status rows are in numeric order, with transport errors first; error
+	// categories come last.
+	want := []string{"total:", "status:error", "status:200", "status:503", "stage:pico", "error:connection_reset"}
+	if !slices.Equal(scopes, want) {
+		t.Fatalf("rows %q, want %q", scopes, want)
+	}
+	if records[1][2] != "5" || records[1][3] != "2.500" || records[3][2] != "3" {
+		t.Fatalf("unexpected values %q %q", records[1], records[3])
+	}
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeMarkdown(&buf, sampleSummary()); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{"| Total de requests | 5 |", "| HTTP 200 | 3 |", "| status error | 1 |", "| status 503 | 1 |", "### Estágios", "| pico | 2 | 0→10 | 5 |"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("markdown lacks %q:\n%s", want, out)
+		}
+	}
+	// Every table row has as many cells as its header.
+	var cols int
+	for _, line := range strings.Split(out, "\n") {
+		switch {
+		case !strings.HasPrefix(line, "|"):
+			cols = 0
+		case cols == 0:
+			cols = strings.Count(line, "|")
+		case strings.Count(line, "|") != cols:
+			t.Fatalf("row %q has %d separators, want %d", line, strings.Count(line, "|"), cols)
+		}
+	}
+}
+
+func TestRawLogWritesOneLinePerRequest(t *testing.T) {
+	srv, _ := countingServer(t, 0)
+	cfg := testRunConfig(t, srv.URL)
+	cfg.totalRequests = 12
+
+	path := filepath.Join(t.TempDir(), "raw.ndjson")
+	raw, err := openRawLog(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	runTest(cfg, raw)
+	if err := raw.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open log: %v", err)
+	}
+	defer f.Close()
+	var seqs []int64
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var e rawEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			t.Fatalf("line %q is not JSON: %v", sc.Text(), err)
+		}
+		if e.Status != 200 || e.LatencyMs <= 0 || e.Timestamp.IsZero() || e.Error != "" {
+			t.Fatalf("unexpected entry %+v", e)
+		}
+		seqs = append(seqs, e.Seq)
+	}
+	slices.Sort(seqs)
+	if len(seqs) != 12 || seqs[0] != 1 || seqs[11] != 12 || len(slices.Compact(seqs)) != 12 {
+		t.Fatalf("unexpected sequence numbers %v", seqs)
+	}
+
+	// Without --raw-log the log is nil and every call is a no-op.
+	none, err := openRawLog("")
+	if err != nil || none != nil {
+		t.Fatalf("empty path should disable the log")
+	}
+	none.record(cfg, 1, time.Now(), result{stage: -1, status: 200, latency: time.Millisecond})
+	if err := none.Close(); err != nil {
+		t.Fatalf("close nil log: %v", err)
+	}
+}
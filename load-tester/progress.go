@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// dashboardInterval is the refresh period of the terminal view.
+	dashboardInterval = time.Second
+	// progressLogInterval spaces the log lines written when stderr is not a terminal.
+	progressLogInterval = 5 * time.Second
+	// rollingWindow is the span of the "current" RPS and percentiles.
+	rollingWindow    = 10 * time.Second
+	progressBarWidth = 30
+)
+
+// progress shows the run on stderr while it happens: a dashboard redrawn in
+// place when stderr is a terminal, periodic log lines otherwise. Stdout is left
+// to the report, so --output json|csv|markdown stays machine-readable.
+type progress struct {
+	cfg      testConfig
+	stats    progressSource
+	start    time.Time
+	out      io.Writer
+	tty      bool
+	interval time.Duration
+	// windows are the latency histograms of the last ticks, oldest first, with
+	// the requests completed and the time covered by each.
+	windows  []*histogram
+	counts   []int
+	spans    []time.Duration
+	last     int
+	lastTick time.Time
+	// lines is the height of the last frame, erased before drawing the next one.
+	lines int
+}
+
+// progressSource is what the view polls: the local stats, or in a distributed
+// run the merged stream of the agents.
+type progressSource interface {
+	snapshot() progressSnapshot
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func newProgress(cfg testConfig, stats progressSource, start time.Time) *progress {
+	p := &progress{cfg: cfg, stats: stats, start: start, lastTick: start, out: os.Stderr, interval: progressLogInterval}
+	if isTerminal(os.Stderr) {
+		p.tty, p.interval = true, dashboardInterval
+	}
+	return p
+}
+
+// startProgress begins reporting and returns a function that draws the final
+// frame and stops.
+func startProgress(cfg testConfig, stats progressSource, start time.Time) (stop func()) {
+	p := newProgress(cfg, stats, start)
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		tick := time.NewTicker(p.interval)
+		defer tick.Stop()
+		for {
+			select {
+			case <-tick.C:
+				p.tick()
+			case <-done:
+				if p.tty {
+					p.tick()
+				}
+				return
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-finished
+	}
+}
+
+func (p *progress) tick() {
+	snap := p.stats.snapshot()
+	now := time.Now()
+	p.windows = append(p.windows, snap.window)
+	p.counts = append(p.counts, snap.total-p.last)
+	p.spans = append(p.spans, now.Sub(p.lastTick))
+	p.last, p.lastTick = snap.total, now
+	if keep := int(rollingWindow / p.interval); len(p.windows) > keep {
+		p.windows = p.windows[len(p.windows)-keep:]
+		p.counts = p.counts[len(p.counts)-keep:]
+		p.spans = p.spans[len(p.spans)-keep:]
+	}
+
+	rolling := newHistogram()
+	var (
+		done int
+		span time.Duration
+	)
+	for i, w := range p.windows {
+		rolling.merge(w)
+		done += p.counts[i]
+		span += p.spans[i]
+	}
+	rps := 0.0
+	if span > 0 {
+		rps = float64(done) / span.Seconds()
+	}
+
+	if p.tty {
+		p.draw(snap, rolling, rps)
+		return
+	}
+	done = snap.total
+	if p.cfg.scenarioJourneys {
+		done = snap.iterations
+	}
+	fmt.Fprintf(p.out, "[%s] %s, %.1f req/s, p50 %s, p99 %s, erros %s\n",
+		fmtElapsed(time.Since(p.start)), p.completed(done), rps,
+		fmtLatency(rolling.percentile(50)), fmtLatency(rolling.percentile(99)), percent(snap.failed, snap.total))
+}
+
+func (p *progress) draw(snap progressSnapshot, rolling *histogram, rps float64) {
+	var b strings.Builder
+	if p.lines > 0 {
+		// Move back to the top of the previous frame and clear it.
+		fmt.Fprintf(&b, "\033[%dA\033[J", p.lines)
+	}
+	elapsed := time.Since(p.start)
+	fmt.Fprintf(&b, "Tempo: %s", fmtElapsed(elapsed))
+	if limit := p.timeLimit(); limit > 0 {
+		fmt.Fprintf(&b, " / %s", fmtElapsed(limit))
+	}
+	b.WriteString("\n")
+	if p.cfg.scenarioJourneys {
+		fmt.Fprintf(&b, "Progresso: %s %s (%d requests)\n", p.completed(snap.iterations), p.bar(snap.iterations, elapsed), snap.total)
+	} else {
+		fmt.Fprintf(&b, "Progresso: %s %s\n", p.completed(snap.total), p.bar(snap.total, elapsed))
+	}
+	if len(p.cfg.stages) > 0 {
+		level, idx, _ := levelAt(p.cfg.stages, elapsed)
+		fmt.Fprintf(&b, "Estágio: %s (alvo atual %.1f)\n", p.cfg.stages[idx].label(idx), level)
+	}
+	fmt.Fprintf(&b, "Taxa atual: %.1f req/s   p50: %s   p99: %s (últimos %s)\n",
+		rps, fmtLatency(rolling.percentile(50)), fmtLatency(rolling.percentile(99)), rollingWindow)
+	fmt.Fprintf(&b, "Erros: %d (%s)\n", snap.failed, percent(snap.failed, snap.total))
+	fmt.Fprintf(&b, "Status: %s\n", statusLine(snap.statusCounts))
+	frame := b.String()
+	p.lines = strings.Count(frame, "\n")
+	io.WriteString(p.out, frame)
+}
+
+// timeLimit is how long the run is planned to last, or 0 when only a request
+// count bounds it.
+func (p *progress) timeLimit() time.Duration {
+	if len(p.cfg.stages) > 0 {
+		return totalDuration(p.cfg.stages)
+	}
+	return p.cfg.duration
+}
+
+// completed is the progress towards --requests, which counts iterations when
+// the scenario has journeys.
+func (p *progress) completed(n int) string {
+	unit := "requests"
+	if p.cfg.scenarioJourneys {
+		unit = "iterações"
+	}
+	if p.cfg.totalRequests > 0 {
+		return fmt.Sprintf("%d/%d %s", n, p.cfg.totalRequests, unit)
+	}
+	return fmt.Sprintf("%d %s", n, unit)
+}
+
+// bar shows the progress towards the request count or, failing that, the time limit.
+func (p *progress) bar(total int, elapsed time.Duration) string {
+	var frac float64
+	switch {
+	case p.cfg.totalRequests > 0:
+		frac = float64(total) / float64(p.cfg.totalRequests)
+	case p.timeLimit() > 0:
+		frac = elapsed.Seconds() / p.timeLimit().Seconds()
+	default:
+		return ""
+	}
+	frac = min(max(frac, 0), 1)
+	filled := int(frac * progressBarWidth)
+	return fmt.Sprintf("[%s%s] %.0f%%", strings.Repeat("#", filled), strings.Repeat("-", progressBarWidth-filled), 100*frac)
+}
+
+func statusLine(counts map[int]int) string {
+	if len(counts) == 0 {
+		return "-"
+	}
+	codes := make([]int, 0, len(counts))
+	for code := range counts {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	parts := make([]string, len(codes))
+	for i, code := range codes {
+		parts[i] = fmt.Sprintf("%s: %d", statusLabel(code), counts[code])
+	}
+	return strings.Join(parts, "  ")
+}
+
+func fmtElapsed(d time.Duration) string {
+	return d.Truncate(time.Second).String()
+}
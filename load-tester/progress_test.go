@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recordSample adds two 200s and a transport error to stats.
+func recordSample(stats *testStats) {
+	stats.record(result{stage: -1, status: 200, latency: 10 * time.Millisecond})
+	stats.record(result{stage: -1, status: 200, latency: 20 * time.Millisecond})
+	stats.record(result{stage: -1, status: 0, latency: time.Second, err: &requestError{kind: errReset, err: errors.New("reset")}})
+}
+
+func TestProgressLeavesStdoutToTheReport(t *testing.T) {
+	if p := newProgress(testConfig{}, newTestStats(0, oneStep), time.Now()); p.out != os.Stderr {
+		t.Fatalf("progress must go to stderr, or it corrupts --output json|csv|markdown")
+	}
+}
+
+func TestProgressLogLine(t *testing.T) {
+	var buf bytes.Buffer
+	stats := newTestStats(0, oneStep)
+	p := &progress{cfg: testConfig{totalRequests: 10}, stats: stats, start: time.Now(), lastTick: time.Now(), out: &buf, interval: progressLogInterval}
+
+	recordSample(stats)
+	p.tick()
+	line := regexp.MustCompile(`^\[0s\] 3/10 requests, [0-9.]+ req/s, p50 \S+, p99 \S+, erros 33\.3%\n$`)
+	if !line.MatchString(buf.String()) {
+		t.Fatalf("unexpected log line %q", buf.String())
+	}
+	if strings.Contains(buf.String(), "\033[") {
+		t.Fatalf("log lines must not carry terminal escapes")
+	}
+}
+
+func TestProgressDashboardRedrawsInPlace(t *testing.T) {
+	var buf bytes.Buffer
+	stats := newTestStats(0, oneStep)
+	p := &progress{cfg: testConfig{duration: 10 * time.Second}, stats: stats, start: time.Now(), lastTick: time.Now(), out: &buf, tty: true, interval: dashboardInterval}
+
+	recordSample(stats)
+	p.tick()
+	first := buf.String()
+	for _, want := range []string{"Tempo: 0s / 10s", "Progresso: 3 requests [", "Erros: 1 (33.3%)", "Status: "} {
+		if !strings.Contains(first, want) {
+			t.Fatalf("frame lacks %q:\n%s", want, first)
+		}
+	}
+	if strings.HasPrefix(first, "\033[") {
+		t.Fatalf("the first frame has nothing to erase")
+	}
+
+	buf.Reset()
+	p.tick()
+	// The next frame moves up over the previous one and clears it.
+	if want := fmt.Sprintf("\033[%dA\033[J", strings.Count(first, "\n")); !strings.HasPrefix(buf.String(), want) {
+		t.Fatalf("second frame should start with %q, got %q", want, buf.String())
+	}
+}
+
+func TestProgressBar(t *testing.T) {
+	cases := []struct {
+		name    string
+		cfg     testConfig
+		done    int
+		elapsed time.Duration
+		want    string
+	}{
+		{"by requests", testConfig{totalRequests: 10}, 5, time.Hour, "50%"},
+		{"by time", testConfig{duration: 4 * time.Second}, 0, time.Second, "25%"},
+		{"capped", testConfig{duration: time.Second}, 0, time.Minute, "100%"},
+		{"unbounded", testConfig{}, 5, time.Second, ""},
+	}
+	for _, tc := range cases {
+		p := &progress{cfg: tc.cfg}
+		if got := p.bar(tc.done, tc.elapsed); !strings.HasSuffix(got, tc.want) || (tc.want == "") != (got == "") {
+			t.Fatalf("%s: bar %q, want suffix %q", tc.name, got, tc.want)
+		}
+	}
+
+	p := &progress{cfg: testConfig{totalRequests: 4, scenarioJourneys: true}}
+	if got := p.completed(2); got != "2/4 iterações" {
+		t.Fatalf("scenario progress counts iterations, got %q", got)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// rawEntry is one line of the --raw-log NDJSON file.
+type rawEntry struct {
+	Timestamp time.Time `json:"ts"`
+	Seq       int64     `json:"seq"`
+	Stage     string    `json:"stage,omitempty"`
+	Step      string    `json:"step,omitempty"`
+	LatencyMs float64   `json:"latency_ms"`
+	Status    int       `json:"status"`
+	Bytes     int64     `json:"bytes"`
+	ErrorType string    `json:"error_type,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	Check     string    `json:"check_failed,omitempty"`
+}
+
+// rawLog writes every request as a JSON line. A nil *rawLog discards entries.
+type rawLog struct {
+	mu  sync.Mutex
+	f   *os.File
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+func openRawLog(path string) (*rawLog, error) {
+	if path == "" {
+		return nil, nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("não foi possível criar --raw-log: %v", err)
+	}
+	w := bufio.NewWriter(f)
+	return &rawLog{f: f, w: w, enc: json.NewEncoder(w)}, nil
+}
+
+// record logs a request sent at sent; for the first step in the open model that
+// is the intended send time, matching the latency in the report.
+func (l *rawLog) record(cfg testConfig, seq int64, sent time.Time, r result) {
+	if l == nil {
+		return
+	}
+	e := rawEntry{Timestamp: sent, Seq: seq, LatencyMs: millis(r.latency), Status: r.status, Bytes: r.size}
+	if r.stage >= 0 {
+		e.Stage = cfg.stages[r.stage].label(r.stage)
+	}
+	if cfg.scenarioJourneys {
+		e.Step = stepLabel(cfg.journeys, r.journey, r.step)
+	}
+	if r.err != nil {
+		e.ErrorType, e.Error = r.err.kind, r.err.Error()
+	}
+	if r.check != nil {
+		e.Check = r.check.Error()
+	}
+	l.mu.Lock()
+	_ = l.enc.Encode(e)
+	l.mu.Unlock()
+}
+
+func (l *rawLog) Close() error {
+	if l == nil {
+		return nil
+	}
+	if err := l.w.Flush(); err != nil {
+		l.f.Close()
+		return err
+	}
+	return l.f.Close()
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
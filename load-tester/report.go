@@ -0,0 +1,268 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// reportPercentiles are the latency percentiles shown in the report.
+var reportPercentiles = []float64{50, 90, 95, 99, 99.9}
+
+const (
+	histogramRows  = 12
+	histogramWidth = 40
+)
+
+func printReport(cfg testConfig, elapsed time.Duration, stats *testStats) {
+	fmt.Println("==== Relatório de Teste de Carga ====")
+	switch {
+	case len(cfg.stages) > 0 && cfg.model == modelClosed:
+		fmt.Printf("Modelo: fechado, %d estágios em %s (pico de %.0f workers)\n", len(cfg.stages), totalDuration(cfg.stages), peakTarget(cfg.stages))
+	case len(cfg.stages) > 0:
+		fmt.Printf("Modelo: aberto, %d estágios em %s (pico de %.1f req/s, até %d em andamento)\n", len(cfg.stages), totalDuration(cfg.stages), peakTarget(cfg.stages), cfg.concurrency)
+	case cfg.rate > 0:
+		fmt.Printf("Modelo: aberto (taxa alvo %.1f req/s, até %d em andamento)\n", cfg.rate, cfg.concurrency)
+	default:
+		fmt.Printf("Modelo: fechado (concorrência %d)\n", cfg.concurrency)
+	}
+	if len(cfg.agents) > 0 {
+		fmt.Printf("Agentes: %d (carga dividida entre eles)\n", len(cfg.agents))
+	}
+	fmt.Printf("Tempo total: %s\n", elapsed)
+	fmt.Printf("Total de requests: %d\n", stats.total)
+	fmt.Printf("HTTP 200: %d\n", stats.success200)
+	if elapsed > 0 {
+		fmt.Printf("Requests por segundo: %.1f\n", float64(stats.total)/elapsed.Seconds())
+	}
+	if stats.late > 0 {
+		fmt.Printf("Envios atrasados (> %s): %d, atraso máx: %s (aumente --concurrency)\n", lateThreshold, stats.late, fmtLatency(stats.maxSendDelay))
+	}
+
+	fmt.Println("Distribuição de códigos de status:")
+	// Sort keys for stable output
+	keys := make([]int, 0, len(stats.statusCounts))
+	for k := range stats.statusCounts {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	for _, code := range keys {
+		fmt.Printf("  %s: %d\n", statusLabel(code), stats.statusCounts[code])
+	}
+
+	if len(stats.errors) > 0 || serverErrors(stats.statusCounts) > 0 {
+		printErrors(stats)
+	}
+
+	if stats.latency.total > 0 {
+		printLatency(stats, keys)
+	}
+	if len(cfg.stages) > 0 {
+		printStages(cfg, stats)
+	}
+	if cfg.scenarioJourneys {
+		printSteps(cfg, stats)
+	} else if !cfg.journeys[0].steps[0].checks.empty() {
+		printChecks(stats)
+	}
+	if len(cfg.thresholds) > 0 {
+		printThresholds(evaluateThresholds(cfg.thresholds, elapsed, stats))
+	}
+}
+
+// printChecks summarizes the --assert results of a single-request run.
+func printChecks(stats *testStats) {
+	st := stats.steps[0][0]
+	fmt.Printf("Verificações: %d ok, %d falharam\n", st.passed, st.failed)
+	for _, msg := range st.samples {
+		fmt.Printf("  ex.: %s\n", msg)
+	}
+}
+
+func printThresholds(results []thresholdResult) {
+	fmt.Println("Limites:")
+	for _, r := range results {
+		verdict := "ok"
+		if !r.Passed {
+			verdict = "VIOLADO"
+		}
+		fmt.Printf("  %-24s %-8s (medido: %s)\n", r.Expr, verdict, r.display)
+	}
+}
+
+// printSteps reports journeys and the metrics of each of their steps, plus a
+// few messages of the checks that failed.
+func printSteps(cfg testConfig, stats *testStats) {
+	fmt.Println("Jornadas:")
+	for i, j := range cfg.journeys {
+		js := stats.journeys[i]
+		fmt.Printf("  %s (peso %d): %d iniciadas, %d concluídas (%s)\n", j.name, j.weight, js.started, js.completed, percent(js.completed, js.started))
+	}
+	fmt.Println("Métricas por passo:")
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "  passo\trequests\tok\tfalhas\terros\tp50\tp95\tp99")
+	var failures []string
+	for i, j := range cfg.journeys {
+		for k := range j.steps {
+			st := stats.steps[i][k]
+			p50, p95, p99 := "-", "-", "-"
+			if st.total > 0 {
+				p50, p95, p99 = fmtLatency(st.latency.percentile(50)), fmtLatency(st.latency.percentile(95)), fmtLatency(st.latency.percentile(99))
+			}
+			label := stepLabel(cfg.journeys, i, k)
+			fmt.Fprintf(tw, "  %s\t%d\t%d\t%d\t%d\t%s\t%s\t%s\n", label, st.total, st.passed, st.failed, st.errors, p50, p95, p99)
+			for _, msg := range st.samples {
+				failures = append(failures, label+": "+msg)
+			}
+		}
+	}
+	tw.Flush()
+	if len(failures) > 0 {
+		fmt.Println("Verificações que falharam:")
+		for _, f := range failures {
+			fmt.Printf("  %s\n", f)
+		}
+	}
+}
+
+// printStages reports each stage over its nominal window; requests are
+// attributed to the stage they were scheduled in.
+func printStages(cfg testConfig, stats *testStats) {
+	unit := "req/s"
+	if cfg.model == modelClosed {
+		unit = "workers"
+	}
+	fmt.Println("Métricas por estágio:")
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "  estágio\tduração\talvo\trequests\treq/s\t2xx\terros\tp50\tp95\tp99")
+	var from float64
+	for i, st := range cfg.stages {
+		s := stats.stages[i]
+		p50, p95, p99 := "-", "-", "-"
+		if s.total > 0 {
+			p50, p95, p99 = fmtLatency(s.latency.percentile(50)), fmtLatency(s.latency.percentile(95)), fmtLatency(s.latency.percentile(99))
+		}
+		fmt.Fprintf(tw, "  %s\t%s\t%g→%g %s\t%d\t%.1f\t%s\t%d\t%s\t%s\t%s\n",
+			st.label(i), st.duration, from, st.target, unit, s.total, float64(s.total)/st.duration.Seconds(),
+			percent(s.success, s.total), s.errors, p50, p95, p99)
+		from = st.target
+	}
+	tw.Flush()
+}
+
+func percent(n, total int) string {
+	if total == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", 100*float64(n)/float64(total))
+}
+
+func printLatency(stats *testStats, codes []int) {
+	fmt.Println("Latência:")
+	fmt.Printf("  mín: %s  média: %s  máx: %s\n", fmtLatency(stats.latency.minDuration()), fmtLatency(stats.latency.mean()), fmtLatency(stats.latency.maxDuration()))
+	fmt.Printf("  %s\n", percentileLine(stats.latency))
+
+	fmt.Println("Histograma de latência:")
+	printHistogram(stats.latency)
+
+	fmt.Println("Latência por código de status:")
+	for _, code := range codes {
+		h := stats.byStatus[code]
+		fmt.Printf("  %s (%d): média %s  %s  máx %s\n", statusLabel(code), h.total, fmtLatency(h.mean()), percentileLine(h), fmtLatency(h.maxDuration()))
+	}
+}
+
+func statusLabel(code int) string {
+	if code == 0 {
+		return "erro"
+	}
+	return fmt.Sprintf("%d", code)
+}
+
+// printErrors separates failures the client caused or gave up on from answers
+// the server produced, then breaks client-side errors down by category.
+func printErrors(stats *testStats) {
+	timeouts := 0
+	if e, ok := stats.errors[errTimeout]; ok {
+		timeouts = e.count
+	}
+	fmt.Printf("Falhas: %d timeouts do cliente, %d erros de transporte, %d respostas 5xx do servidor\n",
+		timeouts, stats.statusCounts[0]-timeouts, serverErrors(stats.statusCounts))
+	if len(stats.errors) == 0 {
+		return
+	}
+	fmt.Println("Erros por categoria:")
+	for _, kind := range sortedErrorKinds(stats.errors) {
+		e := stats.errors[kind]
+		fmt.Printf("  %s: %d\n", errorLabels[kind], e.count)
+		for _, msg := range e.samples {
+			fmt.Printf("    ex.: %s\n", msg)
+		}
+	}
+}
+
+func serverErrors(counts map[int]int) int {
+	n := 0
+	for code, c := range counts {
+		if code >= 500 {
+			n += c
+		}
+	}
+	return n
+}
+
+// sortedErrorKinds orders categories by count, most frequent first.
+func sortedErrorKinds(errs map[string]*errorStats) []string {
+	kinds := make([]string, 0, len(errs))
+	for k := range errs {
+		kinds = append(kinds, k)
+	}
+	sort.Slice(kinds, func(i, j int) bool {
+		if errs[kinds[i]].count != errs[kinds[j]].count {
+			return errs[kinds[i]].count > errs[kinds[j]].count
+		}
+		return kinds[i] < kinds[j]
+	})
+	return kinds
+}
+
+func percentileLine(h *histogram) string {
+	parts := make([]string, len(reportPercentiles))
+	for i, q := range reportPercentiles {
+		parts[i] = fmt.Sprintf("p%g: %s", q, fmtLatency(h.percentile(q)))
+	}
+	return strings.Join(parts, "  ")
+}
+
+func printHistogram(h *histogram) {
+	bars := h.bars(histogramRows)
+	var peak uint64
+	for _, b := range bars {
+		peak = max(peak, b.count)
+	}
+	for _, b := range bars {
+		width := 0
+		if peak > 0 {
+			width = int(b.count * histogramWidth / peak)
+		}
+		if b.count > 0 && width == 0 {
+			width = 1
+		}
+		fmt.Printf("  %9s - %9s | %-*s %d (%.1f%%)\n", fmtLatency(b.from), fmtLatency(b.to), histogramWidth, strings.Repeat("#", width), b.count, 100*float64(b.count)/float64(h.total))
+	}
+}
+
+// fmtLatency rounds a latency to a readable precision for its magnitude.
+func fmtLatency(d time.Duration) string {
+	switch {
+	case d >= time.Second:
+		return d.Round(time.Millisecond).String()
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond).String()
+	default:
+		return d.Round(time.Microsecond).String()
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	neturl "net/url"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// headerFlags collects repeated --header "Nome: valor" flags.
+type headerFlags []string
+
+func (h *headerFlags) String() string { return strings.Join(*h, ", ") }
+
+func (h *headerFlags) Set(v string) error {
+	if name, _, ok := strings.Cut(v, ":"); !ok || strings.TrimSpace(name) == "" {
+		return fmt.Errorf("header %q deve ter o formato Nome: valor", v)
+	}
+	*h = append(*h, v)
+	return nil
+}
+
+// templateData is what URL, header and body templates can reference:
+//
+//	{{.Seq}}        sequence number of the request, starting at 1
+//	{{.CEP}}        random 8-digit CEP, new on every use
+//	{{.CSV.coluna}} column of the CSV row picked for this request
+//	{{.Vars.nome}}  value extracted by an earlier step of the journey
+type templateData struct {
+	Seq  int64
+	CSV  map[string]string
+	Vars map[string]string
+}
+
+func (templateData) CEP() string {
+	return fmt.Sprintf("%08d", rand.IntN(100000000))
+}
+
+// field is a request part that is either literal or rendered per request.
+type field struct {
+	raw  string
+	tmpl *template.Template
+}
+
+func newField(name, s string) (field, error) {
+	if !strings.Contains(s, "{{") {
+		return field{raw: s}, nil
+	}
+	t, err := template.New(name).Option("missingkey=error").Parse(s)
+	if err != nil {
+		return field{}, fmt.Errorf("template inválido em %s: %v", name, err)
+	}
+	return field{tmpl: t}, nil
+}
+
+func (f field) render(data templateData) (string, error) {
+	if f.tmpl == nil {
+		return f.raw, nil
+	}
+	var buf bytes.Buffer
+	if err := f.tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+type headerField struct {
+	name  string
+	value field
+}
+
+// requestSpec describes one HTTP request, rendered per iteration.
+type requestSpec struct {
+	method  string
+	url     field
+	headers []headerField
+	body    field
+	hasBody bool
+	// rows are the CSV records, keyed by the header line, used round-robin by Seq.
+	rows []map[string]string
+}
+
+func newRequestSpec(method, url string, headers []string, body string, hasBody bool, rows []map[string]string) (*requestSpec, error) {
+	if method == "" {
+		method = http.MethodGet
+	}
+	spec := &requestSpec{method: strings.ToUpper(method), hasBody: hasBody, rows: rows}
+	var err error
+	if spec.url, err = newField("url", url); err != nil {
+		return nil, err
+	}
+	for _, h := range headers {
+		name, value, _ := strings.Cut(h, ":")
+		name = strings.TrimSpace(name)
+		f, err := newField("header "+name, strings.TrimSpace(value))
+		if err != nil {
+			return nil, err
+		}
+		spec.headers = append(spec.headers, headerField{name: name, value: f})
+	}
+	if spec.body, err = newField("body", body); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// validate renders the request once so template and URL mistakes fail before the
+// test. vars are the journey variables available to it, filled with placeholders.
+func (s *requestSpec) validate(vars []string) error {
+	placeholders := make(map[string]string, len(vars))
+	for _, v := range vars {
+		placeholders[v] = "0"
+	}
+	req, err := s.build(s.data(0, placeholders))
+	if err != nil {
+		return fmt.Errorf("request inválida: %v", err)
+	}
+	if _, err := neturl.ParseRequestURI(req.URL.String()); err != nil {
+		return fmt.Errorf("url inválida: %v", err)
+	}
+	return nil
+}
+
+func readCSV(path string) ([]map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("não foi possível ler o CSV: %v", err)
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("CSV inválido: %v", err)
+	}
+	if len(records) < 2 {
+		return nil, fmt.Errorf("CSV deve ter cabeçalho e ao menos uma linha")
+	}
+	header := records[0]
+	rows := make([]map[string]string, 0, len(records)-1)
+	for _, rec := range records[1:] {
+		row := make(map[string]string, len(header))
+		for i, name := range header {
+			if i < len(rec) {
+				row[strings.TrimSpace(name)] = rec[i]
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// data is the template data of iteration seq.
+func (s *requestSpec) data(seq int64, vars map[string]string) templateData {
+	data := templateData{Seq: seq, Vars: vars}
+	if len(s.rows) > 0 {
+		n := int64(len(s.rows))
+		data.CSV = s.rows[(seq+n-1)%n]
+	}
+	return data
+}
+
+func (s *requestSpec) build(data templateData) (*http.Request, error) {
+	url, err := s.url.render(data)
+	if err != nil {
+		return nil, err
+	}
+	var body io.Reader
+	if s.hasBody {
+		b, err := s.body.render(data)
+		if err != nil {
+			return nil, err
+		}
+		body = strings.NewReader(b)
+	}
+	req, err := http.NewRequest(s.method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range s.headers {
+		v, err := h.value.render(data)
+		if err != nil {
+			return nil, err
+		}
+		if http.CanonicalHeaderKey(h.name) == "Host" {
+			req.Host = v
+			continue
+		}
+		req.Header.Add(h.name, v)
+	}
+	return req, nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func writeFeed(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "feed.csv")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	return path
+}
+
+// checkedSpec builds a request spec like the command line does, validating it
+// with the journey variables vars.
+func checkedSpec(method, url string, headers []string, body string, hasBody bool, csvPath string, vars ...string) (*requestSpec, error) {
+	var rows []map[string]string
+	if csvPath != "" {
+		var err error
+		if rows, err = readCSV(csvPath); err != nil {
+			return nil, err
+		}
+	}
+	spec, err := newRequestSpec(method, url, headers, body, hasBody, rows)
+	if err != nil {
+		return nil, err
+	}
+	return spec, spec.validate(vars)
+}
+
+func TestRequestSpecRendersPerRequest(t *testing.T) {
+	spec, err := checkedSpec("post", "http://localhost:8080/cep/{{.CSV.cep}}?seq={{.Seq}}",
+		[]string{"X-Seq: {{.Seq}}", "Authorization: Bearer {{.CSV.token}}", "Host: api.example.com"},
+		`{"n": {{.Seq}}}`, true, writeFeed(t, "cep,token\n01001000,a\n20040002,b\n"))
+	if err != nil {
+		t.Fatalf("spec: %v", err)
+	}
+	cases := []struct {
+		seq   int64
+		url   string
+		token string
+		body  string
+	}{
+		{1, "http://localhost:8080/cep/01001000?seq=1", "Bearer a", `{"n": 1}`},
+		{2, "http://localhost:8080/cep/20040002?seq=2", "Bearer b", `{"n": 2}`},
+		// CSV rows are reused round-robin.
+		{3, "http://localhost:8080/cep/01001000?seq=3", "Bearer a", `{"n": 3}`},
+	}
+	for _, tc := range cases {
+		req, err := spec.build(spec.data(tc.seq, nil))
+		if err != nil {
+			t.Fatalf("seq %d: %v", tc.seq, err)
+		}
+		body, _ := io.ReadAll(req.Body)
+		if req.Method != http.MethodPost || req.URL.String() != tc.url || req.Header.Get("Authorization") != tc.token || string(body) != tc.body {
+			t.Fatalf("seq %d: got %s %s %q %q", tc.seq, req.Method, req.URL, req.Header.Get("Authorization"), body)
+		}
+		if req.Host != "api.example.com" || req.Header.Get("X-Seq") == "" {
+			t.Fatalf("seq %d: Host %q, X-Seq %q", tc.seq, req.Host, req.Header.Get("X-Seq"))
+		}
+	}
+}
+
+func TestRequestSpecRandomCEP(t *testing.T) {
+	spec, err := checkedSpec("GET", "http://localhost/{{.CEP}}", nil, "", false, "")
+	if err != nil {
+		t.Fatalf("spec: %v", err)
+	}
+	cep := regexp.MustCompile(`^/\d{8}$`)
+	for seq := int64(1); seq <= 20; seq++ {
+		req, err := spec.build(spec.data(seq, nil))
+		if err != nil || !cep.MatchString(req.URL.Path) {
+			t.Fatalf("unexpected CEP path %q (%v)", req.URL.Path, err)
+		}
+	}
+}
+
+func TestRequestSpec_Invalid(t *testing.T) {
+	csvPath := writeFeed(t, "cep\n01001000\n")
+	cases := map[string]struct {
+		url, csv string
+		headers  []string
+	}{
+		"bad template":       {url: "http://localhost/{{.Seq"},
+		"unknown field":      {url: "http://localhost/{{.Nope}}"},
+		"missing CSV column": {url: "http://localhost/{{.CSV.token}}", csv: csvPath},
+		"relative URL":       {url: "localhost/x"},
+		"bad header":         {url: "http://localhost/", headers: []string{"X-A: {{.Seq"}},
+		"missing CSV file":   {url: "http://localhost/", csv: filepath.Join(t.TempDir(), "none.csv")},
+		"CSV without rows":   {url: "http://localhost/", csv: writeFeed(t, "cep\n")},
+		"unknown variable":   {url: "http://localhost/{{.Vars.token}}"},
+	}
+	for name, tc := range cases {
+		if _, err := checkedSpec("GET", tc.url, tc.headers, "", false, tc.csv); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+	if _, err := checkedSpec("GET", "http://localhost/{{.Vars.token}}", nil, "", false, "", "token"); err != nil {
+		t.Fatalf("a variable extracted by an earlier step should be accepted: %v", err)
+	}
+
+	var h headerFlags
+	if h.Set("sem dois pontos") == nil || h.Set(": valor") == nil {
+		t.Fatalf("headers without a name should be rejected")
+	}
+	if h.Set("X-A: 1") != nil || len(h) != 1 {
+		t.Fatalf("valid header rejected: %v", h)
+	}
+}
+
+func TestRunLoadTest_SendsRenderedRequests(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		paths []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		paths = append(paths, r.Method+" "+r.URL.Path+" "+string(body))
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	cfg := testRunConfig(t, srv.URL)
+	spec, err := checkedSpec("PUT", srv.URL+"/item/{{.Seq}}", nil, "{{.Seq}}", true, "")
+	if err != nil {
+		t.Fatalf("spec: %v", err)
+	}
+	cfg.journeys, cfg.totalRequests = requestJourney(spec), 4
+
+	runTest(cfg, nil)
+	slices.Sort(paths)
+	want := []string{"PUT /item/1 1", "PUT /item/2 2", "PUT /item/3 3", "PUT /item/4 4"}
+	if !slices.Equal(paths, want) {
+		t.Fatalf("server saw %q, want %q", paths, want)
+	}
+}
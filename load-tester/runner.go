@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+func buildHTTPClient(cfg testConfig) *http.Client {
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DisableCompression:    false,
+		MaxIdleConns:          cfg.maxIdleConns,
+		MaxIdleConnsPerHost:   cfg.maxIdleConns,
+		MaxConnsPerHost:       cfg.maxConnsPerHost,
+		IdleConnTimeout:       cfg.keepAliveIdle,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+	return &http.Client{
+		Transport: transport,
+		Timeout:   cfg.requestTimeout,
+	}
+}
+
+// job is one journey iteration to run. In the open model intended is the scheduled send
+// time and latency is measured from it, so time spent waiting for a free worker
+// counts against the target instead of being silently omitted.
+type job struct {
+	intended time.Time
+	// stage indexes cfg.stages; -1 outside staged runs.
+	stage int
+}
+
+// runLoadTest generates the configured load, recording into stats, until the
+// schedule ends or ctx is cancelled. Requests already sent are allowed to finish.
+func runLoadTest(ctx context.Context, cfg testConfig, stats *testStats, raw *rawLog) time.Duration {
+	client := buildHTTPClient(cfg)
+	defer client.CloseIdleConnections()
+
+	jobs := make(chan job)
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+
+	var seq atomic.Int64
+
+	// In closed-model stages only workers below active take jobs; the rest idle
+	// until the ramp reaches them.
+	var active *atomic.Int64
+	workers := cfg.concurrency
+	if len(cfg.stages) > 0 && cfg.model == modelClosed {
+		active = new(atomic.Int64)
+		workers = max(1, int(math.Ceil(peakTarget(cfg.stages))))
+	}
+
+	worker := func(idx int) {
+		defer wg.Done()
+		for {
+			if active != nil && int64(idx) >= active.Load() {
+				select {
+				case <-done:
+					return
+				case <-time.After(stageTick):
+				}
+				continue
+			}
+			j, ok := <-jobs
+			if !ok {
+				return
+			}
+			runJourney(client, cfg, j, cfg.globalSeq(seq.Add(1)), stats, raw)
+		}
+	}
+
+	start := time.Now()
+	if cfg.progress {
+		stop := startProgress(cfg, stats, start)
+		defer stop()
+	}
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go worker(i)
+	}
+
+	switch {
+	case len(cfg.stages) > 0 && cfg.model == modelClosed:
+		scheduleClosedStages(ctx, cfg, start, jobs, active)
+	case len(cfg.stages) > 0:
+		scheduleOpenStages(ctx, cfg, start, jobs)
+	case cfg.rate > 0:
+		scheduleOpen(ctx, cfg, start, jobs)
+	default:
+		scheduleClosed(ctx, cfg, start, jobs)
+	}
+	close(jobs)
+	close(done)
+	wg.Wait()
+	return time.Since(start)
+}
+
+// globalSeq maps a local iteration number to its place in the whole test, which
+// differs from it only on agents sharing a distributed run.
+func (cfg testConfig) globalSeq(n int64) int64 {
+	if cfg.seqStride <= 1 {
+		return n
+	}
+	return (n-1)*int64(cfg.seqStride) + int64(cfg.seqOffset) + 1
+}
+
+// sendJob hands j to the next free worker; it returns false if ctx is cancelled first.
+func sendJob(ctx context.Context, jobs chan<- job, j job) bool {
+	select {
+	case jobs <- j:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// sleepUntil waits for t; it returns false if ctx is cancelled first.
+func sleepUntil(ctx context.Context, t time.Time) bool {
+	wait := time.Until(t)
+	if wait <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// scheduleClosed hands the next request to whichever worker frees up first, so
+// the send rate follows the response time.
+func scheduleClosed(ctx context.Context, cfg testConfig, start time.Time, jobs chan<- job) {
+	deadline := start.Add(cfg.duration)
+	for i := 0; cfg.totalRequests == 0 || i < cfg.totalRequests; i++ {
+		if cfg.duration > 0 && !time.Now().Before(deadline) {
+			return
+		}
+		if !sendJob(ctx, jobs, job{stage: -1}) {
+			return
+		}
+	}
+}
+
+// scheduleOpen issues request i at start + i/rate. When every worker is busy the
+// schedule falls behind, but each job keeps its intended time and the backlog is
+// still sent, so the report shows the queueing a real client would have seen.
+func scheduleOpen(ctx context.Context, cfg testConfig, start time.Time, jobs chan<- job) {
+	interval := time.Duration(float64(time.Second) / cfg.rate)
+	for i := 0; cfg.totalRequests == 0 || i < cfg.totalRequests; i++ {
+		intended := start.Add(time.Duration(i) * interval)
+		if cfg.duration > 0 && intended.Sub(start) >= cfg.duration {
+			return
+		}
+		if !sleepUntil(ctx, intended) || !sendJob(ctx, jobs, job{intended: intended, stage: -1}) {
+			return
+		}
+	}
+}
+
+// scheduleOpenStages follows the arrival rate of each stage, ramping linearly
+// between targets. Arrival n is due when the area under the rate curve reaches n,
+// which carries fractional arrivals across stage boundaries.
+func scheduleOpenStages(ctx context.Context, cfg testConfig, start time.Time, jobs chan<- job) {
+	var (
+		offset time.Duration
+		from   float64
+		area   float64
+		sent   int
+	)
+	for idx, st := range cfg.stages {
+		d := st.duration.Seconds()
+		for n := math.Floor(area) + 1; ; n++ {
+			at, ok := arrivalOffset(from, st.target, d, n-area)
+			if !ok {
+				break
+			}
+			if cfg.totalRequests > 0 && sent >= cfg.totalRequests {
+				return
+			}
+			intended := start.Add(offset + time.Duration(at*float64(time.Second)))
+			if !sleepUntil(ctx, intended) || !sendJob(ctx, jobs, job{intended: intended, stage: idx}) {
+				return
+			}
+			sent++
+		}
+		area += (from + st.target) / 2 * d
+		offset += st.duration
+		from = st.target
+	}
+}
+
+// scheduleClosedStages keeps round(level) workers busy, re-reading the level at
+// least every stageTick so a ramp from zero workers still advances.
+func scheduleClosedStages(ctx context.Context, cfg testConfig, start time.Time, jobs chan<- job, active *atomic.Int64) {
+	tick := time.NewTicker(stageTick)
+	defer tick.Stop()
+	for sent := 0; cfg.totalRequests == 0 || sent < cfg.totalRequests; {
+		level, idx, done := levelAt(cfg.stages, time.Since(start))
+		if done {
+			return
+		}
+		active.Store(int64(math.Round(level)))
+		select {
+		case jobs <- job{stage: idx}:
+			sent++
+		case <-tick.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
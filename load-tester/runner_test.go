@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingServer answers 200 after delay and counts the requests it saw.
+func countingServer(t *testing.T, delay time.Duration) (*httptest.Server, *atomic.Int64) {
+	t.Helper()
+	var hits atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		time.Sleep(delay)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &hits
+}
+
+// requestJourney is the one-step journey of a command-line test.
+func requestJourney(spec *requestSpec) []journey {
+	return []journey{{name: "request", weight: 1, steps: []step{{name: "request", request: spec}}}}
+}
+
+func testRunConfig(t *testing.T, url string) testConfig {
+	t.Helper()
+	spec, err := newRequestSpec("GET", url, nil, "", false, nil)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	return testConfig{
+		journeys:       requestJourney(spec),
+		concurrency:    4,
+		requestTimeout: 5 * time.Second,
+		keepAliveIdle:  30 * time.Second,
+		maxIdleConns:   16,
+	}
+}
+
+// runTest runs cfg locally, like the command line without --agents.
+func runTest(cfg testConfig, raw *rawLog) (time.Duration, *testStats) {
+	stats := newTestStats(len(cfg.stages), cfg.journeys)
+	return runLoadTest(context.Background(), cfg, stats, raw), stats
+}
+
+func TestRunLoadTest_ClosedSendsEveryRequest(t *testing.T) {
+	srv, hits := countingServer(t, 0)
+	cfg := testRunConfig(t, srv.URL)
+	cfg.totalRequests = 37
+
+	_, stats := runTest(cfg, nil)
+	if hits.Load() != 37 || stats.total != 37 || stats.success200 != 37 {
+		t.Fatalf("server saw %d, stats %d total %d ok; want 37", hits.Load(), stats.total, stats.success200)
+	}
+}
+
+func TestRunLoadTest_DurationBoundsClosedRun(t *testing.T) {
+	srv, hits := countingServer(t, 5*time.Millisecond)
+	cfg := testRunConfig(t, srv.URL)
+	cfg.duration = 200 * time.Millisecond
+
+	elapsed, stats := runTest(cfg, nil)
+	if elapsed < cfg.duration || elapsed > cfg.duration+time.Second {
+		t.Fatalf("run took %s, want about %s", elapsed, cfg.duration)
+	}
+	if stats.total == 0 || int64(stats.total) != hits.Load() {
+		t.Fatalf("stats %d, server %d", stats.total, hits.Load())
+	}
+}
+
+func TestRunLoadTest_OpenModelKeepsRate(t *testing.T) {
+	cases := []struct {
+		name     string
+		rate     float64
+		duration time.Duration
+		requests int
+		want     int
+	}{
+		// rate × duration arrivals, sent whether or not responses came back.
+		{"duration", 200, 500 * time.Millisecond, 0, 100},
+		{"requests first", 200, time.Minute, 30, 30},
+	}
+	for _, tc := range cases {
+		srv, hits := countingServer(t, 0)
+		cfg := testRunConfig(t, srv.URL)
+		cfg.rate, cfg.duration, cfg.totalRequests, cfg.concurrency = tc.rate, tc.duration, tc.requests, 32
+
+		elapsed, stats := runTest(cfg, nil)
+		if stats.total != tc.want || hits.Load() != int64(tc.want) {
+			t.Fatalf("%s: sent %d (server %d), want %d", tc.name, stats.total, hits.Load(), tc.want)
+		}
+		// The last arrival is scheduled at (want-1)/rate.
+		if earliest := time.Duration(float64(tc.want-1) / tc.rate * float64(time.Second)); elapsed < earliest {
+			t.Fatalf("%s: run took %s, faster than the schedule allows (%s)", tc.name, elapsed, earliest)
+		}
+	}
+}
+
+func TestRunLoadTest_OpenModelReportsLateSends(t *testing.T) {
+	// One worker and 30ms responses cannot keep up with 100 requests/s.
+	srv, _ := countingServer(t, 30*time.Millisecond)
+	cfg := testRunConfig(t, srv.URL)
+	cfg.rate, cfg.totalRequests, cfg.concurrency = 100, 10, 1
+
+	_, stats := runTest(cfg, nil)
+	if stats.total != 10 || stats.late == 0 || stats.maxSendDelay < 100*time.Millisecond {
+		t.Fatalf("expected late sends, got total %d late %d max delay %s", stats.total, stats.late, stats.maxSendDelay)
+	}
+	// Latency is measured from the intended time, so it includes the queueing.
+	if stats.latency.maxDuration() < stats.maxSendDelay {
+		t.Fatalf("max latency %s should include the send delay %s", stats.latency.maxDuration(), stats.maxSendDelay)
+	}
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// scenarioFile is the YAML (or JSON) scenario format:
+//
+//	model: open
+//	base_url: http://localhost:8080
+//	headers: {API_KEY: abc123}
+//	stages:
+//	  - {name: rampa, duration: 30s, target: 200}
+//	journeys:
+//	  - name: consulta
+//	    weight: 3
+//	    steps:
+//	      - name: token
+//	        method: POST
+//	        url: /token
+//	        assert: {status: 200, json: {$.data.active: "true"}, max_latency: 300ms}
+//	        extract: {token: $.data.token}
+//	        think: 500ms
+//	      - name: cep
+//	        url: /cep/{{.CEP}}
+//	        headers: {Authorization: "Bearer {{.Vars.token}}"}
+//	thresholds: ["p95<300ms", "error_rate<1%"]
+//
+// steps at the top level is shorthand for a single journey.
+type scenarioFile struct {
+	Model    string            `yaml:"model"`
+	BaseURL  string            `yaml:"base_url"`
+	Headers  map[string]string `yaml:"headers"`
+	Stages   []stageSpec       `yaml:"stages"`
+	Journeys []journeySpec     `yaml:"journeys"`
+	Steps    []stepSpec        `yaml:"steps"`
+	// Thresholds use the --threshold syntax, e.g. "p95<300ms".
+	Thresholds []string `yaml:"thresholds"`
+}
+
+type stageSpec struct {
+	Name     string  `yaml:"name"`
+	Duration string  `yaml:"duration"`
+	Target   float64 `yaml:"target"`
+}
+
+type journeySpec struct {
+	Name   string     `yaml:"name"`
+	Weight int        `yaml:"weight"`
+	Steps  []stepSpec `yaml:"steps"`
+}
+
+type stepSpec struct {
+	Name    string            `yaml:"name"`
+	Method  string            `yaml:"method"`
+	URL     string            `yaml:"url"`
+	Headers map[string]string `yaml:"headers"`
+	Body    *string           `yaml:"body"`
+	Think   string            `yaml:"think"`
+	Assert  assertSpec        `yaml:"assert"`
+	Extract map[string]string `yaml:"extract"`
+}
+
+type assertSpec struct {
+	Status       intList           `yaml:"status"`
+	BodyContains string            `yaml:"body_contains"`
+	JSON         map[string]string `yaml:"json"`
+	MaxLatency   string            `yaml:"max_latency"`
+}
+
+// intList accepts either a single number or a list of numbers.
+type intList []int
+
+func (l *intList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		var v int
+		if err := node.Decode(&v); err != nil {
+			return err
+		}
+		*l = intList{v}
+		return nil
+	}
+	var vs []int
+	if err := node.Decode(&vs); err != nil {
+		return err
+	}
+	*l = vs
+	return nil
+}
+
+func loadScenario(path string) (*scenarioFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("não foi possível ler o cenário: %v", err)
+	}
+	var f scenarioFile
+	// JSON is valid YAML, so one decoder reads both formats. Unknown keys are
+	// errors: a misspelled assert or extract would otherwise silently drop a check.
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&f); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("cenário inválido: %v", err)
+	}
+	if len(f.Journeys) > 0 && len(f.Steps) > 0 {
+		return nil, fmt.Errorf("cenário deve usar journeys ou steps, não ambos")
+	}
+	if len(f.Steps) > 0 {
+		f.Journeys = []journeySpec{{Steps: f.Steps}}
+	}
+	return &f, nil
+}
+
+func (f *scenarioFile) stages() ([]stage, error) {
+	stages := make([]stage, 0, len(f.Stages))
+	for _, s := range f.Stages {
+		st, err := newStage(s.Name, s.Duration, strconv.FormatFloat(s.Target, 'f', -1, 64))
+		if err != nil {
+			return nil, err
+		}
+		stages = append(stages, st)
+	}
+	return stages, nil
+}
+
+// journeys builds the scenario journeys. baseURL, when not empty, replaces the
+// file's base_url; headers from the command line win over those in the file.
+func (f *scenarioFile) journeys(baseURL string, headers []string, rows []map[string]string) ([]journey, error) {
+	if baseURL == "" {
+		baseURL = f.BaseURL
+	}
+	journeys := make([]journey, 0, len(f.Journeys))
+	for ji, js := range f.Journeys {
+		jr := journey{name: js.Name, weight: js.Weight}
+		if jr.name == "" {
+			jr.name = fmt.Sprintf("jornada %d", ji+1)
+		}
+		if jr.weight == 0 {
+			jr.weight = 1
+		}
+		if jr.weight < 0 {
+			return nil, fmt.Errorf("%s: weight deve ser > 0", jr.name)
+		}
+		if len(js.Steps) == 0 {
+			return nil, fmt.Errorf("%s: nenhum passo definido", jr.name)
+		}
+		var vars []string
+		for si, ss := range js.Steps {
+			st, err := f.step(ss, si, baseURL, headers, rows)
+			if err != nil {
+				return nil, fmt.Errorf("%s/%s: %v", jr.name, stepName(ss, si), err)
+			}
+			if err := st.request.validate(vars); err != nil {
+				return nil, fmt.Errorf("%s/%s: %v", jr.name, st.name, err)
+			}
+			for _, x := range st.extract {
+				vars = append(vars, x.name)
+			}
+			jr.steps = append(jr.steps, st)
+		}
+		journeys = append(journeys, jr)
+	}
+	return journeys, nil
+}
+
+func (a assertSpec) checks() (checks, error) {
+	c := checks{status: a.Status, bodyContains: a.BodyContains}
+	paths := make([]string, 0, len(a.JSON))
+	for p := range a.JSON {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		path, err := parseJSONPath(p)
+		if err != nil {
+			return checks{}, err
+		}
+		c.json = append(c.json, jsonCheck{path: path, want: a.JSON[p]})
+	}
+	if a.MaxLatency != "" {
+		d, err := time.ParseDuration(a.MaxLatency)
+		if err != nil || d <= 0 {
+			return checks{}, fmt.Errorf("max_latency inválido: %q", a.MaxLatency)
+		}
+		c.maxLatency = d
+	}
+	return c, nil
+}
+
+func stepName(ss stepSpec, i int) string {
+	if ss.Name != "" {
+		return ss.Name
+	}
+	return fmt.Sprintf("passo %d", i+1)
+}
+
+func (f *scenarioFile) step(ss stepSpec, i int, baseURL string, headers []string, rows []map[string]string) (step, error) {
+	st := step{name: stepName(ss, i)}
+	if ss.URL == "" {
+		return step{}, fmt.Errorf("url é obrigatória")
+	}
+	c, err := ss.Assert.checks()
+	if err != nil {
+		return step{}, err
+	}
+	st.checks = c
+	url := ss.URL
+	if strings.HasPrefix(url, "/") {
+		if baseURL == "" {
+			return step{}, fmt.Errorf("url relativa %q requer base_url ou --url", url)
+		}
+		url = strings.TrimSuffix(baseURL, "/") + url
+	}
+	if ss.Think != "" {
+		d, err := time.ParseDuration(ss.Think)
+		if err != nil || d < 0 {
+			return step{}, fmt.Errorf("think inválido: %q", ss.Think)
+		}
+		st.think = d
+	}
+	body := ""
+	if ss.Body != nil {
+		body = *ss.Body
+	}
+	req, err := newRequestSpec(ss.Method, url, mergeHeaders(f.Headers, ss.Headers, headers), body, ss.Body != nil, rows)
+	if err != nil {
+		return step{}, err
+	}
+	st.request = req
+	names := make([]string, 0, len(ss.Extract))
+	for name := range ss.Extract {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		path, err := parseJSONPath(ss.Extract[name])
+		if err != nil {
+			return step{}, err
+		}
+		st.extract = append(st.extract, extraction{name: name, path: path})
+	}
+	return st, nil
+}
+
+// mergeHeaders combines file-wide, step and command-line headers, later ones
+// replacing earlier ones with the same name, as "Nome: valor" lines.
+func mergeHeaders(global, step map[string]string, flags []string) []string {
+	merged := make(map[string]string)
+	for _, m := range []map[string]string{global, step} {
+		for k, v := range m {
+			merged[http.CanonicalHeaderKey(k)] = k + ": " + v
+		}
+	}
+	for _, h := range flags {
+		name, _, _ := strings.Cut(h, ":")
+		merged[http.CanonicalHeaderKey(strings.TrimSpace(name))] = h
+	}
+	keys := make([]string, 0, len(merged))
+	for k := range merged {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	out := make([]string, len(keys))
+	for i, k := range keys {
+		out[i] = merged[k]
+	}
+	return out
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeScenario(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "scenario.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	return path
+}
+
+func TestLoadScenario(t *testing.T) {
+	f, err := loadScenario(writeScenario(t, `
+base_url: http://localhost:8080
+stages:
+  - {duration: 10s, target: 50}
+steps:
+  - name: token
+    method: POST
+    url: /token
+    assert: {status: [200, 201], json: {$.data.active: "true"}, max_latency: 300ms}
+    extract: {token: $.data.token}
+    think: 100ms
+  - url: "/cep/{{.Vars.token}}"
+`))
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	journeys, err := f.journeys("", nil, nil)
+	if err != nil {
+		t.Fatalf("journeys: %v", err)
+	}
+	if len(journeys) != 1 || len(journeys[0].steps) != 2 {
+		t.Fatalf("unexpected journeys %+v", journeys)
+	}
+	st := journeys[0].steps[0]
+	if len(st.checks.status) != 2 || len(st.checks.json) != 1 || st.checks.maxLatency != 300*time.Millisecond ||
+		len(st.extract) != 1 || st.think != 100*time.Millisecond {
+		t.Fatalf("unexpected first step %+v", st)
+	}
+	if journeys[0].steps[1].name != "passo 2" {
+		t.Fatalf("unnamed step should be numbered, got %q", journeys[0].steps[1].name)
+	}
+}
+
+func TestLoadScenario_Invalid(t *testing.T) {
+	cases := map[string]string{
+		// A misspelled key must not silently drop the check.
+		"unknown step key":   "steps:\n  - url: /\n    asert: {status: 200}\n",
+		"unknown assert key": "steps:\n  - url: /\n    assert: {stauts: 200}\n",
+		"unknown top key":    "stage:\n  - {duration: 1s, target: 1}\n",
+		"journeys and steps": "journeys:\n  - steps: [{url: /}]\nsteps:\n  - url: /\n",
+		"not a mapping":      "- url: /\n",
+		"bad status in list": "steps:\n  - url: /\n    assert: {status: [ok]}\n",
+	}
+	for name, content := range cases {
+		if _, err := loadScenario(writeScenario(t, content)); err == nil {
+			t.Fatalf("%s: expected error", name)
+		} else if !strings.HasPrefix(err.Error(), "cenário") {
+			t.Fatalf("%s: unexpected error %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	modelOpen   = "open"
+	modelClosed = "closed"
+)
+
+// stageTick is how often closed-model stages re-evaluate the worker count.
+const stageTick = 100 * time.Millisecond
+
+// stage moves the load linearly from the previous stage's target (0 for the
+// first) to target over duration. In the open model target is an arrival rate in
+// requests/s; in the closed model it is a number of concurrent workers.
+type stage struct {
+	name     string
+	duration time.Duration
+	target   float64
+}
+
+func (s stage) label(i int) string {
+	if s.name != "" {
+		return s.name
+	}
+	return fmt.Sprintf("estágio %d", i+1)
+}
+
+// parseStages reads the --stages syntax: "30s:200,2m:200,10s:1000,30s:0".
+func parseStages(spec string) ([]stage, error) {
+	var stages []stage
+	for _, part := range strings.Split(spec, ",") {
+		d, t, ok := strings.Cut(strings.TrimSpace(part), ":")
+		if !ok {
+			return nil, fmt.Errorf("estágio %q deve ter o formato duração:alvo", part)
+		}
+		st, err := newStage("", d, t)
+		if err != nil {
+			return nil, err
+		}
+		stages = append(stages, st)
+	}
+	return stages, nil
+}
+
+func newStage(name, duration, target string) (stage, error) {
+	d, err := time.ParseDuration(strings.TrimSpace(duration))
+	if err != nil || d <= 0 {
+		return stage{}, fmt.Errorf("duração de estágio inválida: %q", duration)
+	}
+	t, err := strconv.ParseFloat(strings.TrimSpace(target), 64)
+	if err != nil || t < 0 || math.IsInf(t, 0) {
+		return stage{}, fmt.Errorf("alvo de estágio inválido: %q", target)
+	}
+	return stage{name: name, duration: d, target: t}, nil
+}
+
+func totalDuration(stages []stage) time.Duration {
+	var d time.Duration
+	for _, s := range stages {
+		d += s.duration
+	}
+	return d
+}
+
+func peakTarget(stages []stage) float64 {
+	var peak float64
+	for _, s := range stages {
+		peak = max(peak, s.target)
+	}
+	return peak
+}
+
+// levelAt returns the interpolated target and the stage running at elapsed;
+// done is true once every stage has finished.
+func levelAt(stages []stage, elapsed time.Duration) (level float64, idx int, done bool) {
+	var from float64
+	for i, s := range stages {
+		if elapsed < s.duration {
+			frac := float64(elapsed) / float64(s.duration)
+			return from + (s.target-from)*frac, i, false
+		}
+		elapsed -= s.duration
+		from = s.target
+	}
+	return from, len(stages) - 1, true
+}
+
+// arrivalOffset returns how many seconds into a stage ramping linearly from r0 to
+// r1 requests/s over d seconds the cumulative arrivals reach a, solving
+// a = r0·t + (r1-r0)·t²/(2d). ok is false when the stage ends first.
+func arrivalOffset(r0, r1, d, a float64) (t float64, ok bool) {
+	k := (r1 - r0) / (2 * d)
+	if math.Abs(k) < 1e-12 {
+		if r0 <= 0 {
+			return 0, false
+		}
+		t = a / r0
+	} else {
+		disc := r0*r0 + 4*k*a
+		if disc < 0 {
+			return 0, false
+		}
+		t = (-r0 + math.Sqrt(disc)) / (2 * k)
+	}
+	return t, t >= 0 && t <= d
+}
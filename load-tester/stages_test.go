@@ -0,0 +1,103 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestParseStages(t *testing.T) {
+	stages, err := parseStages("30s:200, 2m:200,10s:1000,30s:0")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	want := []stage{
+		{duration: 30 * time.Second, target: 200},
+		{duration: 2 * time.Minute, target: 200},
+		{duration: 10 * time.Second, target: 1000},
+		{duration: 30 * time.Second, target: 0},
+	}
+	if len(stages) != len(want) {
+		t.Fatalf("got %d stages, want %d", len(stages), len(want))
+	}
+	for i := range want {
+		if stages[i] != want[i] {
+			t.Fatalf("stage %d = %+v, want %+v", i, stages[i], want[i])
+		}
+	}
+	if totalDuration(stages) != 3*time.Minute+10*time.Second || peakTarget(stages) != 1000 {
+		t.Fatalf("total %s, peak %v", totalDuration(stages), peakTarget(stages))
+	}
+}
+
+func TestParseStages_Invalid(t *testing.T) {
+	cases := map[string]string{
+		"no target":       "30s",
+		"bad duration":    "30x:10",
+		"zero duration":   "0s:10",
+		"negative target": "30s:-1",
+		"bad target":      "30s:abc",
+		"infinite target": "30s:Inf",
+		"empty stage":     "30s:10,",
+	}
+	for name, spec := range cases {
+		if _, err := parseStages(spec); err == nil {
+			t.Fatalf("%s: expected error for %q", name, spec)
+		}
+	}
+}
+
+func TestLevelAt(t *testing.T) {
+	stages := []stage{
+		{duration: 10 * time.Second, target: 100},
+		{duration: 10 * time.Second, target: 100},
+		{duration: 10 * time.Second, target: 0},
+	}
+	cases := []struct {
+		elapsed time.Duration
+		level   float64
+		idx     int
+		done    bool
+	}{
+		{0, 0, 0, false},
+		{5 * time.Second, 50, 0, false},
+		{10 * time.Second, 100, 1, false},
+		{15 * time.Second, 100, 1, false},
+		{25 * time.Second, 50, 2, false},
+		{30 * time.Second, 0, 2, true},
+		{time.Hour, 0, 2, true},
+	}
+	for _, tc := range cases {
+		level, idx, done := levelAt(stages, tc.elapsed)
+		if math.Abs(level-tc.level) > 1e-9 || idx != tc.idx || done != tc.done {
+			t.Fatalf("levelAt(%s) = %v, %d, %v; want %v, %d, %v", tc.elapsed, level, idx, done, tc.level, tc.idx, tc.done)
+		}
+	}
+}
+
+func TestArrivalOffset(t *testing.T) {
+	cases := []struct {
+		name      string
+		r0, r1, d float64
+		a         float64
+		want      float64
+		ok        bool
+	}{
+		{"constant", 10, 10, 5, 25, 2.5, true},
+		{"constant past end", 10, 10, 5, 51, 0, false},
+		{"idle", 0, 0, 5, 1, 0, false},
+		// 0 -> 10 req/s over 10s: a = t²/2, so 50 arrivals at t=10.
+		{"ramp up", 0, 10, 10, 8, 4, true},
+		{"ramp up end", 0, 10, 10, 50, 10, true},
+		{"ramp up past end", 0, 10, 10, 50.5, 0, false},
+		// 10 -> 0 req/s over 10s: a = 10t - t²/2.
+		{"ramp down", 10, 0, 10, 42, 6, true},
+		{"ramp down past end", 10, 0, 10, 51, 0, false},
+	}
+	for _, tc := range cases {
+		got, ok := arrivalOffset(tc.r0, tc.r1, tc.d, tc.a)
+		if ok != tc.ok || (ok && math.Abs(got-tc.want) > 1e-9) {
+			t.Fatalf("%s: arrivalOffset = %v, %v; want %v, %v", tc.name, got, ok, tc.want, tc.ok)
+		}
+	}
+}
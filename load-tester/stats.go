@@ -0,0 +1,209 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// result is the outcome of one request.
+type result struct {
+	stage   int // index in cfg.stages, -1 outside staged runs
+	journey int
+	step    int
+	status  int // 0 when the exchange failed
+	size    int64
+	latency time.Duration
+	err     *requestError
+	// check is the failed assertion or extraction of a received response.
+	check error
+}
+
+type testStats struct {
+	mu           sync.Mutex
+	total        int
+	success200   int
+	statusCounts map[int]int
+	latency      *histogram
+	byStatus     map[int]*histogram
+	// late and maxSendDelay describe how far the open model fell behind its
+	// schedule because no worker was free.
+	late         int
+	maxSendDelay time.Duration
+	stages       []*stageStats
+	errors       map[string]*errorStats
+	// checkFailures counts responses that failed a check or an extraction.
+	checkFailures int
+	// failed counts requests that erred, got a 5xx or failed a check, each once.
+	failed int
+	// window collects latencies since the last progress snapshot.
+	window *histogram
+	// steps and journeys are indexed like cfg.journeys.
+	steps    [][]*stepStats
+	journeys []*journeyStats
+}
+
+// stepStats is the slice of the results of one journey step.
+type stepStats struct {
+	total   int
+	passed  int
+	failed  int // response received but a check or extraction failed
+	errors  int // status 0
+	latency *histogram
+	// samples are distinct check failure messages.
+	samples []string
+}
+
+type journeyStats struct {
+	started   int
+	completed int
+}
+
+// errorStats counts one error category and keeps a few distinct messages.
+type errorStats struct {
+	count   int
+	samples []string
+}
+
+// stageStats is the slice of the results attributed to one stage.
+type stageStats struct {
+	total   int
+	success int // 2xx
+	errors  int // status 0
+	latency *histogram
+}
+
+// lateThreshold is how far behind schedule a send must start to count as late.
+const lateThreshold = 10 * time.Millisecond
+
+func newTestStats(stages int, journeys []journey) *testStats {
+	s := &testStats{
+		statusCounts: make(map[int]int),
+		latency:      newHistogram(),
+		window:       newHistogram(),
+		byStatus:     make(map[int]*histogram),
+		errors:       make(map[string]*errorStats),
+		stages:       make([]*stageStats, stages),
+		steps:        make([][]*stepStats, len(journeys)),
+		journeys:     make([]*journeyStats, len(journeys)),
+	}
+	for i := range s.stages {
+		s.stages[i] = &stageStats{latency: newHistogram()}
+	}
+	for i, j := range journeys {
+		s.journeys[i] = &journeyStats{}
+		s.steps[i] = make([]*stepStats, len(j.steps))
+		for k := range j.steps {
+			s.steps[i][k] = &stepStats{latency: newHistogram()}
+		}
+	}
+	return s
+}
+
+func (s *testStats) record(r result) {
+	stage, statusCode, latency, rerr := r.stage, r.status, r.latency, r.err
+	s.mu.Lock()
+	if rerr != nil {
+		e, ok := s.errors[rerr.kind]
+		if !ok {
+			e = &errorStats{}
+			s.errors[rerr.kind] = e
+		}
+		e.count++
+		if msg := rerr.Error(); len(e.samples) < maxErrorSamples && !slices.Contains(e.samples, msg) {
+			e.samples = append(e.samples, msg)
+		}
+	}
+	s.total++
+	if statusCode == http.StatusOK {
+		s.success200++
+	}
+	s.statusCounts[statusCode] = s.statusCounts[statusCode] + 1
+	if rerr != nil || statusCode >= 500 || r.check != nil {
+		s.failed++
+	}
+	s.latency.record(latency)
+	s.window.record(latency)
+	h, ok := s.byStatus[statusCode]
+	if !ok {
+		h = newHistogram()
+		s.byStatus[statusCode] = h
+	}
+	h.record(latency)
+	if stage >= 0 && stage < len(s.stages) {
+		st := s.stages[stage]
+		st.total++
+		switch {
+		case statusCode == 0:
+			st.errors++
+		case statusCode >= 200 && statusCode < 300:
+			st.success++
+		}
+		st.latency.record(latency)
+	}
+	step := s.steps[r.journey][r.step]
+	step.total++
+	step.latency.record(latency)
+	switch {
+	case rerr != nil:
+		step.errors++
+	case r.check != nil:
+		s.checkFailures++
+		step.failed++
+		if msg := r.check.Error(); len(step.samples) < maxErrorSamples && !slices.Contains(step.samples, msg) {
+			step.samples = append(step.samples, msg)
+		}
+	default:
+		step.passed++
+	}
+	s.mu.Unlock()
+}
+
+func (s *testStats) recordJourney(journey int, completed bool) {
+	s.mu.Lock()
+	s.journeys[journey].started++
+	if completed {
+		s.journeys[journey].completed++
+	}
+	s.mu.Unlock()
+}
+
+func (s *testStats) recordSendDelay(delay time.Duration) {
+	s.mu.Lock()
+	if delay > lateThreshold {
+		s.late++
+	}
+	s.maxSendDelay = max(s.maxSendDelay, delay)
+	s.mu.Unlock()
+}
+
+// progressSnapshot is what the live progress view shows at one tick.
+type progressSnapshot struct {
+	total        int
+	iterations   int // journeys started
+	failed       int // transport errors, 5xx and failed checks
+	statusCounts map[int]int
+	// window holds the latencies recorded since the previous snapshot.
+	window *histogram
+}
+
+// snapshot copies the running totals and hands over the current window.
+func (s *testStats) snapshot() progressSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snap := progressSnapshot{
+		total:        s.total,
+		failed:       s.failed,
+		statusCounts: make(map[int]int, len(s.statusCounts)),
+		window:       s.window,
+	}
+	for code, n := range s.statusCounts {
+		snap.statusCounts[code] = n
+	}
+	for _, j := range s.journeys {
+		snap.iterations += j.started
+	}
+	s.window = newHistogram()
+	return snap
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// exitThresholdFailed is the exit code when any threshold is breached.
+const exitThresholdFailed = 3
+
+// threshold is a pass/fail condition on the whole run, e.g. p95<300ms.
+//
+// Metrics: pNN (any percentile), mean and max take durations ("300ms", or a bare
+// number of milliseconds); error_rate (requests with a transport error, a 5xx
+// response or a failed check, over all requests) and check_failure_rate take
+// "1%" or a fraction; rps takes requests per second.
+type threshold struct {
+	expr   string
+	metric string
+	op     string
+	// value is in milliseconds for latencies and a fraction for rates.
+	value float64
+}
+
+var thresholdExpr = regexp.MustCompile(`^\s*([a-z_]+|p[0-9.]+)\s*(<=|>=|<|>)\s*(\S+)\s*$`)
+
+func parseThreshold(expr string) (threshold, error) {
+	m := thresholdExpr.FindStringSubmatch(expr)
+	if m == nil {
+		return threshold{}, fmt.Errorf("limite %q deve ter o formato métrica<valor (ex: p95<300ms)", expr)
+	}
+	t := threshold{expr: strings.TrimSpace(expr), metric: m[1], op: m[2]}
+	var err error
+	switch {
+	case t.isLatency():
+		if q, ok := t.percentile(); ok && (q <= 0 || q > 100) {
+			return threshold{}, fmt.Errorf("limite %q: percentil deve estar entre 0 e 100", expr)
+		}
+		t.value, err = parseMillis(m[3])
+	case t.metric == "error_rate" || t.metric == "check_failure_rate":
+		t.value, err = parseRate(m[3])
+	case t.metric == "rps":
+		t.value, err = strconv.ParseFloat(m[3], 64)
+	default:
+		return threshold{}, fmt.Errorf("limite %q: métrica %q desconhecida", expr, t.metric)
+	}
+	if err != nil {
+		return threshold{}, fmt.Errorf("limite %q: valor inválido %q", expr, m[3])
+	}
+	return t, nil
+}
+
+func (t threshold) isLatency() bool {
+	_, ok := t.percentile()
+	return ok || t.metric == "mean" || t.metric == "max"
+}
+
+func (t threshold) percentile() (float64, bool) {
+	if !strings.HasPrefix(t.metric, "p") {
+		return 0, false
+	}
+	q, err := strconv.ParseFloat(t.metric[1:], 64)
+	return q, err == nil
+}
+
+func parseMillis(s string) (float64, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return millis(d), nil
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+func parseRate(s string) (float64, error) {
+	if pct, ok := strings.CutSuffix(s, "%"); ok {
+		v, err := strconv.ParseFloat(pct, 64)
+		return v / 100, err
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+// thresholdResult is a threshold evaluated against a finished run.
+type thresholdResult struct {
+	Expr   string  `json:"expr"`
+	Actual float64 `json:"actual"`
+	Passed bool    `json:"passed"`
+	// display is Actual formatted in the unit of the metric.
+	display string
+}
+
+func evaluateThresholds(thresholds []threshold, elapsed time.Duration, stats *testStats) []thresholdResult {
+	results := make([]thresholdResult, 0, len(thresholds))
+	for _, t := range thresholds {
+		actual, display := t.measure(elapsed, stats)
+		results = append(results, thresholdResult{Expr: t.expr, Actual: actual, Passed: t.holds(actual), display: display})
+	}
+	return results
+}
+
+func (t threshold) measure(elapsed time.Duration, stats *testStats) (float64, string) {
+	switch {
+	case t.metric == "mean":
+		return millis(stats.latency.mean()), fmtLatency(stats.latency.mean())
+	case t.metric == "max":
+		return millis(stats.latency.maxDuration()), fmtLatency(stats.latency.maxDuration())
+	case t.metric == "error_rate":
+		r := rate(stats.failed, stats.total)
+		return r, fmt.Sprintf("%.2f%%", 100*r)
+	case t.metric == "check_failure_rate":
+		r := rate(stats.checkFailures, stats.total)
+		return r, fmt.Sprintf("%.2f%%", 100*r)
+	case t.metric == "rps":
+		r := 0.0
+		if elapsed > 0 {
+			r = float64(stats.total) / elapsed.Seconds()
+		}
+		return r, fmt.Sprintf("%.1f req/s", r)
+	default:
+		q, _ := t.percentile()
+		d := stats.latency.percentile(q)
+		return millis(d), fmtLatency(d)
+	}
+}
+
+func (t threshold) holds(actual float64) bool {
+	switch t.op {
+	case "<":
+		return actual < t.value
+	case "<=":
+		return actual <= t.value
+	case ">":
+		return actual > t.value
+	default:
+		return actual >= t.value
+	}
+}
+
+func rate(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total)
+}
+
+func thresholdsPassed(results []thresholdResult) bool {
+	for _, r := range results {
+		if !r.Passed {
+			return false
+		}
+	}
+	return true
+}
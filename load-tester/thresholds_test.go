@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseThreshold(t *testing.T) {
+	cases := []struct {
+		expr   string
+		metric string
+		op     string
+		value  float64
+	}{
+		{"p95<300ms", "p95", "<", 300},
+		{" p99.9 <= 1s ", "p99.9", "<=", 1000},
+		{"p50<250", "p50", "<", 250},
+		{"mean<50ms", "mean", "<", 50},
+		{"max<2s", "max", "<", 2000},
+		{"error_rate<1%", "error_rate", "<", 0.01},
+		{"error_rate<0.05", "error_rate", "<", 0.05},
+		{"check_failure_rate<=0.5%", "check_failure_rate", "<=", 0.005},
+		{"rps>100", "rps", ">", 100},
+		{"rps>=12.5", "rps", ">=", 12.5},
+	}
+	for _, tc := range cases {
+		th, err := parseThreshold(tc.expr)
+		if err != nil {
+			t.Fatalf("%q: unexpected err: %v", tc.expr, err)
+		}
+		if th.metric != tc.metric || th.op != tc.op || th.value != tc.value {
+			t.Fatalf("%q: got %s %s %v, want %s %s %v", tc.expr, th.metric, th.op, th.value, tc.metric, tc.op, tc.value)
+		}
+	}
+}
+
+func TestParseThreshold_Invalid(t *testing.T) {
+	for _, expr := range []string{"", "p95", "p95=300ms", "p0<1s", "p101<1s", "p95<fast", "latency<1s", "error_rate<lots", "rps>many"} {
+		if _, err := parseThreshold(expr); err == nil {
+			t.Fatalf("%q: expected error", expr)
+		}
+	}
+}
+
+func TestEvaluateThresholds(t *testing.T) {
+	stats := newTestStats(0, oneStep)
+	// 100 requests of 1..100ms: 96 OK, 2 transport errors, 1 server error and
+	// 1 response that failed a check.
+	for i := 1; i <= 100; i++ {
+		r := result{status: 200, latency: time.Duration(i) * time.Millisecond}
+		switch i {
+		case 10, 20:
+			r.status, r.err = 0, &requestError{kind: errReset, err: errors.New("reset")}
+		case 30:
+			r.status = 503
+		case 40:
+			r.check = errors.New("corpo não contém \"ok\"")
+		}
+		stats.record(r)
+	}
+
+	cases := []struct {
+		expr   string
+		passed bool
+	}{
+		{"p50<=51ms", true},
+		{"p50<40ms", false},
+		{"max<100ms", false},
+		{"max<=101ms", true},
+		{"mean<60ms", true},
+		// Errors, 5xx and failed checks all count: 4 of 100.
+		{"error_rate<5%", true},
+		{"error_rate<4%", false},
+		{"check_failure_rate<=1%", true},
+		{"check_failure_rate<1%", false},
+		// 100 requests in 2s.
+		{"rps>=50", true},
+		{"rps>50", false},
+	}
+	var thresholds []threshold
+	for _, tc := range cases {
+		th, err := parseThreshold(tc.expr)
+		if err != nil {
+			t.Fatalf("%q: %v", tc.expr, err)
+		}
+		thresholds = append(thresholds, th)
+	}
+	results := evaluateThresholds(thresholds, 2*time.Second, stats)
+	for i, tc := range cases {
+		if results[i].Passed != tc.passed {
+			t.Fatalf("%q: passed = %v (actual %v), want %v", tc.expr, results[i].Passed, results[i].Actual, tc.passed)
+		}
+	}
+	if thresholdsPassed(results) {
+		t.Fatalf("run with failed thresholds should not pass")
+	}
+	if !thresholdsPassed(nil) {
+		t.Fatalf("a run without thresholds passes")
+	}
+}
+
+func TestErrorRateCountsServerErrors(t *testing.T) {
+	stats := newTestStats(0, oneStep)
+	for i := 0; i < 10; i++ {
+		stats.record(result{status: 500, latency: time.Millisecond})
+	}
+	// A 5xx that also failed a status check is one failed request, not two.
+	stats.record(result{status: 502, latency: time.Millisecond, check: errors.New("status 502, esperado [200]")})
+
+	th, err := parseThreshold("error_rate<1%")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	res := evaluateThresholds([]threshold{th}, time.Second, stats)[0]
+	if res.Passed || res.Actual != 1 {
+		t.Fatalf("all-5xx run should fail error_rate with rate 1, got %+v", res)
+	}
+}
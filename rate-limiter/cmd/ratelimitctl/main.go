@@ -0,0 +1,198 @@
+// Command ratelimitctl inspects and manages rate limiter state in the configured
+// store, validates rules files and simulates request sequences offline.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"rate-limiter/pkg/config"
+	"rate-limiter/pkg/limiter"
+	redispkg "rate-limiter/pkg/storage/redis"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+const usage = `usage: ratelimitctl <command> [flags] [args]
+
+commands:
+  blocked                         list blocked identifiers
+  counters [-aggregate] <id>      show live counters of an identifier (or aggregate key)
+  block <id> <duration>           block an identifier, e.g. "block ip:10.0.0.1 5m"
+  unblock <id>                    lift the block of an identifier
+  validate <rules.json>           check a rules file without connecting to the store
+  simulate [flags]                replay requests against the resolved rule in memory
+
+Identifiers look like "ip:<address>" or "token:<token>", prefixed with
+"tenant:<name>:" for tenants. Store commands read REDIS_ADDR, REDIS_DB,
+REDIS_PASSWORD and RATE_LIMIT_NAMESPACE like the server does.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	err := run(context.Background(), os.Args[1], os.Args[2:], os.Stdout)
+	var uerr usageError
+	switch {
+	case err == nil:
+	case errors.Is(err, flag.ErrHelp):
+		os.Exit(2)
+	case errors.As(err, &uerr):
+		fmt.Fprintf(os.Stderr, "ratelimitctl: %v\n\n%s", err, usage)
+		os.Exit(2)
+	default:
+		fmt.Fprintf(os.Stderr, "ratelimitctl: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+type usageError string
+
+func (e usageError) Error() string { return string(e) }
+
+func run(ctx context.Context, cmd string, args []string, out io.Writer) error {
+	switch cmd {
+	case "blocked":
+		return withStore(ctx, func(s *redispkg.Store) error { return listBlocked(ctx, s, out) })
+	case "counters":
+		fs := flag.NewFlagSet("counters", flag.ContinueOnError)
+		aggregate := fs.Bool("aggregate", false, "treat the argument as an aggregate key (e.g. global, route:<name>)")
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		if fs.NArg() != 1 {
+			return usageError("counters takes exactly one identifier")
+		}
+		prefix := limiter.CounterPrefix(fs.Arg(0))
+		if *aggregate {
+			prefix = limiter.AggregatePrefix(fs.Arg(0))
+		}
+		return withStore(ctx, func(s *redispkg.Store) error { return showCounters(ctx, s, fs.Arg(0), prefix, out) })
+	case "block":
+		if len(args) != 2 {
+			return usageError("block takes an identifier and a duration")
+		}
+		d, err := time.ParseDuration(args[1])
+		if err != nil || d <= 0 {
+			return usageError(fmt.Sprintf("invalid duration: %s", args[1]))
+		}
+		return withStore(ctx, func(s *redispkg.Store) error {
+			if err := s.SetBlock(ctx, args[0], d); err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "blocked %s for %s\n", args[0], d)
+			return nil
+		})
+	case "unblock":
+		if len(args) != 1 {
+			return usageError("unblock takes exactly one identifier")
+		}
+		return withStore(ctx, func(s *redispkg.Store) error {
+			ok, err := s.Unblock(ctx, args[0])
+			if err != nil {
+				return err
+			}
+			if !ok {
+				fmt.Fprintf(out, "%s was not blocked\n", args[0])
+				return nil
+			}
+			fmt.Fprintf(out, "unblocked %s\n", args[0])
+			return nil
+		})
+	case "validate":
+		if len(args) != 1 {
+			return usageError("validate takes exactly one rules file")
+		}
+		return validate(args[0], out)
+	case "simulate":
+		return simulate(args, out)
+	case "help", "-h", "--help":
+		fmt.Fprint(out, usage)
+		return nil
+	default:
+		return usageError(fmt.Sprintf("unknown command %q", cmd))
+	}
+}
+
+// withStore connects to the Redis configured in the environment and runs fn.
+func withStore(ctx context.Context, fn func(*redispkg.Store) error) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	rdb := goredis.NewClient(&goredis.Options{
+		Addr:     cfg.RedisAddr,
+		Password: cfg.RedisPassword,
+		DB:       cfg.RedisDB,
+	})
+	defer rdb.Close()
+
+	pingCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	if err := rdb.Ping(pingCtx).Err(); err != nil {
+		return fmt.Errorf("failed to connect to redis at %s: %w", cfg.RedisAddr, err)
+	}
+	return fn(redispkg.New(rdb, redispkg.WithNamespace(cfg.Namespace)))
+}
+
+func listBlocked(ctx context.Context, s *redispkg.Store, out io.Writer) error {
+	blocked, err := s.Blocked(ctx)
+	if err != nil {
+		return err
+	}
+	if len(blocked) == 0 {
+		fmt.Fprintln(out, "no blocked identifiers")
+		return nil
+	}
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "IDENTIFIER\tREMAINING")
+	for _, b := range blocked {
+		fmt.Fprintf(tw, "%s\t%s\n", b.Identifier, b.TTL.Round(time.Second))
+	}
+	return tw.Flush()
+}
+
+func showCounters(ctx context.Context, s *redispkg.Store, id, prefix string, out io.Writer) error {
+	blocked, ttl, err := s.IsBlocked(ctx, id)
+	if err != nil {
+		return err
+	}
+	if blocked {
+		fmt.Fprintf(out, "%s is blocked for %s\n", id, ttl.Round(time.Second))
+	}
+	counters, err := s.Counters(ctx, prefix)
+	if err != nil {
+		return err
+	}
+	if len(counters) == 0 {
+		fmt.Fprintf(out, "no live counters for %s\n", id)
+		return nil
+	}
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tVALUE\tTTL")
+	for _, c := range counters {
+		fmt.Fprintf(tw, "%s\t%d\t%s\n", c.Key, c.Value, c.TTL)
+	}
+	return tw.Flush()
+}
+
+func validate(path string, out io.Writer) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	rules, err := config.ParseRules(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	fmt.Fprintf(out, "%s: ok (%d routes)\n", path, len(rules.Routes))
+	return nil
+}
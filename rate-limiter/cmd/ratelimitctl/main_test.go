@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSimulate_ShowsDeniedRequests(t *testing.T) {
+	var out bytes.Buffer
+	args := []string{"-rps", "2", "-block", "1s", "-n", "5", "-interval", "100ms"}
+	if err := run(context.Background(), "simulate", args, &out); err != nil {
+		t.Fatalf("simulate: %v", err)
+	}
+	if !strings.Contains(out.String(), "2 allowed, 3 denied") {
+		t.Fatalf("unexpected output:\n%s", out.String())
+	}
+}
+
+func TestValidate(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.json")
+	bad := filepath.Join(dir, "bad.json")
+	_ = os.WriteFile(good, []byte(`{"routes":[{"path":"/export","cost":5}]}`), 0o600)
+	_ = os.WriteFile(bad, []byte(`{"routes":[{"path":"export"}]}`), 0o600)
+
+	var out bytes.Buffer
+	if err := run(context.Background(), "validate", []string{good}, &out); err != nil {
+		t.Fatalf("expected valid rules, got %v", err)
+	}
+	if err := run(context.Background(), "validate", []string{bad}, &out); err == nil {
+		t.Fatalf("expected invalid rules to fail")
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"rate-limiter/pkg/config"
+	"rate-limiter/pkg/limiter"
+	"rate-limiter/pkg/ratelimit"
+	"rate-limiter/pkg/storage/memory"
+)
+
+// simulate resolves the rule for one client and path from the configuration (plus
+// an optional rules file) and replays requests on a virtual clock against an
+// in-memory store, printing which would be denied. Quotas are not simulated.
+func simulate(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("simulate", flag.ContinueOnError)
+	var (
+		rulesFile = fs.String("rules", "", "rules file to load instead of RATE_LIMIT_RULES_FILE")
+		token     = fs.String("token", "", "API token sent by the client")
+		ip        = fs.String("ip", "127.0.0.1", "client IP")
+		path      = fs.String("path", "/", "request path")
+		tenant    = fs.String("tenant", "", "tenant whose rule set applies")
+		cost      = fs.Int64("cost", 0, "cost per request (0 uses the route cost)")
+		rps       = fs.Int64("rps", 0, "override the resolved limit per second")
+		block     = fs.Duration("block", 0, "override the resolved block duration")
+		n         = fs.Int("n", 20, "number of requests, evenly spaced by -interval")
+		interval  = fs.Duration("interval", 50*time.Millisecond, "time between requests")
+		at        = fs.String("at", "", "comma-separated offsets from the start (e.g. 0s,100ms,1.5s); overrides -n and -interval")
+	)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return usageError("simulate takes no arguments")
+	}
+
+	offsets, err := simulationOffsets(*at, *n, *interval)
+	if err != nil {
+		return err
+	}
+	if *rulesFile != "" {
+		if err := os.Setenv("RATE_LIMIT_RULES_FILE", *rulesFile); err != nil {
+			return err
+		}
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	if *tenant != "" && !hasTenant(cfg, *tenant) {
+		return usageError(fmt.Sprintf("unknown tenant %q", *tenant))
+	}
+	engine := ratelimit.NewEngine(nil, cfg)
+	rule := engine.Resolve(ratelimit.Request{Token: *token, IP: *ip, Path: *path, Cost: *cost, Tenant: *tenant})
+	if *rps > 0 {
+		rule.LimitPerSecond = *rps
+	}
+	if *block > 0 {
+		rule.BlockFor = *block
+	}
+
+	fmt.Fprintf(out, "rule %s: identifier=%s limit=%d/s block=%s cost=%d", rule.Key(), rule.Identifier, rule.LimitPerSecond, rule.BlockFor, rule.Cost)
+	for _, agg := range rule.Aggregates {
+		fmt.Fprintf(out, " aggregate[%s]=%d/s", agg.Key, agg.LimitPerSecond)
+	}
+	fmt.Fprintln(out)
+
+	// Start on a second boundary so windows line up with the printed offsets.
+	start := time.Now().Truncate(time.Second)
+	now := start
+	lim := limiter.New(memory.New(func() time.Time { return now }))
+
+	ctx := context.Background()
+	denied := 0
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "#\tAT\tRESULT\tRETRY AFTER\tLIMITED BY")
+	for i, off := range offsets {
+		now = start.Add(off)
+		res, err := lim.Check(ctx, rule.Identifier, rule.LimitPerSecond, rule.Cost, rule.BlockFor, now, rule.Aggregates...)
+		if err != nil {
+			return err
+		}
+		result, retry, by := "allowed", "-", "-"
+		if !res.Allowed {
+			denied++
+			result, retry, by = "DENIED", res.RetryAfter.Round(time.Millisecond).String(), "identifier"
+			if res.Aggregate != "" {
+				by = res.Aggregate
+			}
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n", i+1, off, result, retry, by)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "%d allowed, %d denied\n", len(offsets)-denied, denied)
+	return nil
+}
+
+func simulationOffsets(at string, n int, interval time.Duration) ([]time.Duration, error) {
+	if strings.TrimSpace(at) == "" {
+		if n < 1 || interval < 0 {
+			return nil, usageError("-n must be positive and -interval not negative")
+		}
+		out := make([]time.Duration, n)
+		for i := range out {
+			out[i] = time.Duration(i) * interval
+		}
+		return out, nil
+	}
+	var out []time.Duration
+	for _, p := range strings.Split(at, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		d, err := time.ParseDuration(p)
+		if err != nil || d < 0 {
+			return nil, usageError(fmt.Sprintf("invalid -at offset: %s", p))
+		}
+		if len(out) > 0 && d < out[len(out)-1] {
+			return nil, usageError("-at offsets must be in increasing order")
+		}
+		out = append(out, d)
+	}
+	return out, nil
+}
+
+func hasTenant(cfg *config.Config, name string) bool {
+	for _, t := range cfg.Tenants {
+		if t.Name == name {
+			return true
+		}
+	}
+	return false
+}
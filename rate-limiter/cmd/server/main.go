@@ -3,15 +3,31 @@ package main
 import (
 	"context"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
+	_ "time/tzdata" // quota timezones must resolve in minimal images
 
-	"rate-limiter/internal/config"
-	"rate-limiter/internal/limiter"
-	"rate-limiter/internal/middleware"
-	redispkg "rate-limiter/internal/storage/redis"
+	"rate-limiter/pkg/adaptive"
+	"rate-limiter/pkg/config"
+	"rate-limiter/pkg/decision"
+	"rate-limiter/pkg/events"
+	"rate-limiter/pkg/health"
+	"rate-limiter/pkg/limiter"
+	"rate-limiter/pkg/middleware"
+	"rate-limiter/pkg/proxy"
+	"rate-limiter/pkg/quota"
+	"rate-limiter/pkg/ratelimit"
+	redispkg "rate-limiter/pkg/storage/redis"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	goredis "github.com/redis/go-redis/v9"
+	"google.golang.org/grpc"
 )
 
 func main() {
@@ -25,33 +41,227 @@ func main() {
 		Password: cfg.RedisPassword,
 		DB:       cfg.RedisDB,
 	})
+	defer rdb.Close()
 
 	if err := pingRedis(rdb); err != nil {
 		log.Fatalf("failed to connect to redis: %v", err)
 	}
 
-	store := redispkg.New(rdb)
-	lim := limiter.New(store)
-	rl := middleware.NewRateLimitMiddleware(lim, cfg)
+	store := redispkg.New(rdb, redispkg.WithNamespace(cfg.Namespace))
+	var limOpts []limiter.Option
+	if cfg.Clock == config.ClockStore {
+		limOpts = append(limOpts, limiter.WithStoreClock())
+	}
+	sink, webhook := eventSink(cfg, rdb, store)
+	if sink != nil {
+		limOpts = append(limOpts, limiter.WithEventSink(sink))
+	}
+	lim := limiter.New(store, limOpts...)
+	var (
+		opts    []ratelimit.Option
+		tracker *quota.Tracker
+	)
+	if cfg.Quota.Enabled() {
+		tracker, err = quota.NewTracker(store, cfg.Quota)
+		if err != nil {
+			log.Fatalf("failed to configure quotas: %v", err)
+		}
+		opts = append(opts, ratelimit.WithQuota(tracker))
+	}
+	var mwOpts []middleware.Option
+	if cfg.Adaptive.Enabled {
+		ctrl := adaptive.New(cfg)
+		if err := ctrl.Register(prometheus.DefaultRegisterer); err != nil {
+			log.Fatalf("failed to register adaptive metrics: %v", err)
+		}
+		opts = append(opts, ratelimit.WithLimitAdjuster(ctrl))
+		mwOpts = append(mwOpts, middleware.WithObserver(ctrl))
+	}
+	engine := ratelimit.NewEngine(lim, cfg, opts...)
+	probe := health.NewProbe(store)
+
+	var (
+		handler http.Handler
+		gs      *grpc.Server
+	)
+	switch cfg.ServerMode {
+	case config.ServerModeDecision:
+		handler, gs = decisionServers(engine)
+	case config.ServerModeProxy:
+		handler = proxyHandler(cfg, engine, mwOpts...)
+	default:
+		handler = demoHandler(engine, mwOpts...)
+	}
+
+	if tracker != nil && cfg.Quota.ReportPath != "" {
+		// The usage report reads Redis on every call, so it is rate limited like any
+		// other request, but without spending quota.
+		report := tracker.Handler(cfg.TokenHeader, func(r *http.Request) string {
+			return engine.TenantFor(r.Header.Get(cfg.TenantHeader), r.Host)
+		})
+		handler = mount(cfg.Quota.ReportPath, middleware.FromEngine(engine, middleware.WithoutQuota()).Handler(report), handler)
+	}
+	if cfg.MetricsPath != "" {
+		handler = mount(cfg.MetricsPath, promhttp.Handler(), handler)
+	}
+
+	srv := newHTTPServer(cfg, probe.Wrap(handler))
+	if cfg.ServerMode == config.ServerModeProxy {
+		// Streamed uploads and responses can outlive any fixed deadline. Slow
+		// clients are still bounded by ReadHeaderTimeout and upstream slowness by
+		// PROXY_RESPONSE_TIMEOUT_SECONDS.
+		srv.ReadTimeout, srv.WriteTimeout = 0, 0
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if cfg.SkewCheckSeconds > 0 {
+		skew := limiter.NewSkewDetector(store, time.Duration(cfg.SkewThresholdMillis)*time.Millisecond)
+		go skew.Run(ctx, time.Duration(cfg.SkewCheckSeconds)*time.Second)
+	}
+
+	errCh := make(chan error, 2)
+	go func() {
+		log.Printf("server listening on :%s (mode=%s)", cfg.Port, cfg.ServerMode)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			errCh <- err
+		}
+	}()
+	if gs != nil {
+		lis, err := net.Listen("tcp", ":"+cfg.GRPCPort)
+		if err != nil {
+			log.Fatalf("failed to listen on grpc port: %v", err)
+		}
+		go func() {
+			log.Printf("grpc listening on :%s", cfg.GRPCPort)
+			if err := gs.Serve(lis); err != nil {
+				errCh <- err
+			}
+		}()
+	}
+
+	select {
+	case <-ctx.Done():
+		log.Printf("shutdown signal received, draining in-flight requests")
+	case err := <-errCh:
+		log.Fatalf("server error: %v", err)
+	}
+
+	// Fail readiness first and keep serving while load balancers notice it.
+	probe.SetDraining()
+	if delay := time.Duration(cfg.ShutdownDelaySeconds) * time.Second; delay > 0 {
+		log.Printf("readiness failing, waiting %s before closing listeners", delay)
+		time.Sleep(delay)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.ShutdownTimeoutSeconds)*time.Second)
+	defer cancel()
+	var drained sync.WaitGroup
+	if gs != nil {
+		drained.Add(1)
+		go func() {
+			defer drained.Done()
+			stopped := make(chan struct{})
+			go func() {
+				gs.GracefulStop()
+				close(stopped)
+			}()
+			select {
+			case <-stopped:
+			case <-shutdownCtx.Done():
+				gs.Stop()
+			}
+		}()
+	}
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("graceful shutdown incomplete: %v", err)
+	}
+	drained.Wait()
+	if webhook != nil {
+		if err := webhook.Close(shutdownCtx); err != nil {
+			log.Printf("pending webhook events not delivered: %v", err)
+		}
+	}
+	log.Printf("server stopped")
+}
 
+func demoHandler(engine *ratelimit.Engine, opts ...middleware.Option) http.Handler {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
 		_, _ = w.Write([]byte(`{"status":"ok"}`))
 	})
+	return middleware.FromEngine(engine, opts...).Handler(mux)
+}
+
+// proxyHandler forwards requests that pass the rate limit to the configured upstreams.
+func proxyHandler(cfg *config.Config, engine *ratelimit.Engine, opts ...middleware.Option) http.Handler {
+	px, err := proxy.New(cfg.Proxy)
+	if err != nil {
+		log.Fatalf("failed to configure proxy: %v", err)
+	}
+	return middleware.FromEngine(engine, opts...).Handler(px)
+}
 
-	srv := &http.Server{
-		Addr:         ":" + cfg.Port,
-		Handler:      rl.Handler(mux),
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 10 * time.Second,
-		IdleTimeout:  60 * time.Second,
+// decisionServers builds the decision API: HTTP POST /check on PORT and Envoy's
+// rate limit service over gRPC on GRPC_PORT. Nothing here is rate limited itself.
+func decisionServers(engine *ratelimit.Engine) (http.Handler, *grpc.Server) {
+	svc := decision.NewService(engine)
+	gs := grpc.NewServer()
+	decision.NewEnvoyServer(svc).Register(gs)
+	return svc.Handler(), gs
+}
+
+// eventSink builds the configured block event sinks; the webhook sink is also
+// returned so its queue can be drained on shutdown.
+func eventSink(cfg *config.Config, rdb *goredis.Client, store *redispkg.Store) (limiter.EventSink, *events.WebhookSink) {
+	var (
+		sinks   events.Multi
+		webhook *events.WebhookSink
+	)
+	for _, name := range cfg.Events.Sinks {
+		switch name {
+		case "log":
+			sinks = append(sinks, events.NewLogSink())
+		case "webhook":
+			webhook = events.NewWebhookSink(events.WebhookConfig{
+				URL:     cfg.Events.WebhookURL,
+				Secret:  cfg.Events.WebhookSecret,
+				Retries: cfg.Events.WebhookRetries,
+				Timeout: time.Duration(cfg.Events.WebhookTimeoutSeconds) * time.Second,
+			})
+			sinks = append(sinks, webhook)
+		case "stream":
+			sinks = append(sinks, events.NewStreamSink(rdb, store.Key(cfg.Events.Stream), cfg.Events.StreamMaxLen))
+		}
+	}
+	if len(sinks) == 0 {
+		return nil, nil
 	}
+	return sinks, webhook
+}
 
-	log.Printf("server listening on :%s", cfg.Port)
-	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		log.Fatalf("server error: %v", err)
+// mount serves path with h and everything else with next.
+func mount(path string, h, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == path {
+			h.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func newHTTPServer(cfg *config.Config, h http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              ":" + cfg.Port,
+		Handler:           h,
+		ReadTimeout:       5 * time.Second,
+		ReadHeaderTimeout: 5 * time.Second,
+		WriteTimeout:      10 * time.Second,
+		IdleTimeout:       60 * time.Second,
 	}
 }
 
@@ -0,0 +1,17 @@
+// Package chilimit adapts the rate limit engine to chi.
+//
+// chi middlewares are plain func(http.Handler) http.Handler, so this is a thin
+// wrapper over the net/http middleware, usable as router.Use(chilimit.Middleware(engine)).
+package chilimit
+
+import (
+	"net/http"
+
+	"rate-limiter/pkg/middleware"
+	"rate-limiter/pkg/ratelimit"
+)
+
+// Middleware returns a chi-compatible middleware backed by the engine.
+func Middleware(e *ratelimit.Engine) func(http.Handler) http.Handler {
+	return middleware.FromEngine(e).Handler
+}
@@ -0,0 +1,34 @@
+package chilimit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"rate-limiter/pkg/config"
+	"rate-limiter/pkg/limiter"
+	"rate-limiter/pkg/ratelimit"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type denyChecker struct{}
+
+func (denyChecker) Check(_ context.Context, _ string, _, _ int64, _ time.Duration, _ time.Time, _ ...limiter.Aggregate) (limiter.Result, error) {
+	return limiter.Result{Allowed: false, RetryAfter: time.Second}, nil
+}
+
+func TestMiddleware_PlugsIntoChi(t *testing.T) {
+	cfg := &config.Config{Mode: config.ModeIP, DefaultLimitPerSec: 1, DefaultBlockSeconds: 5, TokenHeader: "API_KEY"}
+	r := chi.NewRouter()
+	r.Use(Middleware(ratelimit.NewEngine(denyChecker{}, cfg)))
+	r.Get("/", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", rr.Code)
+	}
+}
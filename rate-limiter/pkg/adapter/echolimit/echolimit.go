@@ -0,0 +1,27 @@
+// Package echolimit adapts the rate limit engine to echo.
+package echolimit
+
+import (
+	"net/http"
+
+	"rate-limiter/pkg/ratelimit"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Middleware returns an echo.MiddlewareFunc that answers 429 when the request exceeds its rule.
+func Middleware(e *ratelimit.Engine) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			d, err := e.Evaluate(c.Request().Context(), e.FromHTTP(c.Request()))
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "internal error")
+			}
+			if !d.Allowed {
+				ratelimit.WriteDenied(c.Response(), c.Request(), d)
+				return nil
+			}
+			return next(c)
+		}
+	}
+}
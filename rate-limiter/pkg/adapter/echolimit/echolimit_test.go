@@ -0,0 +1,49 @@
+package echolimit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"rate-limiter/pkg/config"
+	"rate-limiter/pkg/limiter"
+	"rate-limiter/pkg/ratelimit"
+
+	"github.com/labstack/echo/v4"
+)
+
+type countingChecker struct {
+	calls int64
+}
+
+func (c *countingChecker) Check(_ context.Context, _ string, limit, cost int64, _ time.Duration, _ time.Time, _ ...limiter.Aggregate) (limiter.Result, error) {
+	c.calls += cost
+	if c.calls > limit {
+		return limiter.Result{Allowed: false, RetryAfter: 2 * time.Second}, nil
+	}
+	return limiter.Result{Allowed: true}, nil
+}
+
+func TestMiddleware_DeniesWith429(t *testing.T) {
+	cfg := &config.Config{Mode: config.ModeIP, DefaultLimitPerSec: 1, DefaultBlockSeconds: 5, TokenHeader: "API_KEY"}
+	e := echo.New()
+	e.Use(Middleware(ratelimit.NewEngine(&countingChecker{}, cfg)))
+	e.GET("/", func(c echo.Context) error { return c.String(http.StatusOK, "ok") })
+
+	rr := httptest.NewRecorder()
+	e.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	e.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", rr.Code)
+	}
+	if rr.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected Retry-After header")
+	}
+}
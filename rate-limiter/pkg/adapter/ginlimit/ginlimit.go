@@ -0,0 +1,28 @@
+// Package ginlimit adapts the rate limit engine to gin.
+package ginlimit
+
+import (
+	"net/http"
+
+	"rate-limiter/pkg/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Middleware returns a gin.HandlerFunc that aborts with 429 when the request exceeds its rule.
+func Middleware(e *ratelimit.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		d, err := e.Evaluate(c.Request.Context(), e.FromHTTP(c.Request))
+		if err != nil {
+			c.String(http.StatusInternalServerError, "internal error")
+			c.Abort()
+			return
+		}
+		if !d.Allowed {
+			ratelimit.WriteDenied(c.Writer, c.Request, d)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,53 @@
+package ginlimit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"rate-limiter/pkg/config"
+	"rate-limiter/pkg/limiter"
+	"rate-limiter/pkg/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+type countingChecker struct {
+	calls int64
+}
+
+func (c *countingChecker) Check(_ context.Context, _ string, limit, cost int64, _ time.Duration, _ time.Time, _ ...limiter.Aggregate) (limiter.Result, error) {
+	c.calls += cost
+	if c.calls > limit {
+		return limiter.Result{Allowed: false, RetryAfter: 2 * time.Second}, nil
+	}
+	return limiter.Result{Allowed: true}, nil
+}
+
+func TestMiddleware_AbortsWith429(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := &config.Config{Mode: config.ModeIP, DefaultLimitPerSec: 1, DefaultBlockSeconds: 5, TokenHeader: "API_KEY"}
+	r := gin.New()
+	r.Use(Middleware(ratelimit.NewEngine(&countingChecker{}, cfg)))
+	r.GET("/", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", rr.Code)
+	}
+	if rr.Header().Get("Retry-After") != "2" {
+		t.Fatalf("expected Retry-After 2, got %q", rr.Header().Get("Retry-After"))
+	}
+	if rr.Body.String() == "ok" {
+		t.Fatalf("handler should not run when denied")
+	}
+}
@@ -0,0 +1,107 @@
+// Package grpclimit adapts the rate limit engine to gRPC servers.
+//
+// The token is read from the metadata key matching the configured token header
+// (lowercased, e.g. "api_key"), the client IP from "x-forwarded-for" or the peer
+// address, and the route from the full method name, so route costs can target
+// "/pkg.Service/Method" prefixes.
+package grpclimit
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+
+	"rate-limiter/pkg/limiter"
+	"rate-limiter/pkg/ratelimit"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryServerInterceptor rejects unary calls over the limit with codes.ResourceExhausted.
+func UnaryServerInterceptor(e *ratelimit.Engine) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := check(ctx, e, info.FullMethod, func(md metadata.MD) error { return grpc.SetHeader(ctx, md) }); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor rejects new streams over the limit with codes.ResourceExhausted.
+func StreamServerInterceptor(e *ratelimit.Engine) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := check(ss.Context(), e, info.FullMethod, ss.SetHeader); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+// FromMetadata extracts a rate limit request from the incoming gRPC context, with
+// the cost declared by limiter.WithCost or, failing that, in the trusted cost header.
+func FromMetadata(ctx context.Context, e *ratelimit.Engine, fullMethod string) ratelimit.Request {
+	cfg := e.Config()
+	md, _ := metadata.FromIncomingContext(ctx)
+	req := ratelimit.Request{
+		Token:  strings.TrimSpace(first(md, cfg.TokenHeader)),
+		IP:     clientIP(ctx, md),
+		Path:   fullMethod,
+		Tenant: e.TenantFor(first(md, cfg.TenantHeader), first(md, ":authority")),
+	}
+	if cost, ok := limiter.CostFromContext(ctx); ok {
+		req.Cost = cost
+	} else if cfg.CostHeader != "" {
+		if cost, err := strconv.ParseInt(strings.TrimSpace(first(md, cfg.CostHeader)), 10, 64); err == nil && cost > 0 {
+			req.DeclaredCost = cost
+		}
+	}
+	return req
+}
+
+func check(ctx context.Context, e *ratelimit.Engine, fullMethod string, setHeader func(metadata.MD) error) error {
+	d, err := e.Evaluate(ctx, FromMetadata(ctx, e, fullMethod))
+	if err != nil {
+		return status.Error(codes.Internal, "internal error")
+	}
+	if !d.Allowed {
+		if d.RetryAfter > 0 {
+			_ = setHeader(metadata.Pairs("retry-after", ratelimit.FormatRetryAfter(d.RetryAfter)))
+		}
+		md, _ := metadata.FromIncomingContext(ctx)
+		return status.Error(codes.ResourceExhausted, ratelimit.Message(d, first(md, "accept-language")))
+	}
+	return nil
+}
+
+func clientIP(ctx context.Context, md metadata.MD) string {
+	if xff := first(md, "x-forwarded-for"); xff != "" {
+		if ip := strings.TrimSpace(strings.Split(xff, ",")[0]); ip != "" {
+			return ip
+		}
+	}
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err == nil && host != "" {
+		return host
+	}
+	return p.Addr.String()
+}
+
+func first(md metadata.MD, key string) string {
+	if key == "" {
+		return ""
+	}
+	// metadata keys are always lowercase
+	if v := md.Get(strings.ToLower(key)); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
@@ -0,0 +1,123 @@
+package grpclimit
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"rate-limiter/pkg/config"
+	"rate-limiter/pkg/limiter"
+	"rate-limiter/pkg/ratelimit"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+type recordingChecker struct {
+	identifiers []string
+	costs       []int64
+	allow       bool
+}
+
+func (c *recordingChecker) Check(_ context.Context, identifier string, _, cost int64, _ time.Duration, _ time.Time, _ ...limiter.Aggregate) (limiter.Result, error) {
+	c.identifiers = append(c.identifiers, identifier)
+	c.costs = append(c.costs, cost)
+	if c.allow {
+		return limiter.Result{Allowed: true}, nil
+	}
+	return limiter.Result{Allowed: false, RetryAfter: 3 * time.Second}, nil
+}
+
+func testContext(md metadata.MD) context.Context {
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.7"), Port: 5000}})
+	return metadata.NewIncomingContext(ctx, md)
+}
+
+func TestUnaryServerInterceptor_KeysByMetadata(t *testing.T) {
+	cfg := &config.Config{
+		Mode: config.ModeAuto, DefaultLimitPerSec: 10, DefaultBlockSeconds: 5, TokenHeader: "API_KEY",
+		Routes: []config.RouteRule{{Path: "/weather.Weather/Export", Cost: 20}},
+	}
+	checker := &recordingChecker{allow: true}
+	interceptor := UnaryServerInterceptor(ratelimit.NewEngine(checker, cfg))
+	handler := func(ctx context.Context, req any) (any, error) { return "ok", nil }
+
+	_, err := interceptor(testContext(metadata.Pairs("api_key", "abc123")), nil, &grpc.UnaryServerInfo{FullMethod: "/weather.Weather/Export"}, handler)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	_, err = interceptor(testContext(metadata.MD{}), nil, &grpc.UnaryServerInfo{FullMethod: "/weather.Weather/Get"}, handler)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	if checker.identifiers[0] != "token:abc123" || checker.costs[0] != 20 {
+		t.Fatalf("unexpected first call: %s cost=%d", checker.identifiers[0], checker.costs[0])
+	}
+	if checker.identifiers[1] != "ip:10.0.0.7" || checker.costs[1] != 1 {
+		t.Fatalf("unexpected second call: %s cost=%d", checker.identifiers[1], checker.costs[1])
+	}
+}
+
+func TestFromMetadata_Cost(t *testing.T) {
+	cfg := &config.Config{
+		Mode: config.ModeAuto, DefaultLimitPerSec: 100, DefaultBlockSeconds: 5, TokenHeader: "API_KEY", CostHeader: "X-RateLimit-Cost",
+		Routes: []config.RouteRule{{Path: "/weather.Weather/Export", Cost: 20}},
+	}
+	e := ratelimit.NewEngine(&recordingChecker{allow: true}, cfg)
+	cases := []struct {
+		name string
+		ctx  context.Context
+		want int64
+	}{
+		{"route", testContext(metadata.MD{}), 20},
+		// The header may raise the route cost but not lower it.
+		{"header below route", testContext(metadata.Pairs("x-ratelimit-cost", "2")), 20},
+		{"header above route", testContext(metadata.Pairs("x-ratelimit-cost", "30")), 30},
+		// Code in front of the interceptor declares the cost with limiter.WithCost.
+		{"context", limiter.WithCost(testContext(metadata.Pairs("x-ratelimit-cost", "30")), 3), 3},
+	}
+	for _, tc := range cases {
+		if got := e.Resolve(FromMetadata(tc.ctx, e, "/weather.Weather/Export")).Cost; got != tc.want {
+			t.Fatalf("%s: expected cost %d, got %d", tc.name, tc.want, got)
+		}
+	}
+}
+
+func TestStreamServerInterceptor_DeniesWithResourceExhausted(t *testing.T) {
+	cfg := &config.Config{Mode: config.ModeIP, DefaultLimitPerSec: 1, DefaultBlockSeconds: 5, TokenHeader: "API_KEY"}
+	interceptor := StreamServerInterceptor(ratelimit.NewEngine(&recordingChecker{}, cfg))
+	ss := &fakeStream{ctx: testContext(metadata.MD{})}
+	called := false
+
+	err := interceptor(nil, ss, &grpc.StreamServerInfo{FullMethod: "/svc.S/Watch"}, func(any, grpc.ServerStream) error {
+		called = true
+		return nil
+	})
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected ResourceExhausted, got %v", err)
+	}
+	if called {
+		t.Fatalf("handler should not run when denied")
+	}
+	if got := ss.header.Get("retry-after"); len(got) != 1 || got[0] != "3" {
+		t.Fatalf("expected retry-after 3, got %v", got)
+	}
+}
+
+type fakeStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	header metadata.MD
+}
+
+func (s *fakeStream) Context() context.Context { return s.ctx }
+
+func (s *fakeStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
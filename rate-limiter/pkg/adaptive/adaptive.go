@@ -0,0 +1,174 @@
+// Package adaptive scales rate limits from backend health, AIMD-style: each rule's
+// effective limit grows by a fixed step every interval the backend stays within
+// its latency and error budgets, and is cut by a factor when it does not.
+package adaptive
+
+import (
+	"sync"
+	"time"
+
+	"rate-limiter/pkg/config"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Controller implements ratelimit.LimitAdjuster and middleware.Observer. The
+// effective limit of a rule starts at the configured default limit (within the
+// bounds) and applies to identifiers with a token override proportionally.
+type Controller struct {
+	cfg    config.AdaptiveConfig
+	base   int64
+	bounds map[string][2]int64 // rule name -> min, max
+	costs  map[string]int64    // rule name -> route cost, when above 1
+	now    func() time.Time
+
+	mu    sync.Mutex
+	rules map[string]*ruleState
+
+	limitGauge *prometheus.GaugeVec
+	latency    *prometheus.GaugeVec
+	errorRate  *prometheus.GaugeVec
+}
+
+type ruleState struct {
+	limit       int64
+	windowStart time.Time
+	requests    int64
+	errors      int64
+	totalTime   time.Duration
+}
+
+func New(cfg *config.Config) *Controller {
+	c := &Controller{
+		cfg:    cfg.Adaptive,
+		base:   cfg.DefaultLimitPerSec,
+		bounds: map[string][2]int64{},
+		costs:  map[string]int64{},
+		now:    time.Now,
+		rules:  map[string]*ruleState{},
+		limitGauge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "ratelimit_adaptive_limit_per_second",
+			Help: "Effective per-identifier limit of each rule in adaptive mode.",
+		}, []string{"rule"}),
+		latency: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "ratelimit_adaptive_latency_seconds",
+			Help: "Mean backend latency of each rule in the last adaptive interval.",
+		}, []string{"rule"}),
+		errorRate: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "ratelimit_adaptive_error_rate",
+			Help: "Share of 5xx backend responses of each rule in the last adaptive interval.",
+		}, []string{"rule"}),
+	}
+	c.addBounds("", cfg.Routes)
+	for _, t := range cfg.Tenants {
+		c.addBounds(t.Name+"/", t.Routes)
+	}
+	return c
+}
+
+// addBounds records the adaptive bounds and costs of routes under their rule keys.
+func (c *Controller) addBounds(prefix string, routes []config.RouteRule) {
+	for _, rt := range routes {
+		name := rt.Name
+		if name == "" {
+			name = rt.Path
+		}
+		if rt.Cost > 1 {
+			c.costs[prefix+name] = rt.Cost
+		}
+		if rt.AdaptiveMinLimitPerSecond == 0 && rt.AdaptiveMaxLimitPerSecond == 0 {
+			continue
+		}
+		lo, hi := c.cfg.MinLimitPerSecond, c.cfg.MaxLimitPerSecond
+		if rt.AdaptiveMinLimitPerSecond > 0 {
+			lo = rt.AdaptiveMinLimitPerSecond
+		}
+		if rt.AdaptiveMaxLimitPerSecond > 0 {
+			hi = rt.AdaptiveMaxLimitPerSecond
+		}
+		c.bounds[prefix+name] = [2]int64{lo, max(lo, hi)}
+	}
+}
+
+// Register adds the controller metrics to reg.
+func (c *Controller) Register(reg prometheus.Registerer) error {
+	for _, col := range []prometheus.Collector{c.limitGauge, c.latency, c.errorRate} {
+		if err := reg.Register(col); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AdjustLimit scales limitPerSecond by the rule's effective limit over the default
+// limit. It never goes below the route cost (or limitPerSecond, if lower), so
+// scaling alone cannot leave a costly route denying every request.
+func (c *Controller) AdjustLimit(rule string, limitPerSecond int64) int64 {
+	c.mu.Lock()
+	eff := c.state(rule).limit
+	c.mu.Unlock()
+	if c.base <= 0 || limitPerSecond == c.base {
+		return eff
+	}
+	return max(1, min(c.costs[rule], limitPerSecond), limitPerSecond*eff/c.base)
+}
+
+// Limit returns the current effective limit of a rule.
+func (c *Controller) Limit(rule string) int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state(rule).limit
+}
+
+// Observe records one backend response and, once the interval has elapsed,
+// moves the rule's limit up or down.
+func (c *Controller) Observe(rule string, status int, latency time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st := c.state(rule)
+	st.requests++
+	st.totalTime += latency
+	if status >= 500 {
+		st.errors++
+	}
+	now := c.now()
+	if now.Sub(st.windowStart) < time.Duration(c.cfg.IntervalSeconds)*time.Second {
+		return
+	}
+
+	lo, hi := c.boundsFor(rule)
+	mean := st.totalTime / time.Duration(st.requests)
+	errRate := float64(st.errors) / float64(st.requests)
+	if mean > time.Duration(c.cfg.TargetLatencyMillis)*time.Millisecond || errRate > c.cfg.MaxErrorRate {
+		st.limit = max(lo, int64(float64(st.limit)*c.cfg.DecreaseFactor))
+	} else {
+		st.limit = min(hi, st.limit+c.cfg.IncreaseStep)
+	}
+	c.limitGauge.WithLabelValues(rule).Set(float64(st.limit))
+	c.latency.WithLabelValues(rule).Set(mean.Seconds())
+	c.errorRate.WithLabelValues(rule).Set(errRate)
+	st.windowStart, st.requests, st.errors, st.totalTime = now, 0, 0, 0
+}
+
+// state returns the rule state, creating it at the default limit. Callers hold mu.
+func (c *Controller) state(rule string) *ruleState {
+	st, ok := c.rules[rule]
+	if !ok {
+		lo, hi := c.boundsFor(rule)
+		st = &ruleState{limit: min(max(c.base, lo), hi), windowStart: c.now()}
+		c.rules[rule] = st
+		c.limitGauge.WithLabelValues(rule).Set(float64(st.limit))
+	}
+	return st
+}
+
+// boundsFor returns the limits of rule, with the floor raised to the route cost:
+// below it no request of the route fits in a window.
+func (c *Controller) boundsFor(rule string) (int64, int64) {
+	lo, hi := c.cfg.MinLimitPerSecond, c.cfg.MaxLimitPerSecond
+	if b, ok := c.bounds[rule]; ok {
+		lo, hi = b[0], b[1]
+	}
+	lo = max(lo, c.costs[rule])
+	return lo, max(lo, hi)
+}
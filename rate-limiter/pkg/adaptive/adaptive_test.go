@@ -0,0 +1,107 @@
+package adaptive
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"rate-limiter/pkg/config"
+)
+
+func newTestController(routes ...config.RouteRule) (*Controller, *time.Time) {
+	cfg := &config.Config{
+		DefaultLimitPerSec: 10,
+		Routes:             routes,
+		Adaptive: config.AdaptiveConfig{
+			Enabled:             true,
+			MinLimitPerSecond:   2,
+			MaxLimitPerSecond:   12,
+			TargetLatencyMillis: 100,
+			MaxErrorRate:        0.1,
+			IncreaseStep:        1,
+			DecreaseFactor:      0.5,
+			IntervalSeconds:     1,
+		},
+	}
+	c := New(cfg)
+	now := time.Unix(1000, 0)
+	c.now = func() time.Time { return now }
+	return c, &now
+}
+
+// tick moves the clock one interval ahead and observes a response, closing the window.
+func tick(c *Controller, now *time.Time, rule string, status int, latency time.Duration) {
+	*now = now.Add(time.Second)
+	c.Observe(rule, status, latency)
+}
+
+func TestController_AdditiveIncreaseUpToMax(t *testing.T) {
+	c, now := newTestController()
+	if got := c.Limit("default"); got != 10 {
+		t.Fatalf("expected to start at the default limit, got %d", got)
+	}
+	for i := 0; i < 5; i++ {
+		tick(c, now, "default", http.StatusOK, 10*time.Millisecond)
+	}
+	if got := c.Limit("default"); got != 12 {
+		t.Fatalf("expected limit capped at max 12, got %d", got)
+	}
+}
+
+func TestController_MultiplicativeDecreaseOnErrorsAndLatency(t *testing.T) {
+	c, now := newTestController()
+	_ = c.Limit("default") // opens the first window
+	tick(c, now, "default", http.StatusServiceUnavailable, time.Millisecond)
+	if got := c.Limit("default"); got != 5 {
+		t.Fatalf("expected 5xx to halve the limit to 5, got %d", got)
+	}
+	tick(c, now, "default", http.StatusOK, 300*time.Millisecond)
+	if got := c.Limit("default"); got != 2 {
+		t.Fatalf("expected slow responses to cut the limit to the min 2, got %d", got)
+	}
+}
+
+func TestController_WaitsForInterval(t *testing.T) {
+	c, _ := newTestController()
+	for i := 0; i < 10; i++ {
+		c.Observe("default", http.StatusInternalServerError, time.Millisecond)
+	}
+	if got := c.Limit("default"); got != 10 {
+		t.Fatalf("expected no change within the interval, got %d", got)
+	}
+}
+
+func TestController_RouteBoundsAndProportionalOverrides(t *testing.T) {
+	c, now := newTestController(config.RouteRule{Name: "export", Path: "/export", AdaptiveMinLimitPerSecond: 8, AdaptiveMaxLimitPerSecond: 20})
+	_ = c.Limit("export")
+	tick(c, now, "export", http.StatusBadGateway, time.Millisecond)
+	if got := c.Limit("export"); got != 8 {
+		t.Fatalf("expected route min 8, got %d", got)
+	}
+	if got := c.AdjustLimit("export", 10); got != 8 {
+		t.Fatalf("expected default-limit identifiers to get 8, got %d", got)
+	}
+	if got := c.AdjustLimit("export", 100); got != 80 {
+		t.Fatalf("expected override scaled to 80, got %d", got)
+	}
+	if got := c.Limit("default"); got != 10 {
+		t.Fatalf("expected other rules unaffected, got %d", got)
+	}
+}
+
+func TestController_FloorCoversRouteCost(t *testing.T) {
+	c, now := newTestController(config.RouteRule{Name: "export", Path: "/export", Cost: 5})
+	_ = c.Limit("export")
+	for i := 0; i < 3; i++ {
+		tick(c, now, "export", http.StatusBadGateway, time.Millisecond)
+	}
+	if got := c.Limit("export"); got != 5 {
+		t.Fatalf("expected the floor raised from 2 to the route cost 5, got %d", got)
+	}
+	if got := c.AdjustLimit("export", 6); got != 5 {
+		t.Fatalf("expected a scaled override to keep the route cost, got %d", got)
+	}
+	if got := c.AdjustLimit("export", 3); got != 3 {
+		t.Fatalf("expected an override below the cost to stay unchanged, got %d", got)
+	}
+}
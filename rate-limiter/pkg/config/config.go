@@ -0,0 +1,516 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+type Mode string
+
+const (
+	ModeIP    Mode = "ip"
+	ModeToken Mode = "token"
+	ModeAuto  Mode = "auto"
+)
+
+// ServerMode selects what cmd/server runs.
+type ServerMode string
+
+const (
+	// ServerModeDemo serves a demo handler behind the rate limit middleware.
+	ServerModeDemo ServerMode = "demo"
+	// ServerModeDecision serves the decision API (HTTP POST /check and Envoy's gRPC rate limit service).
+	ServerModeDecision ServerMode = "decision"
+	// ServerModeProxy forwards allowed requests to the configured upstreams.
+	ServerModeProxy ServerMode = "proxy"
+)
+
+const (
+	ClockLocal = "local"
+	ClockStore = "store"
+)
+
+// ProxyRoute sends requests whose path matches Path (exact or as a prefix segment) to Upstream.
+type ProxyRoute struct {
+	Path     string
+	Upstream string
+}
+
+// ProxyConfig configures the reverse-proxy server mode.
+type ProxyConfig struct {
+	// Upstream receives requests that match no route; empty means unmatched requests get 404.
+	Upstream               string
+	Routes                 []ProxyRoute
+	DialTimeoutSeconds     int64
+	ResponseTimeoutSeconds int64
+	SetHeaders             map[string]string
+	RemoveHeaders          []string
+}
+
+type TokenOverride struct {
+	LimitPerSecond  int64 `json:"limit_per_second"`
+	BlockForSeconds int64 `json:"block_seconds"`
+}
+
+type Config struct {
+	Port       string
+	ServerMode ServerMode
+	GRPCPort   string
+	// ShutdownDelaySeconds keeps serving after SIGTERM with /readyz failing, so load
+	// balancers stop routing new requests before the listeners close.
+	ShutdownDelaySeconds int64
+	// ShutdownTimeoutSeconds bounds how long in-flight requests may drain after the delay.
+	ShutdownTimeoutSeconds int64
+
+	Mode                Mode
+	DefaultLimitPerSec  int64
+	DefaultBlockSeconds int64
+	// GlobalLimitPerSec caps the total units per second across all identifiers and routes; zero disables it.
+	GlobalLimitPerSec int64
+	TokenHeader       string
+	// CostHeader, when set, lets a trusted upstream declare the request cost in this header.
+	CostHeader string
+	// Clock selects where window boundaries come from: "local" (each instance) or "store" (Redis TIME).
+	Clock string
+	// SkewThresholdMillis is the local/store clock divergence that gets logged.
+	SkewThresholdMillis int64
+	// SkewCheckSeconds is how often the skew is measured; zero, the default, disables
+	// the detector and its extra Redis round trip.
+	SkewCheckSeconds int64
+
+	RedisAddr     string
+	RedisDB       int
+	RedisPassword string
+
+	TokenOverrides map[string]TokenOverride
+	Routes         []RouteRule
+	// DefaultResponse customizes the denial for requests that match no route with its own response.
+	DefaultResponse *ResponseTemplate
+
+	// Namespace prefixes every store key, so deployments sharing a Redis never collide.
+	Namespace string
+	// Tenants have their own rule sets and counters; requests that match none use the top-level rules.
+	Tenants []Tenant
+	// TenantHeader, when set, names the tenant of a request ahead of its Host. Only
+	// enable it behind a trusted gateway that sets or strips the header.
+	TenantHeader string
+
+	// MetricsPath serves Prometheus metrics outside the limiter; empty disables it.
+	// It defaults to /metrics only with adaptive limiting, the only source of
+	// metrics, since in proxy mode the path would shadow the upstream's own.
+	MetricsPath string
+
+	Proxy    ProxyConfig
+	Quota    QuotaConfig
+	Adaptive AdaptiveConfig
+	Events   EventsConfig
+}
+
+// EventsConfig selects where block events go.
+type EventsConfig struct {
+	// Sinks lists the enabled sinks: log, webhook and/or stream.
+	Sinks                 []string
+	WebhookURL            string
+	WebhookSecret         string
+	WebhookRetries        int
+	WebhookTimeoutSeconds int64
+	// Stream is the Redis stream key events are appended to.
+	Stream       string
+	StreamMaxLen int64
+}
+
+// AdaptiveConfig scales the effective limit of each rule from the latency and 5xx
+// rate of the handler behind the middleware: additive increase while it is
+// healthy, multiplicative decrease when it is not.
+type AdaptiveConfig struct {
+	Enabled bool
+	// MinLimitPerSecond and MaxLimitPerSecond bound the effective limit of rules
+	// without their own bounds.
+	MinLimitPerSecond int64
+	MaxLimitPerSecond int64
+	// TargetLatencyMillis is the mean latency above which the limit decreases.
+	TargetLatencyMillis int64
+	// MaxErrorRate is the share of 5xx responses (0-1) above which the limit decreases.
+	MaxErrorRate   float64
+	IncreaseStep   int64
+	DecreaseFactor float64
+	// IntervalSeconds is how often each rule's limit is re-evaluated.
+	IntervalSeconds int64
+}
+
+// QuotaConfig configures long-period quotas per API token.
+type QuotaConfig struct {
+	// Period is the calendar period quotas reset on: day, week (ISO, Monday) or month.
+	Period string
+	// Timezone aligns period boundaries, e.g. America/Sao_Paulo.
+	Timezone string
+	// DefaultLimit applies to tokens without an override; zero means unlimited (usage is still tracked).
+	DefaultLimit int64
+	Overrides    map[string]int64
+	// HistoryPeriods is how many past periods are kept for usage reports.
+	HistoryPeriods int
+	// ReportPath serves the usage report for the token in the request; empty disables it.
+	ReportPath string
+}
+
+// Enabled reports whether any quota is configured.
+func (q QuotaConfig) Enabled() bool {
+	return q.DefaultLimit > 0 || len(q.Overrides) > 0
+}
+
+// LimitFor returns the quota of a token; zero means unlimited.
+func (q QuotaConfig) LimitFor(token string) int64 {
+	if lim, ok := q.Overrides[token]; ok {
+		return lim
+	}
+	return q.DefaultLimit
+}
+
+func Load() (*Config, error) {
+	// Load .env if present
+	_ = godotenv.Load()
+
+	cfg := &Config{
+		Port:                   getString("PORT", "8080"),
+		ServerMode:             ServerMode(getString("SERVER_MODE", string(ServerModeDemo))),
+		GRPCPort:               getString("GRPC_PORT", "8081"),
+		ShutdownDelaySeconds:   getInt64("SHUTDOWN_DELAY_SECONDS", 5),
+		ShutdownTimeoutSeconds: getInt64("SHUTDOWN_TIMEOUT_SECONDS", 15),
+		Mode:                   Mode(getString("RATE_LIMIT_MODE", string(ModeAuto))),
+		DefaultLimitPerSec:     getInt64("RATE_LIMIT_RPS", 10),
+		DefaultBlockSeconds:    getInt64("RATE_LIMIT_BLOCK_SECONDS", 300),
+		GlobalLimitPerSec:      getInt64("RATE_LIMIT_GLOBAL_RPS", 0),
+		TokenHeader:            getString("RATE_LIMIT_TOKEN_HEADER", "API_KEY"),
+		CostHeader:             getString("RATE_LIMIT_COST_HEADER", ""),
+		Namespace:              getString("RATE_LIMIT_NAMESPACE", ""),
+		TenantHeader:           getString("RATE_LIMIT_TENANT_HEADER", ""),
+		Clock:                  getString("RATE_LIMIT_CLOCK", ClockLocal),
+		SkewThresholdMillis:    getInt64("RATE_LIMIT_SKEW_THRESHOLD_MS", 250),
+		SkewCheckSeconds:       getInt64("RATE_LIMIT_SKEW_CHECK_SECONDS", 0),
+
+		RedisAddr:     getString("REDIS_ADDR", "localhost:6379"),
+		RedisDB:       int(getInt64("REDIS_DB", 0)),
+		RedisPassword: getString("REDIS_PASSWORD", ""),
+
+		TokenOverrides: map[string]TokenOverride{},
+
+		Proxy: ProxyConfig{
+			Upstream:               getString("PROXY_UPSTREAM", ""),
+			DialTimeoutSeconds:     getInt64("PROXY_DIAL_TIMEOUT_SECONDS", 5),
+			ResponseTimeoutSeconds: getInt64("PROXY_RESPONSE_TIMEOUT_SECONDS", 30),
+			SetHeaders:             map[string]string{},
+		},
+
+		Quota: QuotaConfig{
+			Period:         getString("RATE_LIMIT_QUOTA_PERIOD", "month"),
+			Timezone:       getString("RATE_LIMIT_QUOTA_TIMEZONE", "UTC"),
+			DefaultLimit:   getInt64("RATE_LIMIT_QUOTA_DEFAULT", 0),
+			Overrides:      map[string]int64{},
+			HistoryPeriods: int(getInt64("RATE_LIMIT_QUOTA_HISTORY", 12)),
+		},
+	}
+	cfg.Events = EventsConfig{
+		WebhookURL:            getString("RATE_LIMIT_EVENTS_WEBHOOK_URL", ""),
+		WebhookSecret:         getString("RATE_LIMIT_EVENTS_WEBHOOK_SECRET", ""),
+		WebhookRetries:        int(getInt64("RATE_LIMIT_EVENTS_WEBHOOK_RETRIES", 3)),
+		WebhookTimeoutSeconds: getInt64("RATE_LIMIT_EVENTS_WEBHOOK_TIMEOUT_SECONDS", 5),
+		Stream:                getString("RATE_LIMIT_EVENTS_STREAM", "rl:events"),
+		StreamMaxLen:          getInt64("RATE_LIMIT_EVENTS_STREAM_MAXLEN", 10000),
+	}
+	cfg.Adaptive = AdaptiveConfig{
+		Enabled:             getBool("RATE_LIMIT_ADAPTIVE", false),
+		MinLimitPerSecond:   getInt64("RATE_LIMIT_ADAPTIVE_MIN_RPS", 1),
+		MaxLimitPerSecond:   getInt64("RATE_LIMIT_ADAPTIVE_MAX_RPS", 2*cfg.DefaultLimitPerSec),
+		TargetLatencyMillis: getInt64("RATE_LIMIT_ADAPTIVE_TARGET_LATENCY_MS", 250),
+		MaxErrorRate:        getFloat("RATE_LIMIT_ADAPTIVE_MAX_ERROR_RATE", 0.05),
+		IncreaseStep:        getInt64("RATE_LIMIT_ADAPTIVE_INCREASE_STEP", 1),
+		DecreaseFactor:      getFloat("RATE_LIMIT_ADAPTIVE_DECREASE_FACTOR", 0.5),
+		IntervalSeconds:     getInt64("RATE_LIMIT_ADAPTIVE_INTERVAL_SECONDS", 1),
+	}
+	if cfg.Adaptive.Enabled {
+		cfg.MetricsPath = "/metrics"
+	}
+	if v, ok := os.LookupEnv("METRICS_PATH"); ok {
+		// Unlike other settings an empty value counts: it disables the endpoint.
+		cfg.MetricsPath = strings.TrimSpace(v)
+	}
+	if cfg.MetricsPath != "" && !strings.HasPrefix(cfg.MetricsPath, "/") {
+		return nil, fmt.Errorf("invalid METRICS_PATH: %s", cfg.MetricsPath)
+	}
+	// A proxy forwards every path, so it only shadows one with the report when asked to.
+	if cfg.ServerMode != ServerModeProxy {
+		cfg.Quota.ReportPath = "/quota"
+	}
+	if v, ok := os.LookupEnv("RATE_LIMIT_QUOTA_REPORT_PATH"); ok {
+		cfg.Quota.ReportPath = strings.TrimSpace(v)
+	}
+	if cfg.Quota.ReportPath != "" && !strings.HasPrefix(cfg.Quota.ReportPath, "/") {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_QUOTA_REPORT_PATH: %s", cfg.Quota.ReportPath)
+	}
+
+	switch cfg.ServerMode {
+	case ServerModeDemo, ServerModeDecision, ServerModeProxy:
+	default:
+		return nil, fmt.Errorf("invalid SERVER_MODE: %s", cfg.ServerMode)
+	}
+	if cfg.Clock != ClockLocal && cfg.Clock != ClockStore {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_CLOCK: %s", cfg.Clock)
+	}
+	if strings.ContainsAny(cfg.Namespace, " *?[]\\") {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_NAMESPACE: %s", cfg.Namespace)
+	}
+
+	if err := parseTokenOverrides(cfg); err != nil {
+		return nil, err
+	}
+	if err := loadRulesFile(cfg); err != nil {
+		return nil, err
+	}
+	if err := parseRouteCosts(cfg); err != nil {
+		return nil, err
+	}
+	if err := parseProxy(cfg); err != nil {
+		return nil, err
+	}
+	if err := parseQuota(cfg); err != nil {
+		return nil, err
+	}
+	if err := validateAdaptive(cfg); err != nil {
+		return nil, err
+	}
+	if err := parseEvents(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func parseTokenOverrides(cfg *Config) error {
+	raw := strings.TrimSpace(os.Getenv("RATE_LIMIT_TOKEN_OVERRIDES"))
+	if raw == "" {
+		return nil
+	}
+	parts := strings.Split(raw, ",")
+	for _, p := range parts {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		fields := strings.Split(p, ":")
+		if len(fields) != 3 {
+			return fmt.Errorf("invalid RATE_LIMIT_TOKEN_OVERRIDES item: %s", p)
+		}
+		token := strings.TrimSpace(fields[0])
+		lim, err := strconv.ParseInt(strings.TrimSpace(fields[1]), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid limit in RATE_LIMIT_TOKEN_OVERRIDES '%s': %w", p, err)
+		}
+		blk, err := strconv.ParseInt(strings.TrimSpace(fields[2]), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid block seconds in RATE_LIMIT_TOKEN_OVERRIDES '%s': %w", p, err)
+		}
+		cfg.TokenOverrides[token] = TokenOverride{LimitPerSecond: lim, BlockForSeconds: blk}
+	}
+	return nil
+}
+
+func parseRouteCosts(cfg *Config) error {
+	raw := strings.TrimSpace(os.Getenv("RATE_LIMIT_ROUTE_COSTS"))
+	if raw == "" {
+		return nil
+	}
+	parts := strings.Split(raw, ",")
+	for _, p := range parts {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		idx := strings.LastIndex(p, ":")
+		if idx <= 0 {
+			return fmt.Errorf("invalid RATE_LIMIT_ROUTE_COSTS item: %s", p)
+		}
+		path := strings.TrimSpace(p[:idx])
+		if !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("invalid path in RATE_LIMIT_ROUTE_COSTS '%s': must start with /", p)
+		}
+		cost, err := strconv.ParseInt(strings.TrimSpace(p[idx+1:]), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid cost in RATE_LIMIT_ROUTE_COSTS '%s': %w", p, err)
+		}
+		if cost < 1 {
+			return fmt.Errorf("invalid cost in RATE_LIMIT_ROUTE_COSTS '%s': must be >= 1", p)
+		}
+		cfg.Routes = append(cfg.Routes, RouteRule{Path: path, Cost: cost})
+	}
+	return nil
+}
+
+func parseProxy(cfg *Config) error {
+	if raw := strings.TrimSpace(os.Getenv("PROXY_ROUTES")); raw != "" {
+		for _, p := range strings.Split(raw, ",") {
+			p = strings.TrimSpace(p)
+			if p == "" {
+				continue
+			}
+			path, upstream, ok := strings.Cut(p, "=")
+			path, upstream = strings.TrimSpace(path), strings.TrimSpace(upstream)
+			if !ok || !strings.HasPrefix(path, "/") || upstream == "" {
+				return fmt.Errorf("invalid PROXY_ROUTES item: %s", p)
+			}
+			cfg.Proxy.Routes = append(cfg.Proxy.Routes, ProxyRoute{Path: path, Upstream: upstream})
+		}
+	}
+	if raw := strings.TrimSpace(os.Getenv("PROXY_SET_HEADERS")); raw != "" {
+		for _, p := range strings.Split(raw, ",") {
+			p = strings.TrimSpace(p)
+			if p == "" {
+				continue
+			}
+			name, value, ok := strings.Cut(p, ":")
+			if !ok || strings.TrimSpace(name) == "" {
+				return fmt.Errorf("invalid PROXY_SET_HEADERS item: %s", p)
+			}
+			cfg.Proxy.SetHeaders[strings.TrimSpace(name)] = strings.TrimSpace(value)
+		}
+	}
+	if raw := strings.TrimSpace(os.Getenv("PROXY_REMOVE_HEADERS")); raw != "" {
+		for _, h := range strings.Split(raw, ",") {
+			if h = strings.TrimSpace(h); h != "" {
+				cfg.Proxy.RemoveHeaders = append(cfg.Proxy.RemoveHeaders, h)
+			}
+		}
+	}
+	if cfg.ServerMode == ServerModeProxy && cfg.Proxy.Upstream == "" && len(cfg.Proxy.Routes) == 0 {
+		return fmt.Errorf("SERVER_MODE=proxy requires PROXY_UPSTREAM or PROXY_ROUTES")
+	}
+	return nil
+}
+
+func parseQuota(cfg *Config) error {
+	switch cfg.Quota.Period {
+	case "day", "week", "month":
+	default:
+		return fmt.Errorf("invalid RATE_LIMIT_QUOTA_PERIOD: %s", cfg.Quota.Period)
+	}
+	if _, err := time.LoadLocation(cfg.Quota.Timezone); err != nil {
+		return fmt.Errorf("invalid RATE_LIMIT_QUOTA_TIMEZONE '%s': %w", cfg.Quota.Timezone, err)
+	}
+	raw := strings.TrimSpace(os.Getenv("RATE_LIMIT_QUOTA_OVERRIDES"))
+	if raw == "" {
+		return nil
+	}
+	for _, p := range strings.Split(raw, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		token, limit, ok := strings.Cut(p, ":")
+		if !ok {
+			return fmt.Errorf("invalid RATE_LIMIT_QUOTA_OVERRIDES item: %s", p)
+		}
+		n, err := strconv.ParseInt(strings.TrimSpace(limit), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid limit in RATE_LIMIT_QUOTA_OVERRIDES '%s': %w", p, err)
+		}
+		cfg.Quota.Overrides[strings.TrimSpace(token)] = n
+	}
+	return nil
+}
+
+func validateAdaptive(cfg *Config) error {
+	a := cfg.Adaptive
+	if !a.Enabled {
+		return nil
+	}
+	if cfg.ServerMode == ServerModeDecision {
+		// The decision service never sees backend responses, so limits would stay put.
+		return fmt.Errorf("RATE_LIMIT_ADAPTIVE is not supported with SERVER_MODE=decision")
+	}
+	if a.MinLimitPerSecond < 1 || a.MaxLimitPerSecond < a.MinLimitPerSecond {
+		return fmt.Errorf("invalid RATE_LIMIT_ADAPTIVE_MIN_RPS/MAX_RPS: need 1 <= min <= max, got %d and %d", a.MinLimitPerSecond, a.MaxLimitPerSecond)
+	}
+	if a.DecreaseFactor <= 0 || a.DecreaseFactor >= 1 {
+		return fmt.Errorf("invalid RATE_LIMIT_ADAPTIVE_DECREASE_FACTOR: must be between 0 and 1, got %g", a.DecreaseFactor)
+	}
+	if a.IncreaseStep < 1 || a.IntervalSeconds < 1 || a.TargetLatencyMillis < 1 {
+		return fmt.Errorf("invalid adaptive settings: increase step, interval and target latency must be >= 1")
+	}
+	if err := validateAdaptiveRoutes(cfg.Routes); err != nil {
+		return err
+	}
+	for _, t := range cfg.Tenants {
+		if err := validateAdaptiveRoutes(t.Routes); err != nil {
+			return fmt.Errorf("invalid tenant %s: %w", t.Name, err)
+		}
+	}
+	return nil
+}
+
+func validateAdaptiveRoutes(routes []RouteRule) error {
+	for i, rt := range routes {
+		if rt.AdaptiveMinLimitPerSecond > 0 && rt.AdaptiveMaxLimitPerSecond > 0 && rt.AdaptiveMaxLimitPerSecond < rt.AdaptiveMinLimitPerSecond {
+			return fmt.Errorf("invalid route %d (%s): adaptive_max_limit_per_second below adaptive_min_limit_per_second", i, rt.Name)
+		}
+	}
+	return nil
+}
+
+func parseEvents(cfg *Config) error {
+	for _, sink := range strings.Split(os.Getenv("RATE_LIMIT_EVENTS_SINKS"), ",") {
+		sink = strings.TrimSpace(sink)
+		switch sink {
+		case "":
+			continue
+		case "log", "stream":
+		case "webhook":
+			if cfg.Events.WebhookURL == "" {
+				return fmt.Errorf("RATE_LIMIT_EVENTS_SINKS=webhook requires RATE_LIMIT_EVENTS_WEBHOOK_URL")
+			}
+		default:
+			return fmt.Errorf("invalid RATE_LIMIT_EVENTS_SINKS item: %s", sink)
+		}
+		cfg.Events.Sinks = append(cfg.Events.Sinks, sink)
+	}
+	return nil
+}
+
+func getString(key, def string) string {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		return v
+	}
+	return def
+}
+
+func getInt64(key string, def int64) int64 {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err == nil {
+			return n
+		}
+	}
+	return def
+}
+
+func getBool(key string, def bool) bool {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err == nil {
+			return b
+		}
+	}
+	return def
+}
+
+func getFloat(key string, def float64) float64 {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err == nil {
+			return f
+		}
+	}
+	return def
+}
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoad_MetricsPathFollowsAdaptive(t *testing.T) {
+	cases := []struct {
+		name     string
+		env      map[string]string
+		wantPath string
+	}{
+		{"off by default", nil, ""},
+		{"on with adaptive", map[string]string{"RATE_LIMIT_ADAPTIVE": "true"}, "/metrics"},
+		{"explicit path", map[string]string{"METRICS_PATH": "/internal/metrics"}, "/internal/metrics"},
+		{"explicitly disabled", map[string]string{"RATE_LIMIT_ADAPTIVE": "true", "METRICS_PATH": ""}, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+			cfg, err := Load()
+			if err != nil {
+				t.Fatalf("load: %v", err)
+			}
+			if cfg.MetricsPath != tc.wantPath {
+				t.Fatalf("MetricsPath = %q, want %q", cfg.MetricsPath, tc.wantPath)
+			}
+		})
+	}
+}
+
+func TestLoad_QuotaReportPathSparesProxiedPaths(t *testing.T) {
+	cases := []struct {
+		name     string
+		env      map[string]string
+		wantPath string
+	}{
+		{"on by default", nil, "/quota"},
+		{"explicitly disabled", map[string]string{"RATE_LIMIT_QUOTA_REPORT_PATH": ""}, ""},
+		{"off in proxy mode", map[string]string{"SERVER_MODE": "proxy", "PROXY_UPSTREAM": "http://upstream:8080"}, ""},
+		{"explicit in proxy mode", map[string]string{"SERVER_MODE": "proxy", "PROXY_UPSTREAM": "http://upstream:8080", "RATE_LIMIT_QUOTA_REPORT_PATH": "/_ratelimit/quota"}, "/_ratelimit/quota"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+			cfg, err := Load()
+			if err != nil {
+				t.Fatalf("load: %v", err)
+			}
+			if cfg.Quota.ReportPath != tc.wantPath {
+				t.Fatalf("ReportPath = %q, want %q", cfg.Quota.ReportPath, tc.wantPath)
+			}
+		})
+	}
+}
+
+func TestLoad_ValidatesTenantAdaptiveRoutes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	rules := `{"tenants": [{"name": "cep", "routes": [{"path": "/x", "adaptive_min_limit_per_second": 10, "adaptive_max_limit_per_second": 5}]}]}`
+	if err := os.WriteFile(path, []byte(rules), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	t.Setenv("RATE_LIMIT_RULES_FILE", path)
+	t.Setenv("RATE_LIMIT_ADAPTIVE", "true")
+	if _, err := Load(); err == nil {
+		t.Fatalf("expected error for tenant route with max below min")
+	}
+}
+
+func TestLoad_RejectsAdaptiveDecisionMode(t *testing.T) {
+	t.Setenv("SERVER_MODE", "decision")
+	t.Setenv("RATE_LIMIT_ADAPTIVE", "true")
+	if _, err := Load(); err == nil {
+		t.Fatalf("expected error: the decision service has no backend to observe")
+	}
+}
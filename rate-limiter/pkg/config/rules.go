@@ -0,0 +1,194 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// RouteRule applies to requests whose path matches Path (exact or as a prefix segment);
+// when several rules match, the longest Path wins.
+type RouteRule struct {
+	Name string `json:"name,omitempty"`
+	Path string `json:"path"`
+	// Cost is how many units each request consumes; zero means one.
+	Cost int64 `json:"cost,omitempty"`
+	// AggregateLimitPerSecond caps the total units per second on this route across
+	// all identifiers; zero means no aggregate limit.
+	AggregateLimitPerSecond int64 `json:"aggregate_limit_per_second,omitempty"`
+	// Response customizes the denial for this route.
+	Response *ResponseTemplate `json:"response,omitempty"`
+	// AdaptiveMinLimitPerSecond and AdaptiveMaxLimitPerSecond bound this route's
+	// effective limit in adaptive mode; zero falls back to the global bounds.
+	AdaptiveMinLimitPerSecond int64 `json:"adaptive_min_limit_per_second,omitempty"`
+	AdaptiveMaxLimitPerSecond int64 `json:"adaptive_max_limit_per_second,omitempty"`
+}
+
+// ResponseTemplate customizes the denial response. Body is a Go template
+// (html/template when ContentType is HTML, text/template otherwise) rendered with
+// .Message, .RetryAfterSeconds, .LimitPerSecond, .Rule and .Lang, plus the
+// TemplateFuncs. Without a Body, the built-in negotiated body is used with Status.
+type ResponseTemplate struct {
+	Status      int    `json:"status,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        string `json:"body,omitempty"`
+}
+
+// TemplateFuncs are the functions response body templates can call. json writes a
+// value as a JSON literal, quotes included, so `"message":{{json .Message}}` stays
+// valid JSON whatever the message contains.
+var TemplateFuncs = map[string]any{
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// Rules is the content of the optional RATE_LIMIT_RULES_FILE.
+type Rules struct {
+	DefaultResponse *ResponseTemplate `json:"default_response,omitempty"`
+	Routes          []RouteRule       `json:"routes,omitempty"`
+	Tenants         []Tenant          `json:"tenants,omitempty"`
+}
+
+// Tenant is a product served by the same deployment with its own rule set and
+// counters. Requests are assigned to it by the tenant header or by Host.
+type Tenant struct {
+	Name  string   `json:"name"`
+	Hosts []string `json:"hosts,omitempty"`
+	// LimitPerSecond, BlockSeconds and GlobalLimitPerSecond fall back to the
+	// top-level settings when zero.
+	LimitPerSecond       int64                    `json:"limit_per_second,omitempty"`
+	BlockSeconds         int64                    `json:"block_seconds,omitempty"`
+	GlobalLimitPerSecond int64                    `json:"global_limit_per_second,omitempty"`
+	TokenOverrides       map[string]TokenOverride `json:"token_overrides,omitempty"`
+	// Routes replace the top-level routes for this tenant.
+	Routes []RouteRule `json:"routes,omitempty"`
+	// DefaultResponse falls back to the top-level default response when nil.
+	DefaultResponse *ResponseTemplate `json:"default_response,omitempty"`
+}
+
+// ParseRules decodes and validates a rules file.
+func ParseRules(data []byte) (*Rules, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var rules Rules
+	if err := dec.Decode(&rules); err != nil {
+		return nil, fmt.Errorf("invalid rules file: %w", err)
+	}
+	if err := rules.Validate(); err != nil {
+		return nil, err
+	}
+	return &rules, nil
+}
+
+// Validate checks paths, costs, response templates and tenants.
+func (r *Rules) Validate() error {
+	if err := r.DefaultResponse.validate(); err != nil {
+		return fmt.Errorf("invalid default_response: %w", err)
+	}
+	if err := validateRoutes(r.Routes); err != nil {
+		return err
+	}
+	return validateTenants(r.Tenants)
+}
+
+func validateRoutes(routes []RouteRule) error {
+	for i, rt := range routes {
+		if !strings.HasPrefix(rt.Path, "/") {
+			return fmt.Errorf("invalid route %d (%s): path must start with /", i, rt.Name)
+		}
+		if rt.Cost < 0 {
+			return fmt.Errorf("invalid route %d (%s): cost must be >= 0", i, rt.Name)
+		}
+		if rt.AggregateLimitPerSecond < 0 {
+			return fmt.Errorf("invalid route %d (%s): aggregate_limit_per_second must be >= 0", i, rt.Name)
+		}
+		if rt.AdaptiveMinLimitPerSecond < 0 || rt.AdaptiveMaxLimitPerSecond < 0 {
+			return fmt.Errorf("invalid route %d (%s): adaptive limits must be >= 0", i, rt.Name)
+		}
+		if rt.AdaptiveMinLimitPerSecond > 0 && rt.AdaptiveMinLimitPerSecond < rt.Cost {
+			return fmt.Errorf("invalid route %d (%s): adaptive_min_limit_per_second below the route cost", i, rt.Name)
+		}
+		if err := rt.Response.validate(); err != nil {
+			return fmt.Errorf("invalid route %d (%s) response: %w", i, rt.Name, err)
+		}
+	}
+	return nil
+}
+
+func validateTenants(tenants []Tenant) error {
+	names := map[string]bool{}
+	hosts := map[string]string{}
+	for i, t := range tenants {
+		if t.Name == "" || strings.ContainsAny(t.Name, " :/\r\n*?[]") {
+			return fmt.Errorf("invalid tenant %d: name must be non-empty without spaces, ':', '/' or glob characters", i)
+		}
+		if names[t.Name] {
+			return fmt.Errorf("invalid tenant %d: duplicate name %s", i, t.Name)
+		}
+		names[t.Name] = true
+		for _, h := range t.Hosts {
+			h = strings.ToLower(h)
+			if other, ok := hosts[h]; ok {
+				return fmt.Errorf("invalid tenant %s: host %s already belongs to tenant %s", t.Name, h, other)
+			}
+			hosts[h] = t.Name
+		}
+		if t.LimitPerSecond < 0 || t.BlockSeconds < 0 || t.GlobalLimitPerSecond < 0 {
+			return fmt.Errorf("invalid tenant %s: limits must be >= 0", t.Name)
+		}
+		if err := t.DefaultResponse.validate(); err != nil {
+			return fmt.Errorf("invalid tenant %s default_response: %w", t.Name, err)
+		}
+		if err := validateRoutes(t.Routes); err != nil {
+			return fmt.Errorf("invalid tenant %s: %w", t.Name, err)
+		}
+	}
+	return nil
+}
+
+func (t *ResponseTemplate) validate() error {
+	if t == nil {
+		return nil
+	}
+	if t.Status != 0 && (t.Status < 400 || t.Status > 599) {
+		return fmt.Errorf("status must be a 4xx or 5xx code, got %d", t.Status)
+	}
+	if t.Body == "" {
+		return nil
+	}
+	var err error
+	if strings.Contains(t.ContentType, "html") {
+		_, err = htmltemplate.New("body").Funcs(TemplateFuncs).Parse(t.Body)
+	} else {
+		_, err = template.New("body").Funcs(TemplateFuncs).Parse(t.Body)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid body template: %w", err)
+	}
+	return nil
+}
+
+func loadRulesFile(cfg *Config) error {
+	path := strings.TrimSpace(os.Getenv("RATE_LIMIT_RULES_FILE"))
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read RATE_LIMIT_RULES_FILE: %w", err)
+	}
+	rules, err := ParseRules(data)
+	if err != nil {
+		return err
+	}
+	cfg.DefaultResponse = rules.DefaultResponse
+	cfg.Routes = append(cfg.Routes, rules.Routes...)
+	cfg.Tenants = rules.Tenants
+	return nil
+}
@@ -0,0 +1,37 @@
+package config
+
+import "testing"
+
+func TestParseRules(t *testing.T) {
+	rules, err := ParseRules([]byte(`{
+		"default_response": {"status": 429, "content_type": "text/plain", "body": "slow down, retry in {{.RetryAfterSeconds}}s"},
+		"routes": [{"name": "export", "path": "/export", "cost": 50}]
+	}`))
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if len(rules.Routes) != 1 || rules.Routes[0].Cost != 50 || rules.DefaultResponse.ContentType != "text/plain" {
+		t.Fatalf("unexpected rules: %+v", rules)
+	}
+}
+
+func TestParseRules_Invalid(t *testing.T) {
+	cases := map[string]string{
+		"unknown field":  `{"routez": []}`,
+		"relative path":  `{"routes": [{"path": "export"}]}`,
+		"negative cost":  `{"routes": [{"path": "/export", "cost": -1}]}`,
+		"bad status":     `{"default_response": {"status": 200}}`,
+		"bad template":   `{"routes": [{"path": "/x", "response": {"body": "{{.Message"}}]}`,
+		"bad html templ": `{"default_response": {"content_type": "text/html", "body": "{{end}}"}}`,
+		"tenant name":    `{"tenants": [{"name": "a:b"}]}`,
+		"dup tenant":     `{"tenants": [{"name": "a"}, {"name": "a"}]}`,
+		"shared host":    `{"tenants": [{"name": "a", "hosts": ["x.com"]}, {"name": "b", "hosts": ["X.com"]}]}`,
+		"tenant route":   `{"tenants": [{"name": "a", "routes": [{"path": "x"}]}]}`,
+		"adaptive min":   `{"routes": [{"path": "/export", "cost": 5, "adaptive_min_limit_per_second": 4}]}`,
+	}
+	for name, raw := range cases {
+		if _, err := ParseRules([]byte(raw)); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+// Package decision exposes the rate limit engine as a standalone service, so
+// many services (or an Envoy proxy) can share one place that decides allow/deny.
+//
+// Requests carry descriptors, lists of key/value entries as in Envoy's rate
+// limit service. Known keys feed the engine: the token header name (lowercased,
+// e.g. "api_key") or "token" for the token, "remote_address" or "ip" for the
+// client IP, "path" for the route and "tenant" for the tenant, which otherwise
+// defaults to the domain. Descriptors without token or IP are limited by the
+// joined entries themselves. Domains that name no tenant still scope every
+// identifier, so two domains never share counters.
+//
+// A request is allowed only if every descriptor is; when one denies it, what the
+// allowed descriptors were charged is given back.
+package decision
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"rate-limiter/pkg/ratelimit"
+)
+
+// Entry is one key/value pair of a descriptor.
+type Entry struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// Descriptor is the set of entries that identifies one limit to check.
+type Descriptor struct {
+	Entries []Entry `json:"entries"`
+}
+
+// CheckRequest asks whether the descriptors are within their limits.
+type CheckRequest struct {
+	Domain      string       `json:"domain"`
+	Descriptors []Descriptor `json:"descriptors"`
+	// HitsAddend is the cost charged to every descriptor; zero means one request.
+	HitsAddend int64 `json:"hits_addend,omitempty"`
+}
+
+// DescriptorStatus is the outcome for one descriptor, in request order.
+type DescriptorStatus struct {
+	Allowed           bool   `json:"allowed"`
+	Identifier        string `json:"identifier"`
+	LimitPerSecond    int64  `json:"limit_per_second"`
+	RetryAfterSeconds int64  `json:"retry_after_seconds,omitempty"`
+	// LimitedBy names the aggregate limit that denied the descriptor, if any.
+	LimitedBy string `json:"limited_by,omitempty"`
+	// QuotaExceeded is true when the token used up its long-period quota.
+	QuotaExceeded bool `json:"quota_exceeded,omitempty"`
+}
+
+// CheckResponse is the overall decision plus the headers the caller should add to its response.
+type CheckResponse struct {
+	Allowed  bool               `json:"allowed"`
+	Statuses []DescriptorStatus `json:"statuses"`
+	Headers  map[string]string  `json:"headers,omitempty"`
+}
+
+// Service evaluates descriptors against the engine.
+type Service struct {
+	engine *ratelimit.Engine
+}
+
+func NewService(e *ratelimit.Engine) *Service {
+	return &Service{engine: e}
+}
+
+// Check evaluates every descriptor; the request is denied if any of them is over its
+// limit, and then no descriptor keeps its charge.
+func (s *Service) Check(ctx context.Context, req CheckRequest) (CheckResponse, error) {
+	resp := CheckResponse{Allowed: true, Statuses: make([]DescriptorStatus, 0, len(req.Descriptors))}
+	var (
+		retryAfter time.Duration
+		allowed    []ratelimit.Decision
+	)
+	for _, d := range req.Descriptors {
+		dec, err := s.engine.Evaluate(ctx, s.toRequest(req.Domain, d, req.HitsAddend))
+		if err != nil {
+			return CheckResponse{}, err
+		}
+		if dec.Allowed {
+			allowed = append(allowed, dec)
+		}
+		st := DescriptorStatus{Allowed: dec.Allowed, Identifier: dec.Identifier, LimitPerSecond: dec.LimitPerSecond, LimitedBy: dec.LimitedBy, QuotaExceeded: dec.QuotaExceeded()}
+		if !dec.Allowed {
+			resp.Allowed = false
+			if dec.RetryAfter > 0 {
+				st.RetryAfterSeconds = int64(dec.RetryAfter.Round(time.Second) / time.Second)
+			}
+			if dec.RetryAfter > retryAfter {
+				retryAfter = dec.RetryAfter
+			}
+		}
+		resp.Statuses = append(resp.Statuses, st)
+	}
+	if !resp.Allowed {
+		for _, dec := range allowed {
+			if err := s.engine.Refund(ctx, dec); err != nil {
+				return CheckResponse{}, err
+			}
+		}
+	}
+	if !resp.Allowed && retryAfter > 0 {
+		resp.Headers = map[string]string{"Retry-After": ratelimit.FormatRetryAfter(retryAfter)}
+	}
+	return resp, nil
+}
+
+func (s *Service) toRequest(domain string, d Descriptor, hits int64) ratelimit.Request {
+	tokenKey := strings.ToLower(s.engine.Config().TokenHeader)
+	// The domain selects the tenant of the same name unless an entry names one, and
+	// scopes the identifier when no tenant matches.
+	req := ratelimit.Request{Cost: hits, Tenant: domain, Scope: domain}
+	rest := make([]string, 0, len(d.Entries))
+	for _, e := range d.Entries {
+		switch strings.ToLower(e.Key) {
+		case tokenKey, "token":
+			req.Token = strings.TrimSpace(e.Value)
+		case "remote_address", "ip":
+			req.IP = strings.TrimSpace(e.Value)
+		case "tenant":
+			req.Tenant = strings.TrimSpace(e.Value)
+			rest = append(rest, "tenant="+e.Value)
+		case "path":
+			req.Path = e.Value
+			rest = append(rest, "path="+e.Value)
+		default:
+			rest = append(rest, e.Key+"="+e.Value)
+		}
+	}
+	sort.Strings(rest)
+	req.Key = strings.Join(rest, ",")
+	return req
+}
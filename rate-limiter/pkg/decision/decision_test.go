@@ -0,0 +1,162 @@
+package decision
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"rate-limiter/pkg/config"
+	"rate-limiter/pkg/limiter"
+	"rate-limiter/pkg/ratelimit"
+	"rate-limiter/pkg/storage/memory"
+
+	commonv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/common/ratelimit/v3"
+	rlsv3 "github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v3"
+)
+
+// budgetChecker allows up to limit units per identifier and records what it was asked.
+type budgetChecker struct {
+	used map[string]int64
+}
+
+func (b *budgetChecker) Check(_ context.Context, identifier string, limit, cost int64, blockFor time.Duration, _ time.Time, _ ...limiter.Aggregate) (limiter.Result, error) {
+	b.used[identifier] += cost
+	if b.used[identifier] > limit {
+		return limiter.Result{Allowed: false, RetryAfter: blockFor}, nil
+	}
+	return limiter.Result{Allowed: true}, nil
+}
+
+func newTestService() *Service {
+	cfg := &config.Config{
+		Mode: config.ModeAuto, DefaultLimitPerSec: 2, DefaultBlockSeconds: 10, TokenHeader: "API_KEY",
+		TokenOverrides: map[string]config.TokenOverride{"premium": {LimitPerSecond: 100, BlockForSeconds: 20}},
+	}
+	return NewService(ratelimit.NewEngine(&budgetChecker{used: map[string]int64{}}, cfg))
+}
+
+func TestHandler_CheckDeniesOverLimit(t *testing.T) {
+	h := newTestService().Handler()
+	body := `{"domain":"api","descriptors":[{"entries":[{"key":"remote_address","value":"1.2.3.4"}]}]}`
+
+	var last CheckResponse
+	for i := 0; i < 3; i++ {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/check", bytes.NewBufferString(body)))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", rr.Code)
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&last); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if i < 2 && !last.Allowed {
+			t.Fatalf("unexpected deny at i=%d", i)
+		}
+	}
+	if last.Allowed {
+		t.Fatalf("expected deny on third check")
+	}
+	if last.Statuses[0].Identifier != "scope:api:ip:1.2.3.4" || last.Statuses[0].RetryAfterSeconds != 10 {
+		t.Fatalf("unexpected status: %+v", last.Statuses[0])
+	}
+	if last.Headers["Retry-After"] != "10" {
+		t.Fatalf("expected Retry-After header 10, got %q", last.Headers["Retry-After"])
+	}
+}
+
+func TestHandler_RejectsInvalidRequests(t *testing.T) {
+	h := newTestService().Handler()
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/check", nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/check", bytes.NewBufferString(`{"descriptors":[]}`)))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rr.Code)
+	}
+}
+
+func TestEnvoyServer_ShouldRateLimit(t *testing.T) {
+	srv := NewEnvoyServer(newTestService())
+	req := &rlsv3.RateLimitRequest{
+		Domain:     "edge",
+		HitsAddend: 2,
+		Descriptors: []*commonv3.RateLimitDescriptor{
+			{Entries: []*commonv3.RateLimitDescriptor_Entry{{Key: "api_key", Value: "premium"}}},
+			{Entries: []*commonv3.RateLimitDescriptor_Entry{{Key: "generic_key", Value: "search"}}},
+		},
+	}
+
+	resp, err := srv.ShouldRateLimit(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if resp.GetOverallCode() != rlsv3.RateLimitResponse_OK {
+		t.Fatalf("expected OK, got %v", resp.GetOverallCode())
+	}
+	if got := resp.GetStatuses()[0].GetCurrentLimit().GetRequestsPerUnit(); got != 100 {
+		t.Fatalf("expected premium limit 100, got %d", got)
+	}
+
+	// generic descriptor has default limit 2 and already spent 2 hits
+	resp, err = srv.ShouldRateLimit(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if resp.GetOverallCode() != rlsv3.RateLimitResponse_OVER_LIMIT {
+		t.Fatalf("expected OVER_LIMIT, got %v", resp.GetOverallCode())
+	}
+	if resp.GetStatuses()[0].GetCode() != rlsv3.RateLimitResponse_OK || resp.GetStatuses()[1].GetCode() != rlsv3.RateLimitResponse_OVER_LIMIT {
+		t.Fatalf("unexpected statuses: %v", resp.GetStatuses())
+	}
+	if len(resp.GetResponseHeadersToAdd()) != 1 || resp.GetResponseHeadersToAdd()[0].GetKey() != "Retry-After" {
+		t.Fatalf("expected Retry-After header, got %v", resp.GetResponseHeadersToAdd())
+	}
+}
+
+func TestService_CheckIsAllOrNothing(t *testing.T) {
+	cfg := &config.Config{Mode: config.ModeAuto, DefaultLimitPerSec: 2, TokenHeader: "API_KEY"}
+	svc := NewService(ratelimit.NewEngine(limiter.New(memory.New(nil)), cfg))
+	ctx := context.Background()
+	ip := func(v string) Descriptor { return Descriptor{Entries: []Entry{{Key: "remote_address", Value: v}}} }
+
+	// 5.6.7.8 has used its whole budget, so every request that includes it is denied
+	if resp, err := svc.Check(ctx, CheckRequest{Domain: "api", Descriptors: []Descriptor{ip("5.6.7.8")}, HitsAddend: 2}); err != nil || !resp.Allowed {
+		t.Fatalf("setup: %v %+v", err, resp)
+	}
+	for i := 0; i < 3; i++ {
+		resp, err := svc.Check(ctx, CheckRequest{Domain: "api", Descriptors: []Descriptor{ip("1.2.3.4"), ip("5.6.7.8")}})
+		if err != nil || resp.Allowed || !resp.Statuses[0].Allowed {
+			t.Fatalf("attempt %d: expected deny by the second descriptor only, got %v %+v", i, err, resp)
+		}
+	}
+	// the denied requests gave back their charge to 1.2.3.4
+	resp, err := svc.Check(ctx, CheckRequest{Domain: "api", Descriptors: []Descriptor{ip("1.2.3.4")}, HitsAddend: 2})
+	if err != nil || !resp.Allowed {
+		t.Fatalf("expected full budget for 1.2.3.4, got %v %+v", err, resp)
+	}
+}
+
+func TestService_DomainsDoNotShareCounters(t *testing.T) {
+	svc := newTestService()
+	ctx := context.Background()
+	desc := []Descriptor{{Entries: []Entry{{Key: "api_key", Value: "abc"}}}}
+
+	for _, domain := range []string{"edge", "internal"} {
+		resp, err := svc.Check(ctx, CheckRequest{Domain: domain, Descriptors: desc, HitsAddend: 2})
+		if err != nil || !resp.Allowed {
+			t.Fatalf("%s: expected allow, got %v %+v", domain, err, resp)
+		}
+		if want := "scope:" + domain + ":token:abc"; resp.Statuses[0].Identifier != want {
+			t.Fatalf("%s: identifier %q, want %q", domain, resp.Statuses[0].Identifier, want)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package decision
+
+import (
+	"context"
+	"math"
+	"time"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	rlsv3 "github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// EnvoyServer implements Envoy's envoy.service.ratelimit.v3.RateLimitService on top of the Service.
+type EnvoyServer struct {
+	rlsv3.UnimplementedRateLimitServiceServer
+	svc *Service
+}
+
+func NewEnvoyServer(svc *Service) *EnvoyServer {
+	return &EnvoyServer{svc: svc}
+}
+
+// Register adds the rate limit service to a gRPC server.
+func (s *EnvoyServer) Register(gs *grpc.Server) {
+	rlsv3.RegisterRateLimitServiceServer(gs, s)
+}
+
+func (s *EnvoyServer) ShouldRateLimit(ctx context.Context, in *rlsv3.RateLimitRequest) (*rlsv3.RateLimitResponse, error) {
+	if len(in.GetDescriptors()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "at least one descriptor is required")
+	}
+	req := CheckRequest{Domain: in.GetDomain(), HitsAddend: int64(in.GetHitsAddend())}
+	for _, d := range in.GetDescriptors() {
+		desc := Descriptor{Entries: make([]Entry, 0, len(d.GetEntries()))}
+		for _, e := range d.GetEntries() {
+			desc.Entries = append(desc.Entries, Entry{Key: e.GetKey(), Value: e.GetValue()})
+		}
+		req.Descriptors = append(req.Descriptors, desc)
+	}
+
+	resp, err := s.svc.Check(ctx, req)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	out := &rlsv3.RateLimitResponse{OverallCode: code(resp.Allowed)}
+	for _, st := range resp.Statuses {
+		ds := &rlsv3.RateLimitResponse_DescriptorStatus{
+			Code: code(st.Allowed),
+			CurrentLimit: &rlsv3.RateLimitResponse_RateLimit{
+				RequestsPerUnit: clampUint32(st.LimitPerSecond),
+				Unit:            rlsv3.RateLimitResponse_RateLimit_SECOND,
+			},
+		}
+		if st.RetryAfterSeconds > 0 {
+			ds.DurationUntilReset = durationpb.New(time.Duration(st.RetryAfterSeconds) * time.Second)
+		}
+		out.Statuses = append(out.Statuses, ds)
+	}
+	for k, v := range resp.Headers {
+		out.ResponseHeadersToAdd = append(out.ResponseHeadersToAdd, &corev3.HeaderValue{Key: k, Value: v})
+	}
+	return out, nil
+}
+
+func code(allowed bool) rlsv3.RateLimitResponse_Code {
+	if allowed {
+		return rlsv3.RateLimitResponse_OK
+	}
+	return rlsv3.RateLimitResponse_OVER_LIMIT
+}
+
+func clampUint32(n int64) uint32 {
+	if n < 0 {
+		return 0
+	}
+	if n > math.MaxUint32 {
+		return math.MaxUint32
+	}
+	return uint32(n)
+}
@@ -0,0 +1,36 @@
+package decision
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Handler serves POST /check: it decodes a CheckRequest and answers with a CheckResponse.
+// Denied checks still answer 200 with allowed=false; callers apply the returned headers.
+func (s *Service) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/check", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var req CheckRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+		if len(req.Descriptors) == 0 {
+			http.Error(w, "at least one descriptor is required", http.StatusBadRequest)
+			return
+		}
+		resp, err := s.Check(r.Context(), req)
+		if err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	})
+	return mux
+}
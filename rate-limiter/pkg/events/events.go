@@ -0,0 +1,65 @@
+// Package events delivers limiter block events to the security team: as log lines,
+// signed webhooks or entries in a Redis stream.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"rate-limiter/pkg/limiter"
+)
+
+// Payload is the JSON form of a block event shared by every sink.
+type Payload struct {
+	Type           string    `json:"type"`
+	Identifier     string    `json:"identifier"`
+	Rule           string    `json:"rule,omitempty"`
+	Count          int64     `json:"count"`
+	LimitPerSecond int64     `json:"limit_per_second"`
+	BlockSeconds   int64     `json:"block_seconds"`
+	At             time.Time `json:"at"`
+}
+
+// TypeBlocked is the Payload type of block events.
+const TypeBlocked = "identifier.blocked"
+
+// NewPayload converts a limiter event to its JSON form.
+func NewPayload(e limiter.BlockEvent) Payload {
+	return Payload{
+		Type:           TypeBlocked,
+		Identifier:     e.Identifier,
+		Rule:           e.Rule,
+		Count:          e.Count,
+		LimitPerSecond: e.LimitPerSecond,
+		BlockSeconds:   int64(e.BlockFor.Round(time.Second) / time.Second),
+		At:             e.At.UTC(),
+	}
+}
+
+// LogSink writes each event as a JSON log line.
+type LogSink struct {
+	logf func(format string, args ...any)
+}
+
+func NewLogSink() *LogSink {
+	return &LogSink{logf: log.Printf}
+}
+
+func (s *LogSink) Emit(_ context.Context, e limiter.BlockEvent) {
+	b, err := json.Marshal(NewPayload(e))
+	if err != nil {
+		return
+	}
+	s.logf("rate limit event: %s", b)
+}
+
+// Multi fans events out to several sinks.
+type Multi []limiter.EventSink
+
+func (m Multi) Emit(ctx context.Context, e limiter.BlockEvent) {
+	for _, s := range m {
+		s.Emit(ctx, e)
+	}
+}
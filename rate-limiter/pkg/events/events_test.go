@@ -0,0 +1,141 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"rate-limiter/pkg/limiter"
+
+	miniredis "github.com/alicebob/miniredis/v2"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+var testEvent = limiter.BlockEvent{
+	Identifier:     "ip:10.0.0.1",
+	Rule:           "login",
+	Count:          6,
+	LimitPerSecond: 5,
+	BlockFor:       5 * time.Minute,
+	At:             time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+}
+
+// receiver is a local webhook endpoint that verifies signatures and answers 503
+// to the first `failures` signed deliveries.
+type receiver struct {
+	secret   string
+	failures int32
+	attempts atomic.Int32
+	got      chan Payload
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	if !Verify(rc.secret, r.Header.Get(TimestampHeader), body, r.Header.Get(SignatureHeader)) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if rc.attempts.Add(1) <= rc.failures {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	var p Payload
+	_ = json.Unmarshal(body, &p)
+	rc.got <- p
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func TestWebhookSink_SignsAndRetries(t *testing.T) {
+	rc := &receiver{secret: "s3cret", failures: 2, got: make(chan Payload, 1)}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+
+	sink := NewWebhookSink(WebhookConfig{URL: srv.URL, Secret: "s3cret", Retries: 3, Backoff: time.Millisecond})
+	sink.Emit(context.Background(), testEvent)
+
+	select {
+	case p := <-rc.got:
+		if p.Type != TypeBlocked || p.Identifier != "ip:10.0.0.1" || p.Rule != "login" || p.Count != 6 || p.BlockSeconds != 300 {
+			t.Fatalf("unexpected payload %+v", p)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("event not delivered")
+	}
+	if n := rc.attempts.Load(); n != 3 {
+		t.Fatalf("expected 3 attempts, got %d", n)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := sink.Close(ctx); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+}
+
+func TestWebhookSink_GivesUpOnWrongSecret(t *testing.T) {
+	rc := &receiver{secret: "expected", got: make(chan Payload, 1)}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+
+	sink := NewWebhookSink(WebhookConfig{URL: srv.URL, Secret: "other", Retries: 3, Backoff: time.Millisecond})
+	sink.logf = func(string, ...any) {}
+	sink.Emit(context.Background(), testEvent)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := sink.Close(ctx); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if len(rc.got) != 0 || rc.attempts.Load() != 0 {
+		t.Fatalf("expected unsigned delivery to be rejected without retries")
+	}
+}
+
+func TestWebhookSink_EmitAfterCloseIsDropped(t *testing.T) {
+	rc := &receiver{secret: "s3cret", got: make(chan Payload, 1)}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+
+	sink := NewWebhookSink(WebhookConfig{URL: srv.URL, Secret: "s3cret"})
+	var dropped atomic.Int32
+	sink.logf = func(string, ...any) { dropped.Add(1) }
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := sink.Close(ctx); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	sink.Emit(context.Background(), testEvent)
+	if err := sink.Close(ctx); err != nil {
+		t.Fatalf("second close: %v", err)
+	}
+	if dropped.Load() != 1 {
+		t.Fatalf("expected the late event to be logged as dropped")
+	}
+	if n := rc.attempts.Load(); n != 0 {
+		t.Fatalf("expected no delivery after close, got %d attempts", n)
+	}
+}
+
+func TestStreamSink_AppendsEntry(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis: %v", err)
+	}
+	defer mr.Close()
+	rdb := goredis.NewClient(&goredis.Options{Addr: mr.Addr()})
+
+	NewStreamSink(rdb, "rl:events", 100).Emit(context.Background(), testEvent)
+
+	entries, err := rdb.XRange(context.Background(), "rl:events", "-", "+").Result()
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected one entry, got %v (%v)", entries, err)
+	}
+	v := entries[0].Values
+	if v["identifier"] != "ip:10.0.0.1" || v["rule"] != "login" || v["block_seconds"] != "300" {
+		t.Fatalf("unexpected entry %v", v)
+	}
+}
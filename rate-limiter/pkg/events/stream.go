@@ -0,0 +1,45 @@
+package events
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"rate-limiter/pkg/limiter"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// StreamSink appends events to a Redis stream with XADD, trimmed to about MaxLen
+// entries, for consumers that read with XREAD or consumer groups.
+type StreamSink struct {
+	client *goredis.Client
+	stream string
+	maxLen int64
+	logf   func(format string, args ...any)
+}
+
+func NewStreamSink(client *goredis.Client, stream string, maxLen int64) *StreamSink {
+	return &StreamSink{client: client, stream: stream, maxLen: maxLen, logf: log.Printf}
+}
+
+func (s *StreamSink) Emit(ctx context.Context, e limiter.BlockEvent) {
+	p := NewPayload(e)
+	err := s.client.XAdd(ctx, &goredis.XAddArgs{
+		Stream: s.stream,
+		MaxLen: s.maxLen,
+		Approx: s.maxLen > 0,
+		Values: map[string]any{
+			"type":             p.Type,
+			"identifier":       p.Identifier,
+			"rule":             p.Rule,
+			"count":            p.Count,
+			"limit_per_second": p.LimitPerSecond,
+			"block_seconds":    p.BlockSeconds,
+			"at":               p.At.Format(time.RFC3339Nano),
+		},
+	}).Err()
+	if err != nil {
+		s.logf("failed to append event for %s to stream %s: %v", e.Identifier, s.stream, err)
+	}
+}
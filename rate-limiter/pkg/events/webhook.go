@@ -0,0 +1,174 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"rate-limiter/pkg/limiter"
+)
+
+// SignatureHeader carries "sha256=<hex>", the HMAC-SHA256 of TimestampHeader, a
+// dot and the raw body, keyed with the shared secret.
+const SignatureHeader = "X-RateLimit-Signature"
+
+// TimestampHeader carries the Unix time of the delivery attempt, so receivers
+// can reject replays.
+const TimestampHeader = "X-RateLimit-Timestamp"
+
+// WebhookConfig configures a WebhookSink.
+type WebhookConfig struct {
+	URL    string
+	Secret string
+	// Retries is how many times a failed delivery is retried, with exponential backoff.
+	Retries int
+	Timeout time.Duration
+	// Backoff is the wait before the first retry; it doubles on each attempt.
+	Backoff time.Duration
+	// QueueSize bounds pending deliveries; events beyond it are dropped and logged.
+	QueueSize int
+}
+
+// WebhookSink POSTs events as JSON from a background worker, so the request path
+// never waits on the receiver.
+type WebhookSink struct {
+	cfg    WebhookConfig
+	client *http.Client
+	queue  chan Payload
+	done   chan struct{}
+	logf   func(format string, args ...any)
+	// mu guards closed so Emit never sends on the queue after Close closed it;
+	// handlers still running past the server shutdown may emit late events.
+	mu     sync.Mutex
+	closed bool
+}
+
+func NewWebhookSink(cfg WebhookConfig) *WebhookSink {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = 500 * time.Millisecond
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 1024
+	}
+	s := &WebhookSink{
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+		queue:  make(chan Payload, cfg.QueueSize),
+		done:   make(chan struct{}),
+		logf:   log.Printf,
+	}
+	go s.run()
+	return s
+}
+
+func (s *WebhookSink) Emit(_ context.Context, e limiter.BlockEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		s.logf("webhook sink closed, dropping event for %s", e.Identifier)
+		return
+	}
+	select {
+	case s.queue <- NewPayload(e):
+	default:
+		s.logf("webhook queue full, dropping event for %s", e.Identifier)
+	}
+}
+
+// Close stops accepting events and waits until the queued ones are delivered or ctx ends.
+func (s *WebhookSink) Close(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+	s.mu.Unlock()
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *WebhookSink) run() {
+	defer close(s.done)
+	for p := range s.queue {
+		if err := s.deliver(p); err != nil {
+			s.logf("webhook delivery failed for %s: %v", p.Identifier, err)
+		}
+	}
+}
+
+func (s *WebhookSink) deliver(p Payload) error {
+	body, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	backoff := s.cfg.Backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := s.post(body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= s.cfg.Retries {
+			return err
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// post sends one attempt and reports whether a failure is worth retrying.
+func (s *WebhookSink) post(body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, s.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.cfg.Secret != "" {
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(TimestampHeader, ts)
+		req.Header.Set(SignatureHeader, Sign(s.cfg.Secret, ts, body))
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("receiver answered %s", resp.Status)
+	default:
+		return false, fmt.Errorf("receiver answered %s", resp.Status)
+	}
+}
+
+// Sign returns the SignatureHeader value for a delivery.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a delivery signature in constant time; receivers should also
+// reject timestamps that are too old.
+func Verify(secret, timestamp string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
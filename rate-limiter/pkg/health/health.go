@@ -0,0 +1,76 @@
+// Package health serves liveness and readiness probes outside the rate limit.
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"rate-limiter/pkg/storage"
+)
+
+var errDraining = errors.New("server is draining")
+
+const (
+	LivenessPath  = "/healthz"
+	ReadinessPath = "/readyz"
+)
+
+// Probe answers /healthz while the process runs and /readyz while the store is
+// reachable and the server is not draining.
+type Probe struct {
+	store    storage.Pinger
+	timeout  time.Duration
+	draining atomic.Bool
+}
+
+// NewProbe builds a probe; a nil store makes readiness depend only on draining.
+func NewProbe(store storage.Pinger) *Probe {
+	return &Probe{store: store, timeout: time.Second}
+}
+
+// SetDraining marks the server as shutting down so load balancers stop sending traffic.
+func (p *Probe) SetDraining() {
+	p.draining.Store(true)
+}
+
+// Ready reports whether the server can take traffic.
+func (p *Probe) Ready(ctx context.Context) error {
+	if p.draining.Load() {
+		return errDraining
+	}
+	if p.store == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+	return p.store.Ping(ctx)
+}
+
+// Wrap serves the probe endpoints itself and passes everything else to next,
+// so probes never consume rate limit budget.
+func (p *Probe) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case LivenessPath:
+			writeStatus(w, http.StatusOK, `{"status":"ok"}`)
+		case ReadinessPath:
+			if err := p.Ready(r.Context()); err != nil {
+				writeStatus(w, http.StatusServiceUnavailable, `{"status":"unavailable"}`)
+				return
+			}
+			writeStatus(w, http.StatusOK, `{"status":"ready"}`)
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+func writeStatus(w http.ResponseWriter, code int, body string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	_, _ = w.Write([]byte(body))
+}
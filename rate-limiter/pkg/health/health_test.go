@@ -0,0 +1,73 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type fakePinger struct {
+	err error
+}
+
+func (f *fakePinger) Ping(context.Context) error { return f.err }
+
+func TestProbe_BypassesNextHandler(t *testing.T) {
+	nextCalls := 0
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nextCalls++
+		w.WriteHeader(http.StatusTeapot)
+	})
+	h := NewProbe(&fakePinger{}).Wrap(next)
+
+	for _, path := range []string{LivenessPath, ReadinessPath} {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d", path, rr.Code)
+		}
+	}
+	if nextCalls != 0 {
+		t.Fatalf("probes must not reach the limited handler")
+	}
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rr.Code != http.StatusTeapot {
+		t.Fatalf("expected other paths to reach next, got %d", rr.Code)
+	}
+}
+
+func TestProbe_ReadinessReflectsStoreAndDraining(t *testing.T) {
+	store := &fakePinger{err: errors.New("connection refused")}
+	p := NewProbe(store)
+	h := p.Wrap(http.NotFoundHandler())
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, ReadinessPath, nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 when store is down, got %d", rr.Code)
+	}
+
+	store.err = nil
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, ReadinessPath, nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 when store is up, got %d", rr.Code)
+	}
+
+	p.SetDraining()
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, ReadinessPath, nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 while draining, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, LivenessPath, nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("liveness should stay 200 while draining, got %d", rr.Code)
+	}
+}
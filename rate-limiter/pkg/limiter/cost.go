@@ -0,0 +1,20 @@
+package limiter
+
+import "context"
+
+type costKey struct{}
+
+// WithCost returns a context that carries the cost a request should be charged.
+// Layers running before the rate limit middleware use it to declare expensive requests.
+func WithCost(ctx context.Context, cost int64) context.Context {
+	return context.WithValue(ctx, costKey{}, cost)
+}
+
+// CostFromContext returns the cost stored by WithCost, if any.
+func CostFromContext(ctx context.Context) (int64, bool) {
+	cost, ok := ctx.Value(costKey{}).(int64)
+	if !ok || cost < 1 {
+		return 0, false
+	}
+	return cost, true
+}
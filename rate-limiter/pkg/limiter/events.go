@@ -0,0 +1,43 @@
+package limiter
+
+import (
+	"context"
+	"time"
+)
+
+// BlockEvent is emitted when Check blocks an identifier for exceeding its limit.
+type BlockEvent struct {
+	Identifier string
+	// Rule is the rule name carried by WithRule, empty when the caller set none.
+	Rule string
+	// Count is the window counter that went over LimitPerSecond.
+	Count          int64
+	LimitPerSecond int64
+	BlockFor       time.Duration
+	At             time.Time
+}
+
+// EventSink receives block events. Emit runs on the request path, so sinks that
+// talk to slow destinations should queue and deliver in the background.
+type EventSink interface {
+	Emit(ctx context.Context, e BlockEvent)
+}
+
+// WithEventSink emits a BlockEvent to sink every time Check sets a block.
+func WithEventSink(sink EventSink) Option {
+	return func(l *Limiter) { l.sink = sink }
+}
+
+type ruleKey struct{}
+
+// WithRule returns a context that names the rule a Check call enforces, so block
+// events can report it.
+func WithRule(ctx context.Context, rule string) context.Context {
+	return context.WithValue(ctx, ruleKey{}, rule)
+}
+
+// RuleFromContext returns the rule name stored by WithRule, if any.
+func RuleFromContext(ctx context.Context) (string, bool) {
+	rule, ok := ctx.Value(ruleKey{}).(string)
+	return rule, ok && rule != ""
+}
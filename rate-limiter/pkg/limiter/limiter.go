@@ -0,0 +1,200 @@
+package limiter
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"rate-limiter/pkg/storage"
+)
+
+type Result struct {
+	Allowed    bool
+	RetryAfter time.Duration
+	// Aggregate is the key of the aggregate limit that denied the request; empty when
+	// the request was allowed or denied by its own identifier limit.
+	Aggregate string
+	// charged are the window counters an allowed Check incremented, for Refund.
+	charged []string
+}
+
+// Aggregate is a limit shared by every identifier, such as a per-route or server-wide cap.
+type Aggregate struct {
+	Key            string
+	LimitPerSecond int64
+}
+
+// Checker is the contract used by the middleware to evaluate rate limits.
+type Checker interface {
+	Check(ctx context.Context, identifier string, limitPerSecond, cost int64, blockFor time.Duration, now time.Time, aggregates ...Aggregate) (Result, error)
+}
+
+// Refunder gives back what an allowed Check charged. Callers that decide on several
+// checks together use it so a request denied by one of them spends none of the others.
+type Refunder interface {
+	Refund(ctx context.Context, res Result, cost int64) error
+}
+
+type Limiter struct {
+	store     storage.CounterStore
+	storeTime bool
+	windows   storage.WindowStore
+	sink      EventSink
+}
+
+// Option customizes a Limiter.
+type Option func(*Limiter)
+
+// WithStoreClock derives window boundaries from the store clock instead of the now
+// passed to Check, so instances with skewed clocks count in the same buckets.
+// Stores that do not implement storage.WindowStore keep using the local clock.
+func WithStoreClock() Option {
+	return func(l *Limiter) { l.storeTime = true }
+}
+
+func New(store storage.CounterStore, opts ...Option) *Limiter {
+	l := &Limiter{store: store}
+	for _, opt := range opts {
+		opt(l)
+	}
+	if ws, ok := store.(storage.WindowStore); ok && l.storeTime {
+		l.windows = ws
+	}
+	return l
+}
+
+// Check increases the counter for the identifier by cost within a 1s window and decides allow/deny.
+// A cost below 1 is charged as a single request.
+//
+// Aggregates are evaluated after the identifier limit, so blocked or over-limit clients
+// never consume shared budget. When an aggregate is exceeded the request is denied
+// without blocking anyone, and the cost already charged in this call is given back.
+//
+// A cost above a limit can never fit in one window, so such a request is denied
+// without being charged or blocking the client, and with no RetryAfter: retrying
+// will not help.
+func (l *Limiter) Check(ctx context.Context, identifier string, limitPerSecond, cost int64, blockFor time.Duration, now time.Time, aggregates ...Aggregate) (Result, error) {
+	if cost < 1 {
+		cost = 1
+	}
+	w := window{limiter: l, sec: now.Unix(), resolved: l.windows == nil}
+
+	var charged []string
+	if limitPerSecond > 0 {
+		// First, check blocked state
+		blocked, ttl, err := l.store.IsBlocked(ctx, identifier)
+		if err != nil {
+			return Result{}, err
+		}
+		if blocked {
+			return Result{Allowed: false, RetryAfter: ttl}, nil
+		}
+		if cost > limitPerSecond {
+			return Result{Allowed: false}, nil
+		}
+
+		// Window key by epoch second
+		count, key, err := w.incr(ctx, CounterPrefix(identifier), cost)
+		if err != nil {
+			return Result{}, err
+		}
+		if count > limitPerSecond {
+			// Exceeded. Block further requests for blockFor duration.
+			if blockFor > 0 {
+				if err := l.store.SetBlock(ctx, identifier, blockFor); err != nil {
+					return Result{}, err
+				}
+				if l.sink != nil {
+					rule, _ := RuleFromContext(ctx)
+					l.sink.Emit(ctx, BlockEvent{Identifier: identifier, Rule: rule, Count: count, LimitPerSecond: limitPerSecond, BlockFor: blockFor, At: now})
+				}
+			}
+			return Result{Allowed: false, RetryAfter: blockFor}, nil
+		}
+		charged = append(charged, key)
+	}
+
+	for _, agg := range aggregates {
+		if agg.LimitPerSecond <= 0 {
+			continue
+		}
+		if cost > agg.LimitPerSecond {
+			if err := l.refund(ctx, charged, cost); err != nil {
+				return Result{}, err
+			}
+			return Result{Allowed: false, Aggregate: agg.Key}, nil
+		}
+		count, key, err := w.incr(ctx, AggregatePrefix(agg.Key), cost)
+		if err != nil {
+			return Result{}, err
+		}
+		if count > agg.LimitPerSecond {
+			// Refund this window so the denied request does not eat budget it never used.
+			if err := l.refund(ctx, append(charged, key), cost); err != nil {
+				return Result{}, err
+			}
+			return Result{Allowed: false, RetryAfter: untilNextWindow(now), Aggregate: agg.Key}, nil
+		}
+		charged = append(charged, key)
+	}
+	return Result{Allowed: true, charged: charged}, nil
+}
+
+// Refund gives back the cost charged by the allowed Check that returned res. A block
+// already set is kept, but allowed checks never set one.
+func (l *Limiter) Refund(ctx context.Context, res Result, cost int64) error {
+	if cost < 1 {
+		cost = 1
+	}
+	return l.refund(ctx, res.charged, cost)
+}
+
+func (l *Limiter) refund(ctx context.Context, keys []string, cost int64) error {
+	for _, k := range keys {
+		if _, err := l.store.Incr(ctx, k, -cost, time.Second); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// window pins every counter touched by one Check to the same second. With the store
+// clock, the first increment asks the store which second it is.
+type window struct {
+	limiter  *Limiter
+	sec      int64
+	resolved bool
+}
+
+func (w *window) incr(ctx context.Context, prefix string, cost int64) (int64, string, error) {
+	if !w.resolved {
+		count, start, err := w.limiter.windows.IncrWindow(ctx, prefix, cost, time.Second)
+		if err != nil {
+			return 0, "", err
+		}
+		w.sec, w.resolved = start, true
+		return count, windowKey(prefix, start), nil
+	}
+	key := windowKey(prefix, w.sec)
+	count, err := w.limiter.store.Incr(ctx, key, cost, time.Second)
+	return count, key, err
+}
+
+// CounterPrefix is the key prefix of an identifier's per-second counters; each
+// window is stored under CounterPrefix(id) + ":" + epoch second.
+func CounterPrefix(identifier string) string {
+	return "rl:cnt:" + identifier
+}
+
+// AggregatePrefix is the key prefix of an aggregate's per-second counters.
+func AggregatePrefix(key string) string {
+	return "rl:agg:" + key
+}
+
+func windowKey(prefix string, epochSec int64) string {
+	return fmt.Sprintf("%s:%d", prefix, epochSec)
+}
+
+func untilNextWindow(now time.Time) time.Duration {
+	return now.Truncate(time.Second).Add(time.Second).Sub(now)
+}
@@ -0,0 +1,49 @@
+package limiter
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Run with: go test ./pkg/limiter -run '^$' -bench . -benchmem
+
+func BenchmarkLimiter_Check(b *testing.B) {
+	for _, clock := range stressClocks {
+		for _, target := range stressTargets {
+			b.Run(clock.name+"/"+target.name, func(b *testing.B) {
+				benchmarkCheck(b, target.setup(b)(clock.opts...))
+			})
+		}
+	}
+}
+
+func BenchmarkLimiter_CheckWithAggregates(b *testing.B) {
+	for _, target := range stressTargets {
+		b.Run(target.name, func(b *testing.B) {
+			benchmarkCheck(b, target.setup(b)(), Aggregate{Key: "route:search", LimitPerSecond: 1 << 40}, Aggregate{Key: "global", LimitPerSecond: 1 << 40})
+		})
+	}
+}
+
+// benchmarkCheck spreads parallel checks over many identifiers with limits that are
+// never reached, measuring the allowed path, and reports ops/sec.
+func benchmarkCheck(b *testing.B, lim *Limiter, aggregates ...Aggregate) {
+	var seq atomic.Int64
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	start := time.Now()
+	b.RunParallel(func(pb *testing.PB) {
+		id := "ip:10.0.0." + strconv.FormatInt(seq.Add(1), 10)
+		for pb.Next() {
+			if _, err := lim.Check(ctx, id, 1<<40, 1, 0, stressStart, aggregates...); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+	b.ReportMetric(float64(b.N)/time.Since(start).Seconds(), "ops/s")
+}
@@ -0,0 +1,240 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"rate-limiter/pkg/storage/memory"
+	redispkg "rate-limiter/pkg/storage/redis"
+
+	miniredis "github.com/alicebob/miniredis/v2"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+func newTestLimiter(t *testing.T) (*Limiter, func()) {
+	t.Helper()
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis: %v", err)
+	}
+	rdb := goredis.NewClient(&goredis.Options{Addr: mr.Addr()})
+	store := redispkg.New(rdb)
+	lim := New(store)
+	return lim, func() { mr.Close() }
+}
+
+func TestLimiter_AllowsUnderLimit(t *testing.T) {
+	lim, cleanup := newTestLimiter(t)
+	defer cleanup()
+	ctx := context.Background()
+	now := time.Unix(1_700_000_000, 0)
+	for i := 0; i < 5; i++ {
+		res, err := lim.Check(ctx, "ip:1.2.3.4", 5, 1, 10*time.Second, now)
+		if err != nil {
+			t.Fatalf("check err: %v", err)
+		}
+		if !res.Allowed {
+			t.Fatalf("unexpected deny at i=%d", i)
+		}
+	}
+}
+
+func TestLimiter_BlocksOnExceedAndSetsBlock(t *testing.T) {
+	lim, cleanup := newTestLimiter(t)
+	defer cleanup()
+	ctx := context.Background()
+	now := time.Unix(1_700_000_000, 0)
+
+	// limit 2 rps, block 5s
+	for i := 0; i < 2; i++ {
+		res, err := lim.Check(ctx, "token:abc", 2, 1, 5*time.Second, now)
+		if err != nil || !res.Allowed {
+			t.Fatalf("warmup err: %v allowed=%v", err, res.Allowed)
+		}
+	}
+	res, err := lim.Check(ctx, "token:abc", 2, 1, 5*time.Second, now)
+	if err != nil {
+		t.Fatalf("check err: %v", err)
+	}
+	if res.Allowed {
+		t.Fatalf("expected deny on exceed")
+	}
+
+	// still blocked next second during block window
+	res, err = lim.Check(ctx, "token:abc", 2, 1, 5*time.Second, now.Add(1*time.Second))
+	if err != nil {
+		t.Fatalf("check err: %v", err)
+	}
+	if res.Allowed {
+		t.Fatalf("expected blocked due to SetBlock")
+	}
+}
+
+func TestLimiter_WeightedCostConsumesBudget(t *testing.T) {
+	lim, cleanup := newTestLimiter(t)
+	defer cleanup()
+	ctx := context.Background()
+	now := time.Unix(1_700_000_000, 0)
+
+	// limit 10 per second, each export costs 5
+	for i := 0; i < 2; i++ {
+		res, err := lim.Check(ctx, "ip:1.2.3.4", 10, 5, 0, now)
+		if err != nil || !res.Allowed {
+			t.Fatalf("export %d err: %v allowed=%v", i, err, res.Allowed)
+		}
+	}
+	res, err := lim.Check(ctx, "ip:1.2.3.4", 10, 1, 0, now)
+	if err != nil {
+		t.Fatalf("check err: %v", err)
+	}
+	if res.Allowed {
+		t.Fatalf("expected deny once weighted budget is spent")
+	}
+
+	// next window starts fresh
+	res, err = lim.Check(ctx, "ip:1.2.3.4", 10, 5, 0, now.Add(time.Second))
+	if err != nil || !res.Allowed {
+		t.Fatalf("expected allow in next window, err: %v allowed=%v", err, res.Allowed)
+	}
+}
+
+func TestLimiter_CostAboveLimitDeniesWithoutBlocking(t *testing.T) {
+	lim, cleanup := newTestLimiter(t)
+	defer cleanup()
+	ctx := context.Background()
+	now := time.Unix(1_700_000_000, 0)
+
+	// an export costing 50 can never fit a 2 rps limit
+	res, err := lim.Check(ctx, "ip:1.2.3.4", 2, 50, 10*time.Second, now)
+	if err != nil {
+		t.Fatalf("check err: %v", err)
+	}
+	if res.Allowed || res.RetryAfter != 0 {
+		t.Fatalf("expected deny without retry hint, got %+v", res)
+	}
+	// neither blocked nor charged: the full budget is still there
+	for i := 0; i < 2; i++ {
+		res, err = lim.Check(ctx, "ip:1.2.3.4", 2, 1, 10*time.Second, now)
+		if err != nil || !res.Allowed {
+			t.Fatalf("client should keep its budget at i=%d: %v %+v", i, err, res)
+		}
+	}
+
+	// same for an aggregate, which also gives back the client's charge
+	route := Aggregate{Key: "route:export", LimitPerSecond: 3}
+	res, err = lim.Check(ctx, "ip:5.6.7.8", 10, 5, 10*time.Second, now, route)
+	if err != nil || res.Allowed || res.Aggregate != "route:export" {
+		t.Fatalf("expected aggregate deny, err: %v %+v", err, res)
+	}
+	res, err = lim.Check(ctx, "ip:5.6.7.8", 10, 10, 10*time.Second, now)
+	if err != nil || !res.Allowed {
+		t.Fatalf("expected refunded budget, err: %v %+v", err, res)
+	}
+}
+
+func TestLimiter_AggregateCapsAllIdentifiers(t *testing.T) {
+	lim, cleanup := newTestLimiter(t)
+	defer cleanup()
+	ctx := context.Background()
+	now := time.Unix(1_700_000_000, 250_000_000)
+	search := Aggregate{Key: "route:search", LimitPerSecond: 3}
+
+	// three different clients, each well under its own limit, fill the route
+	for _, id := range []string{"ip:1.1.1.1", "ip:2.2.2.2", "ip:3.3.3.3"} {
+		res, err := lim.Check(ctx, id, 10, 1, 5*time.Second, now, search)
+		if err != nil || !res.Allowed {
+			t.Fatalf("%s err: %v allowed=%v", id, err, res.Allowed)
+		}
+	}
+	res, err := lim.Check(ctx, "ip:4.4.4.4", 10, 1, 5*time.Second, now, search)
+	if err != nil {
+		t.Fatalf("check err: %v", err)
+	}
+	if res.Allowed || res.Aggregate != "route:search" {
+		t.Fatalf("expected aggregate deny, got %+v", res)
+	}
+	if res.RetryAfter != 750*time.Millisecond {
+		t.Fatalf("expected retry at next window, got %s", res.RetryAfter)
+	}
+
+	// aggregate denial neither blocks the client nor charges its own budget
+	for i := 0; i < 10; i++ {
+		res, err = lim.Check(ctx, "ip:4.4.4.4", 10, 1, 5*time.Second, now.Add(time.Second))
+		if err != nil || !res.Allowed {
+			t.Fatalf("client should be free without the aggregate at i=%d: %v %+v", i, err, res)
+		}
+	}
+}
+
+func TestLimiter_AggregateRefundsOnDeny(t *testing.T) {
+	lim, cleanup := newTestLimiter(t)
+	defer cleanup()
+	ctx := context.Background()
+	now := time.Unix(1_700_000_000, 0)
+	route := Aggregate{Key: "route:export", LimitPerSecond: 100}
+	global := Aggregate{Key: "global", LimitPerSecond: 5}
+
+	res, err := lim.Check(ctx, "ip:1.1.1.1", 10, 5, 0, now, route, global)
+	if err != nil || !res.Allowed {
+		t.Fatalf("first err: %v %+v", err, res)
+	}
+	// global denies; route and client counters must be given back
+	res, err = lim.Check(ctx, "ip:1.1.1.1", 10, 5, 0, now, route, global)
+	if err != nil || res.Allowed || res.Aggregate != "global" {
+		t.Fatalf("expected global deny, err: %v %+v", err, res)
+	}
+	// client has 5 left of 10 and the route has 95 left of 100
+	res, err = lim.Check(ctx, "ip:1.1.1.1", 10, 5, 0, now, route)
+	if err != nil || !res.Allowed {
+		t.Fatalf("expected refunded budget, err: %v %+v", err, res)
+	}
+}
+
+func TestLimiter_RefundGivesBackAllowedCheck(t *testing.T) {
+	lim, cleanup := newTestLimiter(t)
+	defer cleanup()
+	ctx := context.Background()
+	now := time.Unix(1_700_000_000, 0)
+	global := Aggregate{Key: "global", LimitPerSecond: 4}
+
+	res, err := lim.Check(ctx, "ip:1.1.1.1", 4, 4, 0, now, global)
+	if err != nil || !res.Allowed {
+		t.Fatalf("first err: %v %+v", err, res)
+	}
+	if err := lim.Refund(ctx, res, 4); err != nil {
+		t.Fatalf("refund: %v", err)
+	}
+	// both the client and the aggregate have their full budget again
+	res, err = lim.Check(ctx, "ip:1.1.1.1", 4, 4, 0, now, global)
+	if err != nil || !res.Allowed {
+		t.Fatalf("expected refunded budget, err: %v %+v", err, res)
+	}
+}
+
+type recordingSink struct {
+	events []BlockEvent
+}
+
+func (r *recordingSink) Emit(_ context.Context, e BlockEvent) {
+	r.events = append(r.events, e)
+}
+
+func TestLimiter_EmitsEventWhenBlocking(t *testing.T) {
+	sink := &recordingSink{}
+	lim := New(memory.New(nil), WithEventSink(sink))
+	ctx := WithRule(context.Background(), "export")
+	now := time.Unix(1_700_000_000, 0)
+
+	for i := 0; i < 4; i++ {
+		_, _ = lim.Check(ctx, "token:abc", 2, 1, 5*time.Second, now)
+	}
+	// Further requests hit the existing block and do not emit again.
+	if len(sink.events) != 1 {
+		t.Fatalf("expected exactly one event, got %d", len(sink.events))
+	}
+	e := sink.events[0]
+	if e.Identifier != "token:abc" || e.Rule != "export" || e.Count != 3 || e.LimitPerSecond != 2 || e.BlockFor != 5*time.Second || !e.At.Equal(now) {
+		t.Fatalf("unexpected event %+v", e)
+	}
+}
@@ -0,0 +1,81 @@
+package limiter
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Clock is a store that can report its own time.
+type Clock interface {
+	Now(ctx context.Context) (time.Time, error)
+}
+
+// SkewDetector compares the local clock with the store clock and logs when they
+// diverge, since local-clock windows put skewed instances in different buckets.
+type SkewDetector struct {
+	store     Clock
+	threshold time.Duration
+	now       func() time.Time
+	logf      func(format string, args ...any)
+}
+
+func NewSkewDetector(store Clock, threshold time.Duration) *SkewDetector {
+	return &SkewDetector{store: store, threshold: threshold, now: time.Now, logf: log.Printf}
+}
+
+// Measure returns store time minus local time, compensating half the round trip.
+func (d *SkewDetector) Measure(ctx context.Context) (time.Duration, error) {
+	sent := d.now()
+	remote, err := d.store.Now(ctx)
+	if err != nil {
+		return 0, err
+	}
+	received := d.now()
+	local := sent.Add(received.Sub(sent) / 2)
+	return remote.Sub(local), nil
+}
+
+// Check measures once and logs when the skew exceeds the threshold.
+func (d *SkewDetector) Check(ctx context.Context) (time.Duration, error) {
+	skew, err := d.Measure(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if skew > d.threshold || skew < -d.threshold {
+		d.logf("clock skew detected: local clock is %s %s the store clock (threshold %s)",
+			abs(skew), direction(skew), d.threshold)
+	}
+	return skew, nil
+}
+
+// Run checks every interval until ctx is done.
+func (d *SkewDetector) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := d.Check(ctx); err != nil && ctx.Err() == nil {
+			d.logf("clock skew check failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func abs(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+func direction(skew time.Duration) string {
+	// positive skew: the store is ahead, so the local clock is behind
+	if skew > 0 {
+		return "behind"
+	}
+	return "ahead of"
+}
@@ -0,0 +1,124 @@
+package limiter
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	redispkg "rate-limiter/pkg/storage/redis"
+
+	miniredis "github.com/alicebob/miniredis/v2"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// newSkewedInstances returns two limiters sharing one Redis, like two pods.
+func newSkewedInstances(t *testing.T, opts ...Option) (*miniredis.Miniredis, *Limiter, *Limiter) {
+	t.Helper()
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis: %v", err)
+	}
+	t.Cleanup(mr.Close)
+	newInstance := func() *Limiter {
+		rdb := goredis.NewClient(&goredis.Options{Addr: mr.Addr()})
+		return New(redispkg.New(rdb), opts...)
+	}
+	return mr, newInstance(), newInstance()
+}
+
+func TestLimiter_StoreClockSharesWindowAcrossSkewedInstances(t *testing.T) {
+	mr, podA, podB := newSkewedInstances(t, WithStoreClock())
+	ctx := context.Background()
+	storeNow := time.Unix(1_700_000_000, 400_000_000)
+	mr.SetTime(storeNow)
+
+	// pod A runs 600ms behind and pod B 700ms ahead, so their local seconds differ
+	localA := storeNow.Add(-600 * time.Millisecond)
+	localB := storeNow.Add(700 * time.Millisecond)
+	if localA.Unix() == localB.Unix() {
+		t.Fatalf("test setup: local clocks should fall in different seconds")
+	}
+
+	allowed := 0
+	for i := 0; i < 3; i++ {
+		for _, pod := range []struct {
+			lim *Limiter
+			now time.Time
+		}{{podA, localA}, {podB, localB}} {
+			res, err := pod.lim.Check(ctx, "ip:1.2.3.4", 4, 1, 0, pod.now)
+			if err != nil {
+				t.Fatalf("check err: %v", err)
+			}
+			if res.Allowed {
+				allowed++
+			}
+		}
+	}
+	if allowed != 4 {
+		t.Fatalf("expected exactly the limit (4) across both pods, got %d", allowed)
+	}
+}
+
+func TestLimiter_LocalClockSplitsWindowAcrossSkewedInstances(t *testing.T) {
+	_, podA, podB := newSkewedInstances(t)
+	ctx := context.Background()
+	storeNow := time.Unix(1_700_000_000, 400_000_000)
+	localA := storeNow.Add(-600 * time.Millisecond)
+	localB := storeNow.Add(700 * time.Millisecond)
+
+	allowed := 0
+	for i := 0; i < 3; i++ {
+		for _, pod := range []struct {
+			lim *Limiter
+			now time.Time
+		}{{podA, localA}, {podB, localB}} {
+			res, err := pod.lim.Check(ctx, "ip:1.2.3.4", 4, 1, 0, pod.now)
+			if err != nil {
+				t.Fatalf("check err: %v", err)
+			}
+			if res.Allowed {
+				allowed++
+			}
+		}
+	}
+	// each pod counts in its own bucket, so the client gets more than the limit
+	if allowed != 6 {
+		t.Fatalf("expected skew to split buckets (6 allowed), got %d", allowed)
+	}
+}
+
+type fixedClock struct {
+	now time.Time
+}
+
+func (c fixedClock) Now(context.Context) (time.Time, error) { return c.now, nil }
+
+func TestSkewDetector_LogsWhenAboveThreshold(t *testing.T) {
+	local := time.Unix(1_700_000_000, 0)
+	var logs []string
+	d := NewSkewDetector(fixedClock{now: local.Add(2 * time.Second)}, 250*time.Millisecond)
+	d.now = func() time.Time { return local }
+	d.logf = func(format string, args ...any) { logs = append(logs, fmt.Sprintf(format, args...)) }
+
+	skew, err := d.Check(context.Background())
+	if err != nil {
+		t.Fatalf("check err: %v", err)
+	}
+	if skew != 2*time.Second {
+		t.Fatalf("expected 2s skew, got %s", skew)
+	}
+	if len(logs) != 1 || !strings.Contains(logs[0], "2s behind") {
+		t.Fatalf("expected skew log, got %v", logs)
+	}
+
+	logs = nil
+	d.store = fixedClock{now: local.Add(-100 * time.Millisecond)}
+	if _, err := d.Check(context.Background()); err != nil {
+		t.Fatalf("check err: %v", err)
+	}
+	if len(logs) != 0 {
+		t.Fatalf("expected no log under threshold, got %v", logs)
+	}
+}
@@ -0,0 +1,156 @@
+package limiter
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"rate-limiter/pkg/storage/memory"
+	redispkg "rate-limiter/pkg/storage/redis"
+
+	miniredis "github.com/alicebob/miniredis/v2"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// stressStart is a window boundary shared by the local clock and miniredis.
+var stressStart = time.Unix(1_700_000_000, 0)
+
+// stressTarget is a store shared by several limiter instances, like pods pointing at one Redis.
+type stressTarget struct {
+	name string
+	// setup creates the shared store and returns a factory of limiters that each
+	// use their own connection to it.
+	setup func(t testing.TB) func(opts ...Option) *Limiter
+}
+
+var stressTargets = []stressTarget{
+	{name: "memory", setup: func(t testing.TB) func(opts ...Option) *Limiter {
+		shared := memory.New(func() time.Time { return stressStart })
+		return func(opts ...Option) *Limiter { return New(shared, opts...) }
+	}},
+	{name: "redis", setup: func(t testing.TB) func(opts ...Option) *Limiter {
+		mr, err := miniredis.Run()
+		if err != nil {
+			t.Fatalf("miniredis: %v", err)
+		}
+		mr.SetTime(stressStart)
+		t.Cleanup(mr.Close)
+		return func(opts ...Option) *Limiter {
+			rdb := goredis.NewClient(&goredis.Options{Addr: mr.Addr(), PoolSize: 64})
+			t.Cleanup(func() { rdb.Close() })
+			return New(redispkg.New(rdb), opts...)
+		}
+	}},
+}
+
+var stressClocks = []struct {
+	name string
+	opts []Option
+}{
+	{name: "local-clock"},
+	{name: "store-clock", opts: []Option{WithStoreClock()}},
+}
+
+// TestLimiter_ConcurrentInstancesAllowExactlyLimit fires thousands of goroutines
+// at one identifier through several limiter instances sharing a store and checks
+// that exactly limit requests get through the window, for every store and clock.
+func TestLimiter_ConcurrentInstancesAllowExactlyLimit(t *testing.T) {
+	const (
+		instances = 4
+		limit     = 100
+	)
+	perInstance := 500
+	if testing.Short() {
+		perInstance = 100
+	}
+	for _, clock := range stressClocks {
+		for _, target := range stressTargets {
+			t.Run(clock.name+"/"+target.name, func(t *testing.T) {
+				newInstance := target.setup(t)
+				lims := make([]*Limiter, instances)
+				for i := range lims {
+					lims[i] = newInstance(clock.opts...)
+				}
+				allowed, elapsed, allocs := fire(t, lims, perInstance, func(lim *Limiter) (Result, error) {
+					return lim.Check(context.Background(), "ip:10.0.0.1", limit, 1, time.Minute, stressStart)
+				})
+				total := instances * perInstance
+				t.Logf("%d requests in %s (%.0f ops/s, %.1f allocs/op)", total, elapsed, float64(total)/elapsed.Seconds(), float64(allocs)/float64(total))
+				if allowed != limit {
+					t.Fatalf("expected exactly %d allowed, got %d", limit, allowed)
+				}
+			})
+		}
+	}
+}
+
+// TestLimiter_ConcurrentAggregateAllowsExactlyLimit checks that a shared aggregate
+// admits exactly its limit when many identifiers race for it.
+func TestLimiter_ConcurrentAggregateAllowsExactlyLimit(t *testing.T) {
+	const (
+		instances = 4
+		limit     = 50
+	)
+	for _, target := range stressTargets {
+		t.Run(target.name, func(t *testing.T) {
+			newInstance := target.setup(t)
+			lims := make([]*Limiter, instances)
+			for i := range lims {
+				lims[i] = newInstance()
+			}
+			var seq atomic.Int64
+			allowed, _, _ := fire(t, lims, 200, func(lim *Limiter) (Result, error) {
+				id := fmt.Sprintf("ip:10.0.%d.1", seq.Add(1)%20)
+				return lim.Check(context.Background(), id, 1000, 1, 0, stressStart, Aggregate{Key: "global", LimitPerSecond: limit})
+			})
+			if allowed != limit {
+				t.Fatalf("expected exactly %d allowed by the aggregate, got %d", limit, allowed)
+			}
+		})
+	}
+}
+
+// fire runs perInstance concurrent checks on every limiter and returns how many
+// were allowed, the wall time and the heap allocations made meanwhile.
+func fire(t *testing.T, lims []*Limiter, perInstance int, check func(*Limiter) (Result, error)) (int, time.Duration, uint64) {
+	t.Helper()
+	var (
+		allowed  atomic.Int64
+		failures atomic.Int64
+		wg       sync.WaitGroup
+		start    = make(chan struct{})
+		before   runtime.MemStats
+		after    runtime.MemStats
+	)
+	for _, lim := range lims {
+		for i := 0; i < perInstance; i++ {
+			wg.Add(1)
+			go func(lim *Limiter) {
+				defer wg.Done()
+				<-start
+				res, err := check(lim)
+				if err != nil {
+					failures.Add(1)
+					return
+				}
+				if res.Allowed {
+					allowed.Add(1)
+				}
+			}(lim)
+		}
+	}
+	runtime.ReadMemStats(&before)
+	began := time.Now()
+	close(start)
+	wg.Wait()
+	elapsed := time.Since(began)
+	runtime.ReadMemStats(&after)
+	if n := failures.Load(); n > 0 {
+		t.Fatalf("%d checks failed", n)
+	}
+	return int(allowed.Load()), elapsed, after.Mallocs - before.Mallocs
+}
@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"rate-limiter/pkg/config"
+	"rate-limiter/pkg/limiter"
+	"rate-limiter/pkg/ratelimit"
+)
+
+// RateLimitMiddleware enforces the rate limit rules on net/http handlers.
+// Handler has the func(http.Handler) http.Handler shape, so it also plugs into chi and similar routers.
+type RateLimitMiddleware struct {
+	engine    *ratelimit.Engine
+	observer  Observer
+	skipQuota bool
+}
+
+// Observer is told how the next handler did on every request the limiter let
+// through, keyed by ratelimit.Rule.Key.
+type Observer interface {
+	Observe(rule string, status int, latency time.Duration)
+}
+
+// Option customizes a RateLimitMiddleware.
+type Option func(*RateLimitMiddleware)
+
+// WithObserver reports the status and latency of allowed requests to o, which is
+// how adaptive limiting learns the backend health.
+func WithObserver(o Observer) Option {
+	return func(m *RateLimitMiddleware) { m.observer = o }
+}
+
+// WithoutQuota applies only the rate limits, leaving the long-period quota untouched.
+// The quota report uses it so a client that used up its quota can still read it.
+func WithoutQuota() Option {
+	return func(m *RateLimitMiddleware) { m.skipQuota = true }
+}
+
+func NewRateLimitMiddleware(l limiter.Checker, cfg *config.Config) *RateLimitMiddleware {
+	return FromEngine(ratelimit.NewEngine(l, cfg))
+}
+
+// FromEngine builds the middleware on top of an engine shared with other adapters.
+func FromEngine(e *ratelimit.Engine, opts ...Option) *RateLimitMiddleware {
+	m := &RateLimitMiddleware{engine: e}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+func (m *RateLimitMiddleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := m.engine.FromHTTP(r)
+		req.SkipQuota = m.skipQuota
+		d, err := m.engine.Evaluate(r.Context(), req)
+		if err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		if !d.Allowed {
+			ratelimit.WriteDenied(w, r, d)
+			return
+		}
+		if m.observer == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		next.ServeHTTP(rec, r)
+		m.observer.Observe(d.Key(), rec.status, time.Since(start))
+	})
+}
+
+// statusRecorder captures the status code written by the next handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	// Informational 1xx responses precede the final status.
+	if !s.wroteHeader && code >= 200 {
+		s.status, s.wroteHeader = code, true
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	s.wroteHeader = true
+	return s.ResponseWriter.Write(b)
+}
+
+// Unwrap exposes the original writer to http.ResponseController, so flushing
+// streamed proxy responses keeps working.
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
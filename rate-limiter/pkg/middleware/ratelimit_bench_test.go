@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"rate-limiter/pkg/config"
+	"rate-limiter/pkg/limiter"
+	"rate-limiter/pkg/storage/memory"
+)
+
+// Run with: go test ./pkg/middleware -run '^$' -bench . -benchmem
+
+func BenchmarkMiddleware(b *testing.B) {
+	cases := []struct {
+		name  string
+		limit int64
+	}{
+		{name: "allowed", limit: 1 << 40},
+		// The route costs 5: the first request spends the whole budget and the
+		// rest are denied, so no request takes the cost-above-limit shortcut.
+		{name: "denied", limit: 5},
+	}
+	for _, tc := range cases {
+		b.Run(tc.name, func(b *testing.B) {
+			cfg := &config.Config{Mode: config.ModeAuto, DefaultLimitPerSec: tc.limit, DefaultBlockSeconds: 60, TokenHeader: "API_KEY",
+				Routes: []config.RouteRule{{Name: "export", Path: "/export", Cost: 5}}}
+			h := NewRateLimitMiddleware(limiter.New(memory.New(nil)), cfg).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			var seq atomic.Int64
+			b.ReportAllocs()
+			b.ResetTimer()
+			start := time.Now()
+			b.RunParallel(func(pb *testing.PB) {
+				req := httptest.NewRequest(http.MethodGet, "/export/csv", nil)
+				req.Header.Set("X-Forwarded-For", "10.0.0."+strconv.FormatInt(seq.Add(1), 10))
+				for pb.Next() {
+					h.ServeHTTP(httptest.NewRecorder(), req)
+				}
+			})
+			b.ReportMetric(float64(b.N)/time.Since(start).Seconds(), "ops/s")
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"rate-limiter/pkg/config"
+	"rate-limiter/pkg/limiter"
+	"rate-limiter/pkg/ratelimit"
+)
+
+type fakeChecker struct {
+	allow bool
+}
+
+func (f fakeChecker) Check(_ context.Context, _ string, _, _ int64, _ time.Duration, _ time.Time, _ ...limiter.Aggregate) (limiter.Result, error) {
+	if f.allow {
+		return limiter.Result{Allowed: true}, nil
+	}
+	return limiter.Result{Allowed: false, RetryAfter: 3 * time.Second}, nil
+}
+
+func TestMiddleware_DeniesWith429(t *testing.T) {
+	cfg := &config.Config{Port: "8080", Mode: config.ModeIP, DefaultLimitPerSec: 1, DefaultBlockSeconds: 5, TokenHeader: "API_KEY"}
+	mw := NewRateLimitMiddleware(fakeChecker{allow: false}, cfg)
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rr := httptest.NewRecorder()
+	mw.Handler(next).ServeHTTP(rr, req)
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", rr.Code)
+	}
+	if rr.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected Retry-After header")
+	}
+}
+
+func TestMiddleware_AllowsNext(t *testing.T) {
+	cfg := &config.Config{Port: "8080", Mode: config.ModeIP, DefaultLimitPerSec: 1, DefaultBlockSeconds: 5, TokenHeader: "API_KEY"}
+	mw := NewRateLimitMiddleware(fakeChecker{allow: true}, cfg)
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rr := httptest.NewRecorder()
+	mw.Handler(next).ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+}
+
+type costRecorder struct {
+	cost int64
+}
+
+func (c *costRecorder) Check(_ context.Context, _ string, _, cost int64, _ time.Duration, _ time.Time, _ ...limiter.Aggregate) (limiter.Result, error) {
+	c.cost = cost
+	return limiter.Result{Allowed: true}, nil
+}
+
+func TestMiddleware_ResolvesCost(t *testing.T) {
+	cfg := &config.Config{
+		Port: "8080", Mode: config.ModeIP, DefaultLimitPerSec: 100, DefaultBlockSeconds: 5, TokenHeader: "API_KEY",
+		CostHeader: "X-RateLimit-Cost",
+		Routes:     []config.RouteRule{{Path: "/export", Cost: 50}, {Path: "/export/small", Cost: 5}},
+	}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+
+	cases := []struct {
+		name   string
+		path   string
+		header string
+		ctx    int64
+		want   int64
+	}{
+		{name: "default", path: "/", want: 1},
+		{name: "route", path: "/export", want: 50},
+		{name: "route subpath", path: "/export/csv", want: 50},
+		{name: "longest prefix", path: "/export/small/1", want: 5},
+		{name: "no partial segment", path: "/exports", want: 1},
+		{name: "header", path: "/", header: "7", want: 7},
+		{name: "invalid header ignored", path: "/export", header: "abc", want: 50},
+		{name: "context wins", path: "/export", header: "7", ctx: 3, want: 3},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := &costRecorder{}
+			mw := NewRateLimitMiddleware(rec, cfg)
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.header != "" {
+				req.Header.Set("X-RateLimit-Cost", tc.header)
+			}
+			if tc.ctx > 0 {
+				req = req.WithContext(limiter.WithCost(req.Context(), tc.ctx))
+			}
+			mw.Handler(next).ServeHTTP(httptest.NewRecorder(), req)
+			if rec.cost != tc.want {
+				t.Fatalf("expected cost %d, got %d", tc.want, rec.cost)
+			}
+		})
+	}
+}
+
+type observation struct {
+	rule   string
+	status int
+}
+
+type fakeObserver struct {
+	seen []observation
+}
+
+func (f *fakeObserver) Observe(rule string, status int, _ time.Duration) {
+	f.seen = append(f.seen, observation{rule: rule, status: status})
+}
+
+func TestMiddleware_ObservesAllowedRequests(t *testing.T) {
+	cfg := &config.Config{Mode: config.ModeIP, DefaultLimitPerSec: 1, TokenHeader: "API_KEY", Routes: []config.RouteRule{{Name: "export", Path: "/export"}}}
+	obs := &fakeObserver{}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/export" {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	})
+
+	h := FromEngine(ratelimit.NewEngine(fakeChecker{allow: true}, cfg), WithObserver(obs)).Handler(next)
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/export", nil))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	denied := FromEngine(ratelimit.NewEngine(fakeChecker{allow: false}, cfg), WithObserver(obs)).Handler(next)
+	denied.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	want := []observation{{"export", http.StatusBadGateway}, {"default", http.StatusOK}}
+	if len(obs.seen) != len(want) || obs.seen[0] != want[0] || obs.seen[1] != want[1] {
+		t.Fatalf("expected %v, got %v", want, obs.seen)
+	}
+}
@@ -0,0 +1,145 @@
+// Package outbound throttles calls to third-party APIs (ViaCEP, WeatherAPI, ...)
+// with an http.RoundTripper backed by the same limiter and store as the server, so
+// every instance shares one budget per host.
+package outbound
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"time"
+
+	"rate-limiter/pkg/limiter"
+)
+
+// LimitedError is returned when a host's budget is exhausted and the transport
+// does not wait, or would have to wait past its maximum or the request deadline.
+type LimitedError struct {
+	Host       string
+	RetryAfter time.Duration
+}
+
+func (e *LimitedError) Error() string {
+	return fmt.Sprintf("outbound rate limit for %s exceeded, retry in %s", e.Host, e.RetryAfter)
+}
+
+// CostError is returned when a request costs more than its host's limit: it
+// can never fit in a window, so the transport fails at once instead of waiting.
+type CostError struct {
+	Host        string
+	Cost, Limit int64
+}
+
+func (e *CostError) Error() string {
+	return fmt.Sprintf("outbound request to %s costs %d, more than the limit of %d per second", e.Host, e.Cost, e.Limit)
+}
+
+// Transport limits requests per destination host. Hosts without a limit pass through.
+type Transport struct {
+	base    http.RoundTripper
+	limiter limiter.Checker
+	limits  map[string]int64
+	def     int64
+	maxWait time.Duration
+	now     func() time.Time
+}
+
+// Option customizes a Transport.
+type Option func(*Transport)
+
+// WithBase sends allowed requests through rt instead of http.DefaultTransport.
+func WithBase(rt http.RoundTripper) Option {
+	return func(t *Transport) { t.base = rt }
+}
+
+// WithHostLimit caps requests per second to host (matched without port, case-insensitively).
+func WithHostLimit(host string, limitPerSecond int64) Option {
+	return func(t *Transport) { t.limits[strings.ToLower(host)] = limitPerSecond }
+}
+
+// WithDefaultLimit caps requests per second to each host without its own limit.
+func WithDefaultLimit(limitPerSecond int64) Option {
+	return func(t *Transport) { t.def = limitPerSecond }
+}
+
+// WithWait makes requests over the budget wait for the next window, up to maxWait
+// and the request context deadline, instead of failing fast.
+func WithWait(maxWait time.Duration) Option {
+	return func(t *Transport) { t.maxWait = maxWait }
+}
+
+// New builds a Transport on l; with a Redis-backed limiter the budget is shared
+// by every process using the same store. Request costs set with limiter.WithCost
+// are honored.
+func New(l limiter.Checker, opts ...Option) *Transport {
+	t := &Transport{base: http.DefaultTransport, limiter: l, limits: map[string]int64{}, now: time.Now}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := strings.ToLower(req.URL.Hostname())
+	limit, ok := t.limits[host]
+	if !ok {
+		limit = t.def
+	}
+	if limit <= 0 {
+		return t.base.RoundTrip(req)
+	}
+	cost, ok := limiter.CostFromContext(req.Context())
+	if !ok {
+		cost = 1
+	}
+	if err := t.acquire(req.Context(), host, limit, cost); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	return t.base.RoundTrip(req)
+}
+
+// acquire takes cost units from the host budget, waiting for later windows when allowed to.
+func (t *Transport) acquire(ctx context.Context, host string, limit, cost int64) error {
+	if cost > limit {
+		return &CostError{Host: host, Cost: cost, Limit: limit}
+	}
+	var deadline time.Time
+	if t.maxWait > 0 {
+		deadline = t.now().Add(t.maxWait)
+	}
+	if d, ok := ctx.Deadline(); ok && (deadline.IsZero() || d.Before(deadline)) {
+		deadline = d
+	}
+	for {
+		now := t.now()
+		// No block: an exhausted host recovers as soon as the window turns.
+		res, err := t.limiter.Check(ctx, "outbound:"+host, limit, cost, 0, now)
+		if err != nil {
+			return err
+		}
+		if res.Allowed {
+			return nil
+		}
+		wait := res.RetryAfter
+		if wait <= 0 {
+			wait = now.Truncate(time.Second).Add(time.Second).Sub(now)
+		}
+		// Spread waiters over the start of the window instead of waking them at once.
+		wait += rand.N(50 * time.Millisecond)
+		if t.maxWait <= 0 || now.Add(wait).After(deadline) {
+			return &LimitedError{Host: host, RetryAfter: wait}
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
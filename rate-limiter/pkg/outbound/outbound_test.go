@@ -0,0 +1,104 @@
+package outbound
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"rate-limiter/pkg/limiter"
+	"rate-limiter/pkg/storage/memory"
+)
+
+func newUpstream(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &hits
+}
+
+func TestTransport_FailsFastWhenExhausted(t *testing.T) {
+	srv, hits := newUpstream(t)
+	tr := New(limiter.New(memory.New(nil)), WithHostLimit("127.0.0.1", 2))
+	// Pin the clock so all calls share one window.
+	now := time.Now()
+	tr.now = func() time.Time { return now }
+	client := &http.Client{Transport: tr}
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatalf("call %d: %v", i, err)
+		}
+		resp.Body.Close()
+	}
+	_, err := client.Get(srv.URL)
+	var lerr *LimitedError
+	if !errors.As(err, &lerr) || lerr.Host != "127.0.0.1" {
+		t.Fatalf("expected LimitedError, got %v", err)
+	}
+	if hits.Load() != 2 {
+		t.Fatalf("expected the limited call not to reach the upstream, got %d hits", hits.Load())
+	}
+}
+
+func TestTransport_WaitsForNextWindow(t *testing.T) {
+	srv, hits := newUpstream(t)
+	client := &http.Client{Transport: New(limiter.New(memory.New(nil)), WithDefaultLimit(1), WithWait(3*time.Second))}
+
+	start := time.Now()
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatalf("call %d: %v", i, err)
+		}
+		resp.Body.Close()
+	}
+	if hits.Load() != 2 {
+		t.Fatalf("expected both calls to reach the upstream, got %d", hits.Load())
+	}
+	if start.Truncate(time.Second).Equal(time.Now().Truncate(time.Second)) {
+		t.Fatalf("expected the second call to wait for the next window")
+	}
+}
+
+func TestTransport_CostAboveLimitFailsWithoutWaiting(t *testing.T) {
+	srv, hits := newUpstream(t)
+	client := &http.Client{Transport: New(limiter.New(memory.New(nil)), WithDefaultLimit(2), WithWait(3*time.Second))}
+	req, _ := http.NewRequestWithContext(limiter.WithCost(context.Background(), 3), http.MethodGet, srv.URL, nil)
+
+	start := time.Now()
+	_, err := client.Do(req)
+	var cerr *CostError
+	if !errors.As(err, &cerr) || cerr.Cost != 3 || cerr.Limit != 2 {
+		t.Fatalf("expected CostError, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected an immediate failure, waited %s", elapsed)
+	}
+	if hits.Load() != 0 {
+		t.Fatalf("expected the call not to reach the upstream, got %d hits", hits.Load())
+	}
+}
+
+func TestTransport_UnlimitedHostPassesThrough(t *testing.T) {
+	srv, hits := newUpstream(t)
+	client := &http.Client{Transport: New(limiter.New(memory.New(nil)), WithHostLimit("viacep.com.br", 1))}
+	for i := 0; i < 5; i++ {
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatalf("call %d: %v", i, err)
+		}
+		resp.Body.Close()
+	}
+	if hits.Load() != 5 {
+		t.Fatalf("expected 5 hits, got %d", hits.Load())
+	}
+}
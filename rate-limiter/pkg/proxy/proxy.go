@@ -0,0 +1,120 @@
+// Package proxy forwards requests to upstream services, so the rate limiter
+// can run as a gateway in front of existing services.
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"time"
+
+	"rate-limiter/pkg/config"
+	"rate-limiter/pkg/ratelimit"
+)
+
+type route struct {
+	path  string
+	proxy *httputil.ReverseProxy
+}
+
+// Proxy routes each request to the upstream of the longest matching path, or to the default upstream.
+type Proxy struct {
+	routes   []route
+	fallback *httputil.ReverseProxy
+}
+
+// New builds a proxy from the configuration; every upstream must be an absolute URL.
+func New(cfg config.ProxyConfig) (*Proxy, error) {
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   time.Duration(cfg.DialTimeoutSeconds) * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:          512,
+		MaxIdleConnsPerHost:   128,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: time.Duration(cfg.ResponseTimeoutSeconds) * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+
+	p := &Proxy{}
+	for _, r := range cfg.Routes {
+		rp, err := newReverseProxy(r.Upstream, cfg, transport)
+		if err != nil {
+			return nil, err
+		}
+		p.routes = append(p.routes, route{path: r.Path, proxy: rp})
+	}
+	if cfg.Upstream != "" {
+		rp, err := newReverseProxy(cfg.Upstream, cfg, transport)
+		if err != nil {
+			return nil, err
+		}
+		p.fallback = rp
+	}
+	return p, nil
+}
+
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	target := p.fallback
+	longest := -1
+	for _, rt := range p.routes {
+		if ratelimit.MatchPath(rt.path, r.URL.Path) && len(rt.path) > longest {
+			target, longest = rt.proxy, len(rt.path)
+		}
+	}
+	if target == nil {
+		http.Error(w, "no upstream for path", http.StatusNotFound)
+		return
+	}
+	target.ServeHTTP(w, r)
+}
+
+func newReverseProxy(raw string, cfg config.ProxyConfig, transport http.RoundTripper) (*httputil.ReverseProxy, error) {
+	upstream, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid upstream %q: %w", raw, err)
+	}
+	if upstream.Scheme == "" || upstream.Host == "" {
+		return nil, fmt.Errorf("invalid upstream %q: scheme and host are required", raw)
+	}
+
+	return &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(upstream)
+			pr.SetXForwarded()
+			// Keep the client chain seen by the limiter instead of restarting it at this hop.
+			if prior := pr.In.Header.Values("X-Forwarded-For"); len(prior) > 0 {
+				pr.Out.Header.Set("X-Forwarded-For", strings.Join(prior, ", ")+", "+pr.Out.Header.Get("X-Forwarded-For"))
+			}
+			for _, h := range cfg.RemoveHeaders {
+				pr.Out.Header.Del(h)
+			}
+			for k, v := range cfg.SetHeaders {
+				pr.Out.Header.Set(k, v)
+			}
+		},
+		Transport: transport,
+		// Flush immediately so streamed responses (SSE, chunked downloads) reach the client as they arrive.
+		FlushInterval: -1,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			status := http.StatusBadGateway
+			var netErr net.Error
+			if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+				status = http.StatusGatewayTimeout
+			}
+			if !errors.Is(err, context.Canceled) {
+				log.Printf("proxy error for %s %s: %v", r.Method, r.URL.Path, err)
+			}
+			w.WriteHeader(status)
+		},
+	}, nil
+}
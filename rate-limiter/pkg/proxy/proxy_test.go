@@ -0,0 +1,105 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"rate-limiter/pkg/config"
+)
+
+func newUpstream(t *testing.T, name string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Upstream", name)
+		w.Header().Set("X-Seen-Path", r.URL.Path)
+		w.Header().Set("X-Seen-Gateway", r.Header.Get("X-Gateway"))
+		w.Header().Set("X-Seen-Key", r.Header.Get("API_KEY"))
+		w.Header().Set("X-Seen-XFF", r.Header.Get("X-Forwarded-For"))
+		fmt.Fprint(w, "ok")
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestProxy_RoutesAndRewritesHeaders(t *testing.T) {
+	def := newUpstream(t, "default")
+	cep := newUpstream(t, "cep")
+	px, err := New(config.ProxyConfig{
+		Upstream:               def.URL,
+		Routes:                 []config.ProxyRoute{{Path: "/cep", Upstream: cep.URL}},
+		DialTimeoutSeconds:     1,
+		ResponseTimeoutSeconds: 1,
+		SetHeaders:             map[string]string{"X-Gateway": "rate-limiter"},
+		RemoveHeaders:          []string{"API_KEY"},
+	})
+	if err != nil {
+		t.Fatalf("new proxy: %v", err)
+	}
+
+	cases := []struct {
+		path     string
+		upstream string
+	}{
+		{path: "/cep/01001000", upstream: "cep"},
+		{path: "/weather", upstream: "default"},
+		{path: "/cepx", upstream: "default"},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		req.Header.Set("API_KEY", "secret")
+		req.Header.Set("X-Forwarded-For", "203.0.113.9")
+		rr := httptest.NewRecorder()
+		px.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d", tc.path, rr.Code)
+		}
+		if got := rr.Header().Get("X-Upstream"); got != tc.upstream {
+			t.Fatalf("%s: expected upstream %s, got %s", tc.path, tc.upstream, got)
+		}
+		if got := rr.Header().Get("X-Seen-Path"); got != tc.path {
+			t.Fatalf("%s: upstream saw path %s", tc.path, got)
+		}
+		if rr.Header().Get("X-Seen-Gateway") != "rate-limiter" || rr.Header().Get("X-Seen-Key") != "" {
+			t.Fatalf("%s: headers not rewritten: %v", tc.path, rr.Header())
+		}
+		if got := rr.Header().Get("X-Seen-XFF"); got != "203.0.113.9, 192.0.2.1" {
+			t.Fatalf("%s: unexpected X-Forwarded-For %q", tc.path, got)
+		}
+	}
+}
+
+func TestProxy_NoUpstreamAndBadGateway(t *testing.T) {
+	down := httptest.NewServer(http.NotFoundHandler())
+	downURL := down.URL
+	down.Close()
+
+	px, err := New(config.ProxyConfig{
+		Routes:                 []config.ProxyRoute{{Path: "/down", Upstream: downURL}},
+		DialTimeoutSeconds:     1,
+		ResponseTimeoutSeconds: 1,
+	})
+	if err != nil {
+		t.Fatalf("new proxy: %v", err)
+	}
+
+	rr := httptest.NewRecorder()
+	px.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/other", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 without upstream, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	px.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/down", nil))
+	if rr.Code != http.StatusBadGateway {
+		t.Fatalf("expected 502 for unreachable upstream, got %d", rr.Code)
+	}
+}
+
+func TestNew_RejectsRelativeUpstream(t *testing.T) {
+	if _, err := New(config.ProxyConfig{Upstream: "service-a:8080"}); err == nil {
+		t.Fatalf("expected error for upstream without scheme")
+	}
+}
@@ -0,0 +1,38 @@
+package quota
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Handler serves the usage report of the token sent in tokenHeader, so each client
+// can only see its own consumption. tenantOf names the tenant of the request, as the
+// rate limiter resolves it; nil means every request uses the top-level rules.
+func (t *Tracker) Handler(tokenHeader string, tenantOf func(*http.Request) string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		token := strings.TrimSpace(r.Header.Get(tokenHeader))
+		if token == "" {
+			http.Error(w, "missing "+tokenHeader+" header", http.StatusUnauthorized)
+			return
+		}
+		tenant := ""
+		if tenantOf != nil {
+			tenant = tenantOf(r)
+		}
+		rep, err := t.Report(r.Context(), tenant, token, time.Now())
+		if err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		_ = json.NewEncoder(w).Encode(rep)
+	})
+}
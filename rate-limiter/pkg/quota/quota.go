@@ -0,0 +1,190 @@
+// Package quota accounts long-period usage per API token (e.g. 1M calls per month),
+// with calendar-aligned periods in a configured timezone.
+package quota
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"rate-limiter/pkg/config"
+	"rate-limiter/pkg/storage"
+)
+
+// Period is a calendar period quotas reset on.
+type Period string
+
+const (
+	Day   Period = "day"
+	Week  Period = "week"
+	Month Period = "month"
+)
+
+// Bounds returns the start and end of the period that contains t, in t's location.
+func (p Period) Bounds(t time.Time) (start, end time.Time) {
+	y, m, d := t.Date()
+	switch p {
+	case Day:
+		start = time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+		return start, start.AddDate(0, 0, 1)
+	case Week:
+		// ISO weeks start on Monday
+		offset := (int(t.Weekday()) + 6) % 7
+		start = time.Date(y, m, d-offset, 0, 0, 0, 0, t.Location())
+		return start, start.AddDate(0, 0, 7)
+	default:
+		start = time.Date(y, m, 1, 0, 0, 0, 0, t.Location())
+		return start, start.AddDate(0, 1, 0)
+	}
+}
+
+// ID names the period that starts at start, e.g. "2024-05", "2024-W19" or "2024-05-06".
+func (p Period) ID(start time.Time) string {
+	switch p {
+	case Day:
+		return start.Format("2006-01-02")
+	case Week:
+		y, w := start.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", y, w)
+	default:
+		return start.Format("2006-01")
+	}
+}
+
+// Result is the outcome of consuming quota.
+type Result struct {
+	Allowed bool
+	// Limit is zero when the token has no quota.
+	Limit   int64
+	Used    int64
+	ResetAt time.Time
+}
+
+// Usage is the consumption of one period.
+type Usage struct {
+	Period string    `json:"period"`
+	Used   int64     `json:"used"`
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+}
+
+// Report is the current period plus history for a token, newest first.
+type Report struct {
+	Period   Period `json:"period"`
+	Timezone string `json:"timezone"`
+	Limit    int64  `json:"limit,omitempty"`
+	// Remaining is nil only for unlimited tokens, so an exhausted quota reports 0.
+	Remaining *int64  `json:"remaining,omitempty"`
+	Current   Usage   `json:"current"`
+	History   []Usage `json:"history"`
+}
+
+// Tracker consumes and reports quota usage.
+type Tracker struct {
+	store   storage.QuotaStore
+	cfg     config.QuotaConfig
+	period  Period
+	loc     *time.Location
+	history int
+}
+
+func NewTracker(store storage.QuotaStore, cfg config.QuotaConfig) (*Tracker, error) {
+	loc, err := time.LoadLocation(cfg.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid quota timezone: %w", err)
+	}
+	history := cfg.HistoryPeriods
+	if history < 0 {
+		history = 0
+	}
+	return &Tracker{store: store, cfg: cfg, period: Period(cfg.Period), loc: loc, history: history}, nil
+}
+
+// Consume adds cost to the token usage in the current period of tenant, empty for
+// the top-level rules. Denied calls are not counted, so a token that hits its quota
+// keeps reporting exactly the limit.
+func (t *Tracker) Consume(ctx context.Context, tenant, token string, cost int64, now time.Time) (Result, error) {
+	start, end := t.period.Bounds(now.In(t.loc))
+	id := t.period.ID(start)
+	limit := t.cfg.LimitFor(token)
+	acct := account(tenant, token)
+
+	used, err := t.store.AddUsage(ctx, acct, id, cost, t.retention(now, end))
+	if err != nil {
+		return Result{}, err
+	}
+	res := Result{Allowed: true, Limit: limit, Used: used, ResetAt: end}
+	if limit > 0 && used > limit {
+		if _, err := t.store.AddUsage(ctx, acct, id, -cost, t.retention(now, end)); err != nil {
+			return Result{}, err
+		}
+		res.Allowed = false
+		res.Used = used - cost
+	}
+	return res, nil
+}
+
+// Refund gives back cost consumed by an allowed Consume at now, when the caller
+// ends up denying the request for another reason.
+func (t *Tracker) Refund(ctx context.Context, tenant, token string, cost int64, now time.Time) error {
+	start, end := t.period.Bounds(now.In(t.loc))
+	_, err := t.store.AddUsage(ctx, account(tenant, token), t.period.ID(start), -cost, t.retention(now, end))
+	return err
+}
+
+// Report returns the token usage in tenant in the current period and the kept history.
+func (t *Tracker) Report(ctx context.Context, tenant, token string, now time.Time) (Report, error) {
+	usages := make([]Usage, 0, t.history+1)
+	ids := make([]string, 0, t.history+1)
+	at := now.In(t.loc)
+	for i := 0; i <= t.history; i++ {
+		start, end := t.period.Bounds(at)
+		id := t.period.ID(start)
+		usages = append(usages, Usage{Period: id, Start: start, End: end})
+		ids = append(ids, id)
+		at = start.Add(-time.Nanosecond)
+	}
+	used, err := t.store.Usage(ctx, account(tenant, token), ids)
+	if err != nil {
+		return Report{}, err
+	}
+	for i := range usages {
+		usages[i].Used = used[i]
+	}
+
+	rep := Report{
+		Period:   t.period,
+		Timezone: t.loc.String(),
+		Limit:    t.cfg.LimitFor(token),
+		Current:  usages[0],
+		History:  usages[1:],
+	}
+	if rep.Limit > 0 {
+		remaining := max(rep.Limit-rep.Current.Used, 0)
+		rep.Remaining = &remaining
+	}
+	return rep, nil
+}
+
+// account qualifies token with its tenant, like the rate limit identifiers, so the
+// same token used under two tenants has independent quotas.
+func account(tenant, token string) string {
+	if tenant == "" {
+		return token
+	}
+	return "tenant:" + tenant + ":" + token
+}
+
+// retention keeps the counter until the current period ends plus the history window.
+func (t *Tracker) retention(now, end time.Time) time.Duration {
+	var span time.Duration
+	switch t.period {
+	case Day:
+		span = 24 * time.Hour
+	case Week:
+		span = 7 * 24 * time.Hour
+	default:
+		span = 31 * 24 * time.Hour
+	}
+	return end.Sub(now) + time.Duration(t.history)*span
+}
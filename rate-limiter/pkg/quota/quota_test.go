@@ -0,0 +1,170 @@
+package quota
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"rate-limiter/pkg/config"
+	redispkg "rate-limiter/pkg/storage/redis"
+
+	miniredis "github.com/alicebob/miniredis/v2"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+func newTestTracker(t *testing.T, cfg config.QuotaConfig) *Tracker {
+	t.Helper()
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis: %v", err)
+	}
+	t.Cleanup(mr.Close)
+	store := redispkg.New(goredis.NewClient(&goredis.Options{Addr: mr.Addr()}))
+	tr, err := NewTracker(store, cfg)
+	if err != nil {
+		t.Fatalf("tracker: %v", err)
+	}
+	return tr
+}
+
+func TestPeriod_BoundsAreCalendarAlignedInTimezone(t *testing.T) {
+	loc, _ := time.LoadLocation("America/Sao_Paulo")
+	// 2024-06-01 01:30 UTC is still May 31st in São Paulo (UTC-3)
+	at := time.Date(2024, 6, 1, 1, 30, 0, 0, time.UTC).In(loc)
+
+	start, end := Month.Bounds(at)
+	if Month.ID(start) != "2024-05" || !end.Equal(time.Date(2024, 6, 1, 0, 0, 0, 0, loc)) {
+		t.Fatalf("unexpected month bounds %s - %s", start, end)
+	}
+	start, _ = Week.Bounds(at)
+	if Week.ID(start) != "2024-W22" || start.Weekday() != time.Monday {
+		t.Fatalf("unexpected week start %s (%s)", start, Week.ID(start))
+	}
+	start, end = Day.Bounds(at)
+	if Day.ID(start) != "2024-05-31" || end.Sub(start) != 24*time.Hour {
+		t.Fatalf("unexpected day bounds %s - %s", start, end)
+	}
+}
+
+func TestTracker_DeniesWhenExhaustedWithoutCounting(t *testing.T) {
+	tr := newTestTracker(t, config.QuotaConfig{Period: "month", Timezone: "UTC", DefaultLimit: 10, Overrides: map[string]int64{"big": 1000}, HistoryPeriods: 2})
+	ctx := context.Background()
+	now := time.Date(2024, 5, 20, 12, 0, 0, 0, time.UTC)
+
+	res, err := tr.Consume(ctx, "", "free", 8, now)
+	if err != nil || !res.Allowed || res.Used != 8 {
+		t.Fatalf("first consume: %v %+v", err, res)
+	}
+	res, err = tr.Consume(ctx, "", "free", 5, now)
+	if err != nil {
+		t.Fatalf("consume err: %v", err)
+	}
+	if res.Allowed || res.Used != 8 || res.Limit != 10 {
+		t.Fatalf("expected deny keeping usage at 8, got %+v", res)
+	}
+	if !res.ResetAt.Equal(time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected reset %s", res.ResetAt)
+	}
+
+	// override and new period are independent
+	if res, _ := tr.Consume(ctx, "", "big", 500, now); !res.Allowed {
+		t.Fatalf("override should allow")
+	}
+	if res, _ := tr.Consume(ctx, "", "free", 5, now.AddDate(0, 1, 0)); !res.Allowed {
+		t.Fatalf("next period should allow")
+	}
+}
+
+func TestTracker_ReportHistory(t *testing.T) {
+	tr := newTestTracker(t, config.QuotaConfig{Period: "month", Timezone: "UTC", DefaultLimit: 100, HistoryPeriods: 2})
+	ctx := context.Background()
+	may := time.Date(2024, 5, 20, 12, 0, 0, 0, time.UTC)
+
+	_, _ = tr.Consume(ctx, "", "abc", 30, may.AddDate(0, -2, 0))
+	_, _ = tr.Consume(ctx, "", "abc", 7, may)
+
+	rep, err := tr.Report(ctx, "", "abc", may)
+	if err != nil {
+		t.Fatalf("report: %v", err)
+	}
+	if rep.Current.Period != "2024-05" || rep.Current.Used != 7 || rep.Remaining == nil || *rep.Remaining != 93 {
+		t.Fatalf("unexpected current: %+v remaining=%v", rep.Current, rep.Remaining)
+	}
+	if len(rep.History) != 2 || rep.History[0].Period != "2024-04" || rep.History[0].Used != 0 || rep.History[1].Period != "2024-03" || rep.History[1].Used != 30 {
+		t.Fatalf("unexpected history: %+v", rep.History)
+	}
+}
+
+func TestHandler_ReportsOwnToken(t *testing.T) {
+	tr := newTestTracker(t, config.QuotaConfig{Period: "day", Timezone: "UTC", DefaultLimit: 5})
+	_, _ = tr.Consume(context.Background(), "", "abc", 2, time.Now())
+	h := tr.Handler("API_KEY", nil)
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/quota", nil))
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without token, got %d", rr.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/quota", nil)
+	req.Header.Set("API_KEY", "abc")
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	var rep Report
+	if err := json.NewDecoder(rr.Body).Decode(&rep); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if rep.Current.Used != 2 || rep.Remaining == nil || *rep.Remaining != 3 || rep.Period != Day {
+		t.Fatalf("unexpected report: %+v", rep)
+	}
+}
+
+func TestTracker_TenantsHaveIndependentQuotas(t *testing.T) {
+	tr := newTestTracker(t, config.QuotaConfig{Period: "day", Timezone: "UTC", DefaultLimit: 5})
+	ctx := context.Background()
+	now := time.Now()
+
+	if res, _ := tr.Consume(ctx, "acme", "abc", 5, now); !res.Allowed {
+		t.Fatalf("first tenant should allow")
+	}
+	if res, _ := tr.Consume(ctx, "acme", "abc", 1, now); res.Allowed {
+		t.Fatalf("first tenant should be exhausted")
+	}
+	if res, _ := tr.Consume(ctx, "", "abc", 5, now); !res.Allowed || res.Used != 5 {
+		t.Fatalf("the same token under the top-level rules has its own quota, got %+v", res)
+	}
+	rep, err := tr.Report(ctx, "other", "abc", now)
+	if err != nil || rep.Current.Used != 0 {
+		t.Fatalf("unexpected report for another tenant: %v %+v", err, rep)
+	}
+}
+
+func TestReport_RemainingZeroIsReported(t *testing.T) {
+	cases := []struct {
+		name  string
+		limit int64
+		want  string
+	}{
+		{"exhausted", 2, `"remaining":0`},
+		{"unlimited", 0, ""},
+	}
+	for _, tc := range cases {
+		tr := newTestTracker(t, config.QuotaConfig{Period: "day", Timezone: "UTC", Overrides: map[string]int64{"abc": tc.limit}})
+		_, _ = tr.Consume(context.Background(), "", "abc", 2, time.Now())
+		rep, err := tr.Report(context.Background(), "", "abc", time.Now())
+		if err != nil {
+			t.Fatalf("%s: report: %v", tc.name, err)
+		}
+		data, _ := json.Marshal(rep)
+		if tc.want != "" && !strings.Contains(string(data), tc.want) {
+			t.Fatalf("%s: %s should contain %s", tc.name, data, tc.want)
+		}
+		if tc.want == "" && strings.Contains(string(data), "remaining") {
+			t.Fatalf("%s: unlimited token should not report remaining: %s", tc.name, data)
+		}
+	}
+}
@@ -0,0 +1,392 @@
+package ratelimit
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"rate-limiter/pkg/config"
+	"rate-limiter/pkg/limiter"
+	"rate-limiter/pkg/quota"
+)
+
+// Request carries the attributes the rule engine needs, independent of the transport.
+type Request struct {
+	Token string
+	IP    string
+	// Path is the HTTP path or, for gRPC, the full method name ("/pkg.Service/Method").
+	Path string
+	// Cost overrides the route cost when greater than zero.
+	Cost int64
+	// DeclaredCost is a cost reported by the caller, such as the trusted cost
+	// header. It raises the route cost but never lowers it; Cost takes precedence.
+	DeclaredCost int64
+	// Key identifies requests that carry neither token nor IP, such as generic
+	// descriptors sent to the decision service. It is ignored otherwise.
+	Key string
+	// Tenant selects a tenant rule set by name; unknown or empty names use the top-level rules.
+	Tenant string
+	// Scope qualifies the identifier when no tenant applies, such as the Envoy domain
+	// of a decision request, so callers in different scopes never share counters.
+	Scope string
+	// SkipQuota checks only the rate limits, for requests that must not spend the
+	// long-period quota, such as the quota report itself.
+	SkipQuota bool
+}
+
+// DefaultRuleName names the rule of requests that match no route.
+const DefaultRuleName = "default"
+
+// GlobalAggregateKey is the aggregate shared by every request to the server.
+const GlobalAggregateKey = "global"
+
+// Rule is the limit that applies to a request after resolving mode, token overrides and route rules.
+type Rule struct {
+	// Name is the matched route's name (its path when unnamed) or DefaultRuleName.
+	Name string
+	// Tenant is the tenant whose rule set applied, empty for the top-level rules.
+	Tenant string
+	// Identifier is qualified with the tenant ("tenant:<name>:ip:...") so tenants never share counters.
+	Identifier     string
+	LimitPerSecond int64
+	BlockFor       time.Duration
+	Cost           int64
+	// Aggregates are the limits shared with every other identifier (route-wide and global).
+	Aggregates []limiter.Aggregate
+	// Response customizes the denial; nil means the built-in negotiated response.
+	Response *config.ResponseTemplate
+}
+
+// Key names the rule uniquely across tenants, as "<tenant>/<name>" or just the name.
+func (r Rule) Key() string {
+	if r.Tenant == "" {
+		return r.Name
+	}
+	return r.Tenant + "/" + r.Name
+}
+
+// Decision is the outcome of evaluating a request against its rule.
+type Decision struct {
+	Rule
+	Allowed    bool
+	RetryAfter time.Duration
+	// LimitedBy is the aggregate key that denied the request, empty for the per-identifier limit.
+	LimitedBy string
+	// Quota is the long-period quota outcome for requests with a token when quotas are enabled.
+	Quota *quota.Result
+
+	// What Evaluate charged, for Refund.
+	charged limiter.Result
+	token   string
+	at      time.Time
+}
+
+// QuotaExceeded reports whether the request was denied by its long-period quota.
+func (d Decision) QuotaExceeded() bool {
+	return d.Quota != nil && !d.Quota.Allowed
+}
+
+// Engine resolves rules from the configuration and checks them against a limiter.
+// Every adapter (net/http, gin, echo, gRPC) shares it so they enforce the same rules.
+type Engine struct {
+	limiter  limiter.Checker
+	cfg      *config.Config
+	quota    *quota.Tracker
+	adjuster LimitAdjuster
+
+	base    ruleSet
+	tenants map[string]*ruleSet
+	hosts   map[string]string // lowercased host -> tenant name
+}
+
+// ruleSet holds the rules of one tenant, or the top-level rules.
+type ruleSet struct {
+	tenant       string
+	limit        int64
+	blockSeconds int64
+	global       int64
+	overrides    map[string]config.TokenOverride
+	routes       []config.RouteRule
+	response     *config.ResponseTemplate
+}
+
+// LimitAdjuster changes the per-identifier limit of a resolved rule at runtime,
+// e.g. from the health of the backend.
+type LimitAdjuster interface {
+	AdjustLimit(rule string, limitPerSecond int64) int64
+}
+
+// Option customizes an Engine.
+type Option func(*Engine)
+
+// WithQuota enforces long-period quotas for requests that carry a token.
+func WithQuota(t *quota.Tracker) Option {
+	return func(e *Engine) { e.quota = t }
+}
+
+// WithLimitAdjuster passes every resolved limit through a, as adaptive limiting does.
+func WithLimitAdjuster(a LimitAdjuster) Option {
+	return func(e *Engine) { e.adjuster = a }
+}
+
+func NewEngine(l limiter.Checker, cfg *config.Config, opts ...Option) *Engine {
+	e := &Engine{limiter: l, cfg: cfg, tenants: map[string]*ruleSet{}, hosts: map[string]string{}}
+	e.base = ruleSet{
+		limit:        cfg.DefaultLimitPerSec,
+		blockSeconds: cfg.DefaultBlockSeconds,
+		global:       cfg.GlobalLimitPerSec,
+		overrides:    cfg.TokenOverrides,
+		routes:       cfg.Routes,
+		response:     cfg.DefaultResponse,
+	}
+	for _, t := range cfg.Tenants {
+		rs := &ruleSet{
+			tenant:       t.Name,
+			limit:        orDefault(t.LimitPerSecond, cfg.DefaultLimitPerSec),
+			blockSeconds: orDefault(t.BlockSeconds, cfg.DefaultBlockSeconds),
+			global:       orDefault(t.GlobalLimitPerSecond, cfg.GlobalLimitPerSec),
+			overrides:    t.TokenOverrides,
+			routes:       t.Routes,
+			response:     t.DefaultResponse,
+		}
+		if rs.response == nil {
+			rs.response = cfg.DefaultResponse
+		}
+		e.tenants[t.Name] = rs
+		for _, h := range t.Hosts {
+			e.hosts[strings.ToLower(h)] = t.Name
+		}
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+func orDefault(v, def int64) int64 {
+	if v == 0 {
+		return def
+	}
+	return v
+}
+
+// Config returns the configuration the engine was built with.
+func (e *Engine) Config() *config.Config {
+	return e.cfg
+}
+
+// Evaluate resolves the rule for req and consumes its cost from the limiter and,
+// for requests allowed there, from the token's long-period quota.
+func (e *Engine) Evaluate(ctx context.Context, req Request) (Decision, error) {
+	rule := e.Resolve(req)
+	now := time.Now()
+	res, err := e.limiter.Check(limiter.WithRule(ctx, rule.Key()), rule.Identifier, rule.LimitPerSecond, rule.Cost, rule.BlockFor, now, rule.Aggregates...)
+	if err != nil {
+		return Decision{}, err
+	}
+	d := Decision{Rule: rule, Allowed: res.Allowed, RetryAfter: res.RetryAfter, LimitedBy: res.Aggregate, charged: res, token: req.Token, at: now}
+	if !d.Allowed || e.quota == nil || req.SkipQuota || req.Token == "" || e.cfg.Mode == config.ModeIP {
+		return d, nil
+	}
+	q, err := e.quota.Consume(ctx, rule.Tenant, req.Token, rule.Cost, now)
+	if err != nil {
+		return Decision{}, err
+	}
+	d.Quota = &q
+	if !q.Allowed {
+		d.Allowed = false
+		d.RetryAfter = q.ResetAt.Sub(now)
+	}
+	return d, nil
+}
+
+// Refund gives back what Evaluate charged for an allowed decision, for callers that
+// deny the request anyway because another check failed. Denied decisions charged
+// nothing and are ignored.
+func (e *Engine) Refund(ctx context.Context, d Decision) error {
+	if !d.Allowed {
+		return nil
+	}
+	if r, ok := e.limiter.(limiter.Refunder); ok {
+		if err := r.Refund(ctx, d.charged, d.Cost); err != nil {
+			return err
+		}
+	}
+	if d.Quota != nil {
+		return e.quota.Refund(ctx, d.Tenant, d.token, d.Cost, d.at)
+	}
+	return nil
+}
+
+// FromHTTP extracts a Request from an HTTP request: token header, client IP, path,
+// tenant and the cost declared in the context or in the trusted cost header.
+func (e *Engine) FromHTTP(r *http.Request) Request {
+	req := Request{
+		Token:  strings.TrimSpace(r.Header.Get(e.cfg.TokenHeader)),
+		IP:     ClientIP(r),
+		Path:   r.URL.Path,
+		Tenant: e.TenantFor(r.Header.Get(e.cfg.TenantHeader), r.Host),
+	}
+	if cost, ok := limiter.CostFromContext(r.Context()); ok {
+		req.Cost = cost
+	} else if e.cfg.CostHeader != "" {
+		if v := strings.TrimSpace(r.Header.Get(e.cfg.CostHeader)); v != "" {
+			if cost, err := strconv.ParseInt(v, 10, 64); err == nil && cost > 0 {
+				req.DeclaredCost = cost
+			}
+		}
+	}
+	return req
+}
+
+// TenantFor returns the tenant named by the tenant header value, when the header
+// is enabled, or the tenant that owns host; empty means the top-level rules.
+func (e *Engine) TenantFor(header, host string) string {
+	if e.cfg.TenantHeader != "" {
+		if name := strings.TrimSpace(header); name != "" {
+			if _, ok := e.tenants[name]; ok {
+				return name
+			}
+		}
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return e.hosts[strings.ToLower(host)]
+}
+
+// Resolve maps a request to its rule without touching the limiter.
+func (e *Engine) Resolve(req Request) Rule {
+	rs := &e.base
+	if t, ok := e.tenants[req.Tenant]; ok {
+		rs = t
+	}
+	identifier, limit, blockSeconds := e.resolveLimit(rs, req.Token, req.IP)
+	if req.Token == "" && req.IP == "" && req.Key != "" {
+		identifier = safeIdentifier("key:" + req.Key)
+	}
+	scope := ""
+	if rs.tenant != "" {
+		scope = "tenant:" + rs.tenant + ":"
+	}
+	if rs.tenant == "" && req.Scope != "" {
+		identifier = "scope:" + safeIdentifier(req.Scope) + ":" + identifier
+	}
+	rule := Rule{
+		Name:           DefaultRuleName,
+		Tenant:         rs.tenant,
+		Identifier:     scope + identifier,
+		LimitPerSecond: limit,
+		BlockFor:       time.Duration(blockSeconds) * time.Second,
+		Cost:           1,
+		Response:       rs.response,
+	}
+	if route := matchRoute(rs.routes, req.Path); route != nil {
+		rule.Name = route.Name
+		if rule.Name == "" {
+			rule.Name = route.Path
+		}
+		if route.Cost > 0 {
+			rule.Cost = route.Cost
+		}
+		if route.Response != nil {
+			rule.Response = route.Response
+		}
+		if route.AggregateLimitPerSecond > 0 {
+			rule.Aggregates = append(rule.Aggregates, limiter.Aggregate{Key: scope + "route:" + safeIdentifier(rule.Name), LimitPerSecond: route.AggregateLimitPerSecond})
+		}
+	}
+	if rs.global > 0 {
+		rule.Aggregates = append(rule.Aggregates, limiter.Aggregate{Key: scope + GlobalAggregateKey, LimitPerSecond: rs.global})
+	}
+	if req.Cost > 0 {
+		rule.Cost = req.Cost
+	} else if req.DeclaredCost > rule.Cost {
+		rule.Cost = req.DeclaredCost
+	}
+	if e.adjuster != nil && rule.LimitPerSecond > 0 {
+		rule.LimitPerSecond = e.adjuster.AdjustLimit(rule.Key(), rule.LimitPerSecond)
+	}
+	return rule
+}
+
+func (e *Engine) resolveLimit(rs *ruleSet, token, ip string) (identifier string, limit int64, blockSeconds int64) {
+	// Auto mode: token overrides IP if present and configured; token mode: require token; ip mode: ignore token
+	switch e.cfg.Mode {
+	case config.ModeToken:
+		identifier = safeIdentifier("token:" + token)
+		if ov, ok := rs.overrides[token]; ok {
+			return identifier, ov.LimitPerSecond, ov.BlockForSeconds
+		}
+		return identifier, rs.limit, rs.blockSeconds
+	case config.ModeIP:
+		identifier = safeIdentifier("ip:" + ip)
+		return identifier, rs.limit, rs.blockSeconds
+	default: // auto
+		if token != "" {
+			identifier = safeIdentifier("token:" + token)
+			if ov, ok := rs.overrides[token]; ok {
+				return identifier, ov.LimitPerSecond, ov.BlockForSeconds
+			}
+			// Token present but no override: use defaults, token takes precedence over IP
+			return identifier, rs.limit, rs.blockSeconds
+		}
+		identifier = safeIdentifier("ip:" + ip)
+		return identifier, rs.limit, rs.blockSeconds
+	}
+}
+
+// matchRoute returns the longest matching route rule, or nil when none matches.
+func matchRoute(routes []config.RouteRule, path string) *config.RouteRule {
+	var (
+		match   *config.RouteRule
+		longest = -1
+	)
+	for i := range routes {
+		rt := &routes[i]
+		if MatchPath(rt.Path, path) && len(rt.Path) > longest {
+			match, longest = rt, len(rt.Path)
+		}
+	}
+	return match
+}
+
+// MatchPath reports whether path equals pattern or lives under it ("/export" matches "/export/csv" but not "/exports").
+func MatchPath(pattern, path string) bool {
+	if pattern == "/" || pattern == path {
+		return true
+	}
+	pattern = strings.TrimSuffix(pattern, "/")
+	return strings.HasPrefix(path, pattern+"/")
+}
+
+// ClientIP returns the first X-Forwarded-For entry or the remote address host.
+func ClientIP(r *http.Request) string {
+	// Try X-Forwarded-For first, then RemoteAddr
+	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+		// first value is client
+		parts := strings.Split(xff, ",")
+		if len(parts) > 0 {
+			ip := strings.TrimSpace(parts[0])
+			if ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err == nil && host != "" {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+func safeIdentifier(s string) string {
+	// prevent spaces and illegal characters in redis keys
+	s = strings.ReplaceAll(s, " ", "_")
+	s = strings.ReplaceAll(s, "\n", "_")
+	s = strings.ReplaceAll(s, "\r", "_")
+	return s
+}
@@ -0,0 +1,176 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"rate-limiter/pkg/config"
+	"rate-limiter/pkg/limiter"
+	"rate-limiter/pkg/quota"
+	redispkg "rate-limiter/pkg/storage/redis"
+
+	miniredis "github.com/alicebob/miniredis/v2"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+type allowAll struct{}
+
+func (allowAll) Check(context.Context, string, int64, int64, time.Duration, time.Time, ...limiter.Aggregate) (limiter.Result, error) {
+	return limiter.Result{Allowed: true}, nil
+}
+
+func tenantConfig() *config.Config {
+	return &config.Config{
+		Mode:                config.ModeAuto,
+		DefaultLimitPerSec:  10,
+		DefaultBlockSeconds: 60,
+		GlobalLimitPerSec:   1000,
+		TokenHeader:         "API_KEY",
+		TenantHeader:        "X-Tenant",
+		TokenOverrides:      map[string]config.TokenOverride{"abc": {LimitPerSecond: 100, BlockForSeconds: 1}},
+		Routes:              []config.RouteRule{{Name: "export", Path: "/export", Cost: 50}},
+		Tenants: []config.Tenant{
+			{
+				Name:           "cep",
+				Hosts:          []string{"cep.example.com"},
+				LimitPerSecond: 5,
+				TokenOverrides: map[string]config.TokenOverride{"abc": {LimitPerSecond: 7, BlockForSeconds: 2}},
+				Routes:         []config.RouteRule{{Name: "search", Path: "/search", Cost: 2, AggregateLimitPerSecond: 300}},
+			},
+			{Name: "weather", Hosts: []string{"weather.example.com"}},
+		},
+	}
+}
+
+func TestEngine_TenantFor(t *testing.T) {
+	e := NewEngine(nil, tenantConfig())
+	cases := []struct {
+		header, host, want string
+	}{
+		{host: "cep.example.com", want: "cep"},
+		{host: "CEP.example.com:8080", want: "cep"},
+		{header: "weather", host: "cep.example.com", want: "weather"},
+		{header: "unknown", host: "weather.example.com", want: "weather"},
+		{host: "other.example.com", want: ""},
+	}
+	for _, tc := range cases {
+		if got := e.TenantFor(tc.header, tc.host); got != tc.want {
+			t.Fatalf("TenantFor(%q, %q) = %q, want %q", tc.header, tc.host, got, tc.want)
+		}
+	}
+
+	cfg := tenantConfig()
+	cfg.TenantHeader = ""
+	if got := NewEngine(nil, cfg).TenantFor("weather", "cep.example.com"); got != "cep" {
+		t.Fatalf("expected the header to be ignored when disabled, got %q", got)
+	}
+}
+
+func TestEngine_ResolveIsolatesTenants(t *testing.T) {
+	e := NewEngine(nil, tenantConfig())
+
+	top := e.Resolve(Request{IP: "1.2.3.4", Path: "/export"})
+	if top.Identifier != "ip:1.2.3.4" || top.Tenant != "" || top.Cost != 50 || top.LimitPerSecond != 10 {
+		t.Fatalf("unexpected top-level rule %+v", top)
+	}
+
+	cep := e.Resolve(Request{IP: "1.2.3.4", Path: "/export", Tenant: "cep"})
+	if cep.Identifier != "tenant:cep:ip:1.2.3.4" || cep.Name != DefaultRuleName || cep.Cost != 1 || cep.LimitPerSecond != 5 || cep.BlockFor.Seconds() != 60 {
+		t.Fatalf("expected tenant rules without top-level routes, got %+v", cep)
+	}
+
+	search := e.Resolve(Request{Token: "abc", Path: "/search", Tenant: "cep"})
+	if search.Key() != "cep/search" || search.Identifier != "tenant:cep:token:abc" || search.LimitPerSecond != 7 || search.Cost != 2 {
+		t.Fatalf("unexpected tenant route rule %+v", search)
+	}
+	if len(search.Aggregates) != 2 || search.Aggregates[0].Key != "tenant:cep:route:search" || search.Aggregates[1].Key != "tenant:cep:global" {
+		t.Fatalf("expected tenant-scoped aggregates, got %+v", search.Aggregates)
+	}
+
+	weather := e.Resolve(Request{Token: "abc", Tenant: "weather"})
+	if weather.LimitPerSecond != 10 || weather.Identifier != "tenant:weather:token:abc" {
+		t.Fatalf("expected inherited limits without top-level overrides, got %+v", weather)
+	}
+}
+
+func TestEngine_FromHTTPSelectsTenantByHost(t *testing.T) {
+	e := NewEngine(nil, tenantConfig())
+	r := httptest.NewRequest("GET", "http://weather.example.com/forecast", nil)
+	if got := e.FromHTTP(r).Tenant; got != "weather" {
+		t.Fatalf("expected weather, got %q", got)
+	}
+	r.Header.Set("X-Tenant", "cep")
+	if got := e.FromHTTP(r).Tenant; got != "cep" {
+		t.Fatalf("expected the tenant header to win, got %q", got)
+	}
+}
+
+func TestEngine_CostHeaderOnlyRaisesRouteCost(t *testing.T) {
+	cfg := tenantConfig()
+	cfg.CostHeader = "X-RateLimit-Cost"
+	e := NewEngine(nil, cfg)
+	cases := []struct {
+		path, header string
+		want         int64
+	}{
+		{"/export", "", 50},
+		{"/export", "2", 50},
+		{"/export", "80", 80},
+		{"/other", "3", 3},
+		{"/other", "nope", 1},
+	}
+	for _, tc := range cases {
+		r := httptest.NewRequest("GET", tc.path, nil)
+		if tc.header != "" {
+			r.Header.Set("X-RateLimit-Cost", tc.header)
+		}
+		if got := e.Resolve(e.FromHTTP(r)).Cost; got != tc.want {
+			t.Fatalf("%s with cost %q: expected %d, got %d", tc.path, tc.header, tc.want, got)
+		}
+	}
+
+	// Code in front of the middleware is trusted and may lower the cost.
+	r := httptest.NewRequest("GET", "/export", nil)
+	r = r.WithContext(limiter.WithCost(r.Context(), 1))
+	if got := e.Resolve(e.FromHTTP(r)).Cost; got != 1 {
+		t.Fatalf("expected the context cost to win, got %d", got)
+	}
+}
+
+func TestEngine_EvaluateChargesQuotaPerTenant(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis: %v", err)
+	}
+	t.Cleanup(mr.Close)
+	tr, err := quota.NewTracker(redispkg.New(goredis.NewClient(&goredis.Options{Addr: mr.Addr()})),
+		config.QuotaConfig{Period: "day", Timezone: "UTC", DefaultLimit: 1})
+	if err != nil {
+		t.Fatalf("tracker: %v", err)
+	}
+	e := NewEngine(allowAll{}, tenantConfig(), WithQuota(tr))
+	ctx := context.Background()
+
+	cases := []struct {
+		name    string
+		req     Request
+		allowed bool
+	}{
+		{"first call spends the quota", Request{Token: "abc"}, true},
+		{"quota exhausted", Request{Token: "abc"}, false},
+		{"rate check only", Request{Token: "abc", SkipQuota: true}, true},
+		{"other tenant", Request{Token: "abc", Tenant: "cep"}, true},
+		{"other tenant exhausted", Request{Token: "abc", Tenant: "cep"}, false},
+	}
+	for _, tc := range cases {
+		d, err := e.Evaluate(ctx, tc.req)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if d.Allowed != tc.allowed {
+			t.Fatalf("%s: allowed = %v, want %v", tc.name, d.Allowed, tc.allowed)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package ratelimit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	htmltemplate "html/template"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"rate-limiter/pkg/config"
+)
+
+// DeniedMessage is the message returned to clients that exceeded their limit.
+const DeniedMessage = "you have reached the maximum number of requests or actions allowed within a certain time frame"
+
+// QuotaExceededMessage is the message returned to tokens that used up their long-period quota.
+const QuotaExceededMessage = "you have used up the request quota of your API token for the current period"
+
+const (
+	LangEnglish    = "en"
+	LangPortuguese = "pt-BR"
+)
+
+const (
+	contentJSON    = "application/json"
+	contentProblem = "application/problem+json"
+	contentText    = "text/plain"
+	contentHTML    = "text/html"
+)
+
+type messages struct {
+	title   string
+	denied  string
+	quota   string
+	retryIn string // formatted with the number of seconds
+}
+
+var catalog = map[string]messages{
+	LangEnglish: {
+		title:   "Too Many Requests",
+		denied:  DeniedMessage,
+		quota:   QuotaExceededMessage,
+		retryIn: "Try again in %d seconds.",
+	},
+	LangPortuguese: {
+		title:   "Muitas requisições",
+		denied:  "você atingiu o número máximo de requisições ou ações permitidas dentro de um determinado intervalo de tempo",
+		quota:   "você esgotou a cota de requisições do seu token de API para o período atual",
+		retryIn: "Tente novamente em %d segundos.",
+	},
+}
+
+// Message returns the denial message for d in the best language for an Accept-Language value.
+func Message(d Decision, acceptLanguage string) string {
+	return catalog[NegotiateLanguage(acceptLanguage)].message(d)
+}
+
+func (m messages) message(d Decision) string {
+	if d.QuotaExceeded() {
+		return m.quota
+	}
+	return m.denied
+}
+
+// TemplateData is what response templates can reference.
+type TemplateData struct {
+	Message           string
+	RetryAfterSeconds int
+	LimitPerSecond    int64
+	Rule              string
+	Lang              string
+	// QuotaExceeded is true when the long-period quota, not the rate, denied the request.
+	QuotaExceeded bool
+}
+
+// WriteDenied writes the denial response for d. A rule response template wins;
+// otherwise the body is negotiated between JSON, problem+json, plain text and
+// HTML from Accept, in English or Portuguese from Accept-Language.
+func WriteDenied(w http.ResponseWriter, r *http.Request, d Decision) {
+	lang := NegotiateLanguage(r.Header.Get("Accept-Language"))
+	msgs := catalog[lang]
+	message := msgs.message(d)
+	retry := 0
+	if d.RetryAfter > 0 {
+		w.Header().Set("Retry-After", FormatRetryAfter(d.RetryAfter))
+		retry, _ = strconv.Atoi(FormatRetryAfter(d.RetryAfter))
+	}
+	status := http.StatusTooManyRequests
+	tmpl := d.Response
+	if tmpl != nil && tmpl.Status != 0 {
+		status = tmpl.Status
+	}
+	w.Header().Set("Content-Language", lang)
+	w.Header().Add("Vary", "Accept, Accept-Language")
+
+	if tmpl != nil && tmpl.Body != "" {
+		data := TemplateData{Message: message, RetryAfterSeconds: retry, LimitPerSecond: d.LimitPerSecond, Rule: d.Name, Lang: lang, QuotaExceeded: d.QuotaExceeded()}
+		var buf bytes.Buffer
+		if err := renderTemplate(&buf, tmpl, data); err == nil {
+			contentType := tmpl.ContentType
+			if contentType == "" {
+				contentType = contentJSON
+			}
+			w.Header().Set("Content-Type", contentType)
+			w.WriteHeader(status)
+			_, _ = w.Write(buf.Bytes())
+			return
+		}
+		// A template that fails at render time falls back to the built-in body.
+	}
+
+	retryText := ""
+	if retry > 0 {
+		retryText = fmt.Sprintf(msgs.retryIn, retry)
+	}
+	var quotaInfo *quotaBody
+	if d.QuotaExceeded() {
+		quotaInfo = &quotaBody{Limit: d.Quota.Limit, Used: d.Quota.Used, ResetsAt: d.Quota.ResetAt.Format(time.RFC3339)}
+	}
+	switch negotiateContentType(r.Header.Get("Accept")) {
+	case contentProblem:
+		w.Header().Set("Content-Type", contentProblem)
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(problem{
+			Type:              "about:blank",
+			Title:             msgs.title,
+			Status:            status,
+			Detail:            strings.TrimSpace(message + ". " + retryText),
+			RetryAfterSeconds: retry,
+			Quota:             quotaInfo,
+		})
+	case contentText:
+		w.Header().Set("Content-Type", contentText+"; charset=utf-8")
+		w.WriteHeader(status)
+		_, _ = io.WriteString(w, strings.TrimSpace(message+"\n"+retryText)+"\n")
+	case contentHTML:
+		w.Header().Set("Content-Type", contentHTML+"; charset=utf-8")
+		w.WriteHeader(status)
+		_, _ = fmt.Fprintf(w, "<!DOCTYPE html>\n<html lang=%q><head><meta charset=\"utf-8\"><title>%s</title></head><body><h1>%s</h1><p>%s</p><p>%s</p></body></html>\n",
+			lang, html.EscapeString(msgs.title), html.EscapeString(msgs.title), html.EscapeString(message), html.EscapeString(retryText))
+	default:
+		w.Header().Set("Content-Type", contentJSON)
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(deniedBody{Message: message, RetryAfterSeconds: retry, Quota: quotaInfo})
+	}
+}
+
+type deniedBody struct {
+	Message           string     `json:"message"`
+	RetryAfterSeconds int        `json:"retry_after_seconds,omitempty"`
+	Quota             *quotaBody `json:"quota,omitempty"`
+}
+
+type quotaBody struct {
+	Limit    int64  `json:"limit"`
+	Used     int64  `json:"used"`
+	ResetsAt string `json:"resets_at"`
+}
+
+// problem is an RFC 7807 problem details object.
+type problem struct {
+	Type              string     `json:"type"`
+	Title             string     `json:"title"`
+	Status            int        `json:"status"`
+	Detail            string     `json:"detail"`
+	RetryAfterSeconds int        `json:"retry_after_seconds,omitempty"`
+	Quota             *quotaBody `json:"quota,omitempty"`
+}
+
+// FormatRetryAfter renders d as whole seconds for the Retry-After header, never below 1.
+func FormatRetryAfter(d time.Duration) string {
+	// Round up to seconds
+	secs := int(d.Round(time.Second) / time.Second)
+	if secs < 1 {
+		secs = 1
+	}
+	return strconv.Itoa(secs)
+}
+
+// NegotiateLanguage picks pt-BR or en from an Accept-Language value; English is the default.
+func NegotiateLanguage(accept string) string {
+	for _, tag := range parseQuality(accept) {
+		primary, _, _ := strings.Cut(strings.ToLower(tag), "-")
+		switch primary {
+		case "pt":
+			return LangPortuguese
+		case "en", "*":
+			return LangEnglish
+		}
+	}
+	return LangEnglish
+}
+
+// negotiateContentType picks the supported media type with the highest quality; JSON is the default.
+func negotiateContentType(accept string) string {
+	for _, mt := range parseQuality(accept) {
+		switch strings.ToLower(mt) {
+		case contentJSON, "application/*", "*/*":
+			return contentJSON
+		case contentProblem:
+			return contentProblem
+		case contentText:
+			return contentText
+		case contentHTML, "application/xhtml+xml":
+			return contentHTML
+		case "text/*":
+			return contentText
+		}
+	}
+	return contentJSON
+}
+
+// parseQuality returns the values of an Accept-style header ordered by q, dropping q=0.
+func parseQuality(header string) []string {
+	type item struct {
+		value string
+		q     float64
+	}
+	var items []item
+	for _, part := range strings.Split(header, ",") {
+		value, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		q := 1.0
+		for _, p := range strings.Split(params, ";") {
+			k, v, ok := strings.Cut(strings.TrimSpace(p), "=")
+			if ok && strings.TrimSpace(k) == "q" {
+				if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+					q = f
+				}
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+		items = append(items, item{value: value, q: q})
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].q > items[j].q })
+	out := make([]string, len(items))
+	for i, it := range items {
+		out[i] = it.value
+	}
+	return out
+}
+
+type executor interface {
+	Execute(w io.Writer, data any) error
+}
+
+var templates sync.Map // *config.ResponseTemplate -> executor
+
+func renderTemplate(w io.Writer, t *config.ResponseTemplate, data TemplateData) error {
+	cached, ok := templates.Load(t)
+	if !ok {
+		var (
+			ex  executor
+			err error
+		)
+		if strings.Contains(t.ContentType, "html") {
+			ex, err = htmltemplate.New("body").Funcs(config.TemplateFuncs).Parse(t.Body)
+		} else {
+			ex, err = template.New("body").Funcs(config.TemplateFuncs).Parse(t.Body)
+		}
+		if err != nil {
+			return err
+		}
+		cached, _ = templates.LoadOrStore(t, ex)
+	}
+	return cached.(executor).Execute(w, data)
+}
@@ -0,0 +1,137 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"rate-limiter/pkg/config"
+	"rate-limiter/pkg/quota"
+)
+
+func deny(t *testing.T, d Decision, headers map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rr := httptest.NewRecorder()
+	WriteDenied(rr, req, d)
+	return rr
+}
+
+func TestWriteDenied_DefaultsToJSONInEnglish(t *testing.T) {
+	rr := deny(t, Decision{RetryAfter: 10 * time.Second}, nil)
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+	var body deniedBody
+	if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if body.Message != DeniedMessage || body.RetryAfterSeconds != 10 {
+		t.Fatalf("unexpected body: %+v", body)
+	}
+}
+
+func TestWriteDenied_NegotiatesContentTypeAndLanguage(t *testing.T) {
+	cases := []struct {
+		name        string
+		accept      string
+		language    string
+		contentType string
+		contains    string
+	}{
+		{name: "problem", accept: "application/problem+json", contentType: "application/problem+json", contains: `"title":"Too Many Requests"`},
+		{name: "text pt-BR", accept: "text/plain", language: "pt-BR,pt;q=0.9", contentType: "text/plain; charset=utf-8", contains: "Tente novamente em 5 segundos."},
+		{name: "html by quality", accept: "application/json;q=0.5, text/html", contentType: "text/html; charset=utf-8", contains: "<h1>Too Many Requests</h1>"},
+		{name: "english preferred", accept: "*/*", language: "en-US, pt;q=0.5", contentType: "application/json", contains: "maximum number of requests"},
+		{name: "unsupported falls back", accept: "image/png", language: "fr", contentType: "application/json", contains: "maximum number of requests"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rr := deny(t, Decision{RetryAfter: 5 * time.Second}, map[string]string{"Accept": tc.accept, "Accept-Language": tc.language})
+			if ct := rr.Header().Get("Content-Type"); ct != tc.contentType {
+				t.Fatalf("expected content type %q, got %q", tc.contentType, ct)
+			}
+			if !strings.Contains(rr.Body.String(), tc.contains) {
+				t.Fatalf("expected body to contain %q, got %q", tc.contains, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestWriteDenied_RuleTemplate(t *testing.T) {
+	d := Decision{
+		Rule: Rule{
+			Name:           "export",
+			LimitPerSecond: 3,
+			Response: &config.ResponseTemplate{
+				Status:      503,
+				ContentType: "application/json",
+				Body:        `{"rule":"{{.Rule}}","retry":{{.RetryAfterSeconds}},"lang":"{{.Lang}}"}`,
+			},
+		},
+		RetryAfter: 7 * time.Second,
+	}
+	rr := deny(t, d, map[string]string{"Accept-Language": "pt"})
+	if rr.Code != 503 {
+		t.Fatalf("expected template status 503, got %d", rr.Code)
+	}
+	if got := rr.Body.String(); got != `{"rule":"export","retry":7,"lang":"pt-BR"}` {
+		t.Fatalf("unexpected body %q", got)
+	}
+	if rr.Header().Get("Retry-After") != "7" {
+		t.Fatalf("expected Retry-After header")
+	}
+}
+
+func TestWriteDenied_JSONTemplateFuncEscapes(t *testing.T) {
+	d := Decision{Rule: Rule{Response: &config.ResponseTemplate{
+		ContentType: "application/json",
+		Body:        `{"message":{{json .Message}},"rule":{{json .Rule}}}`,
+	}}}
+	d.Rule.Name = `say "hi"`
+	rr := deny(t, d, nil)
+	var body map[string]string
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("body is not valid JSON: %v (%q)", err, rr.Body.String())
+	}
+	if body["rule"] != `say "hi"` || body["message"] == "" {
+		t.Fatalf("unexpected body %v", body)
+	}
+}
+
+func TestWriteDenied_StatusOnlyTemplateKeepsNegotiation(t *testing.T) {
+	d := Decision{Rule: Rule{Response: &config.ResponseTemplate{Status: 503}}}
+	rr := deny(t, d, map[string]string{"Accept": "text/plain"})
+	if rr.Code != 503 || !strings.HasPrefix(rr.Header().Get("Content-Type"), "text/plain") {
+		t.Fatalf("unexpected response %d %q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+}
+
+func TestWriteDenied_QuotaExceededHasDistinctMessage(t *testing.T) {
+	resetAt := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	d := Decision{Quota: &quota.Result{Allowed: false, Limit: 1000, Used: 1000, ResetAt: resetAt}, RetryAfter: time.Hour}
+	rr := deny(t, d, nil)
+
+	var body deniedBody
+	if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if body.Message != QuotaExceededMessage {
+		t.Fatalf("expected quota message, got %q", body.Message)
+	}
+	if body.Quota == nil || body.Quota.Limit != 1000 || body.Quota.ResetsAt != "2024-06-01T00:00:00Z" {
+		t.Fatalf("unexpected quota info: %+v", body.Quota)
+	}
+	if rr.Header().Get("Retry-After") != "3600" {
+		t.Fatalf("expected Retry-After until reset, got %q", rr.Header().Get("Retry-After"))
+	}
+}
@@ -0,0 +1,82 @@
+// Package memory is an in-process CounterStore for tests, simulations and
+// single-instance deployments; state is not shared between processes.
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type entry struct {
+	value     int64
+	expiresAt time.Time
+}
+
+type Store struct {
+	mu       sync.Mutex
+	now      func() time.Time
+	counters map[string]entry
+	blocks   map[string]time.Time
+}
+
+// New builds a store; now drives expiration and defaults to time.Now, which lets
+// simulations replay requests on a virtual clock.
+func New(now func() time.Time) *Store {
+	if now == nil {
+		now = time.Now
+	}
+	return &Store{now: now, counters: map[string]entry{}, blocks: map[string]time.Time{}}
+}
+
+func (s *Store) Incr(_ context.Context, key string, amount int64, window time.Duration) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	e, ok := s.counters[key]
+	if !ok || !now.Before(e.expiresAt) {
+		e = entry{expiresAt: now.Add(window)}
+	}
+	e.value += amount
+	s.counters[key] = e
+	s.sweep(now)
+	return e.value, nil
+}
+
+func (s *Store) SetBlock(_ context.Context, id string, blockFor time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.blocks[id] = s.now().Add(blockFor)
+	return nil
+}
+
+func (s *Store) IsBlocked(_ context.Context, id string) (bool, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	until, ok := s.blocks[id]
+	if !ok {
+		return false, 0, nil
+	}
+	ttl := until.Sub(s.now())
+	if ttl <= 0 {
+		delete(s.blocks, id)
+		return false, 0, nil
+	}
+	return true, ttl, nil
+}
+
+func (s *Store) Ping(context.Context) error {
+	return nil
+}
+
+// sweep drops expired counters once the map grows, so long runs stay bounded.
+func (s *Store) sweep(now time.Time) {
+	if len(s.counters) < 4096 {
+		return
+	}
+	for k, e := range s.counters {
+		if !now.Before(e.expiresAt) {
+			delete(s.counters, k)
+		}
+	}
+}
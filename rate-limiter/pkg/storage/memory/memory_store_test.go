@@ -0,0 +1,34 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestStore_ExpiresOnClock(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1000, 0)
+	s := New(func() time.Time { return now })
+
+	if n, _ := s.Incr(ctx, "k", 2, time.Second); n != 2 {
+		t.Fatalf("expected 2, got %d", n)
+	}
+	if n, _ := s.Incr(ctx, "k", 1, time.Second); n != 3 {
+		t.Fatalf("expected 3, got %d", n)
+	}
+	_ = s.SetBlock(ctx, "id", 5*time.Second)
+
+	now = now.Add(time.Second)
+	if n, _ := s.Incr(ctx, "k", 1, time.Second); n != 1 {
+		t.Fatalf("expected counter to restart after its window, got %d", n)
+	}
+	if blocked, ttl, _ := s.IsBlocked(ctx, "id"); !blocked || ttl != 4*time.Second {
+		t.Fatalf("expected block with 4s left, got %v %s", blocked, ttl)
+	}
+
+	now = now.Add(4 * time.Second)
+	if blocked, _, _ := s.IsBlocked(ctx, "id"); blocked {
+		t.Fatalf("expected block to expire")
+	}
+}
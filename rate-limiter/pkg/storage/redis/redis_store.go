@@ -0,0 +1,233 @@
+package redis
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+type Store struct {
+	client    *goredis.Client
+	namespace string
+}
+
+// Option customizes a Store.
+type Option func(*Store)
+
+// WithNamespace prefixes every key with namespace + ":", so several deployments
+// can share one Redis without colliding.
+func WithNamespace(namespace string) Option {
+	return func(s *Store) { s.namespace = namespace }
+}
+
+func New(client *goredis.Client, opts ...Option) *Store {
+	s := &Store{client: client}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Key returns the Redis key for key in the store namespace.
+func (s *Store) Key(key string) string {
+	if s.namespace == "" {
+		return key
+	}
+	return s.namespace + ":" + key
+}
+
+func (s *Store) Incr(ctx context.Context, key string, amount int64, window time.Duration) (int64, error) {
+	// INCRBY and set TTL when new (value == amount)
+	key = s.Key(key)
+	val, err := s.client.IncrBy(ctx, key, amount).Result()
+	if err != nil {
+		return 0, err
+	}
+	if val == amount {
+		_ = s.client.Expire(ctx, key, window).Err()
+	}
+	return val, nil
+}
+
+func (s *Store) SetBlock(ctx context.Context, id string, blockFor time.Duration) error {
+	key := s.blockKey(id)
+	return s.client.Set(ctx, key, "1", blockFor).Err()
+}
+
+func (s *Store) IsBlocked(ctx context.Context, id string) (bool, time.Duration, error) {
+	key := s.blockKey(id)
+	ttl, err := s.client.TTL(ctx, key).Result()
+	if err != nil {
+		return false, 0, err
+	}
+	if ttl > 0 {
+		return true, ttl, nil
+	}
+	// When key missing, TTL returns -2; when no expire, -1
+	return false, 0, nil
+}
+
+func (s *Store) AddUsage(ctx context.Context, token, period string, amount int64, ttl time.Duration) (int64, error) {
+	key := s.quotaKey(token, period)
+	pipe := s.client.TxPipeline()
+	incr := pipe.IncrBy(ctx, key, amount)
+	pipe.Expire(ctx, key, ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return incr.Val(), nil
+}
+
+func (s *Store) Usage(ctx context.Context, token string, periods []string) ([]int64, error) {
+	if len(periods) == 0 {
+		return nil, nil
+	}
+	keys := make([]string, len(periods))
+	for i, p := range periods {
+		keys[i] = s.quotaKey(token, p)
+	}
+	vals, err := s.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]int64, len(vals))
+	for i, v := range vals {
+		str, ok := v.(string)
+		if !ok {
+			continue
+		}
+		n, err := strconv.ParseInt(str, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = n
+	}
+	return out, nil
+}
+
+// incrWindowScript increments the window counter and sets its TTL when new,
+// atomically. The key is always passed in KEYS so it routes in Redis Cluster.
+var incrWindowScript = goredis.NewScript(`
+local n = redis.call('INCRBY', KEYS[1], ARGV[1])
+if n == tonumber(ARGV[1]) then
+  redis.call('EXPIRE', KEYS[1], ARGV[2])
+end
+return n
+`)
+
+// IncrWindow reads the server clock with TIME so every instance agrees on the
+// window, then increments it. The key depends on the time, so that takes two
+// round trips; a script building the key itself would not run on Redis Cluster.
+func (s *Store) IncrWindow(ctx context.Context, prefix string, amount int64, window time.Duration) (int64, int64, error) {
+	win := int64(window / time.Second)
+	if win < 1 {
+		win = 1
+	}
+	now, err := s.client.Time(ctx).Result()
+	if err != nil {
+		return 0, 0, err
+	}
+	start := now.Unix() - now.Unix()%win
+	key := s.Key(prefix + ":" + strconv.FormatInt(start, 10))
+	n, err := incrWindowScript.Run(ctx, s.client, []string{key}, amount, win).Int64()
+	if err != nil {
+		return 0, 0, err
+	}
+	return n, start, nil
+}
+
+func (s *Store) Now(ctx context.Context) (time.Time, error) {
+	return s.client.Time(ctx).Result()
+}
+
+// Ping checks connectivity with the Redis server.
+func (s *Store) Ping(ctx context.Context) error {
+	return s.client.Ping(ctx).Err()
+}
+
+// Blocked is an identifier currently blocked and the time left on its block.
+type Blocked struct {
+	Identifier string
+	TTL        time.Duration
+}
+
+// Counter is a counter key with its current value and time to live.
+type Counter struct {
+	Key   string
+	Value int64
+	TTL   time.Duration
+}
+
+// Blocked lists every blocked identifier. It walks the keyspace with SCAN, so it
+// is meant for operators, not for the request path.
+func (s *Store) Blocked(ctx context.Context) ([]Blocked, error) {
+	var out []Blocked
+	iter := s.client.Scan(ctx, 0, s.blockKey("*"), 100).Iterator()
+	for iter.Next(ctx) {
+		ttl, err := s.client.TTL(ctx, iter.Val()).Result()
+		if err != nil {
+			return nil, err
+		}
+		if ttl <= 0 {
+			continue
+		}
+		out = append(out, Blocked{Identifier: strings.TrimPrefix(iter.Val(), s.blockKey("")), TTL: ttl})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Identifier < out[j].Identifier })
+	return out, iter.Err()
+}
+
+// Unblock lifts the block of id, reporting whether it was blocked.
+func (s *Store) Unblock(ctx context.Context, id string) (bool, error) {
+	n, err := s.client.Del(ctx, s.blockKey(id)).Result()
+	return n > 0, err
+}
+
+// Counters returns the live counters whose keys start with prefix + ":", such as
+// the per-second windows of one identifier. Keys are reported without the namespace.
+func (s *Store) Counters(ctx context.Context, prefix string) ([]Counter, error) {
+	var out []Counter
+	iter := s.client.Scan(ctx, 0, escapeGlob(s.Key(prefix))+":*", 100).Iterator()
+	for iter.Next(ctx) {
+		key := iter.Val()
+		val, err := s.client.Get(ctx, key).Int64()
+		if err == goredis.Nil {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		ttl, err := s.client.TTL(ctx, key).Result()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, Counter{Key: strings.TrimPrefix(key, s.Key("")), Value: val, TTL: ttl})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out, iter.Err()
+}
+
+// escapeGlob quotes the characters SCAN MATCH treats as patterns.
+func escapeGlob(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func (s *Store) blockKey(id string) string {
+	return s.Key("rl:block:" + id)
+}
+
+func (s *Store) quotaKey(token, period string) string {
+	return s.Key("rl:quota:" + token + ":" + period)
+}
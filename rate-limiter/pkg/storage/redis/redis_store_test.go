@@ -0,0 +1,135 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	miniredis "github.com/alicebob/miniredis/v2"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis: %v", err)
+	}
+	t.Cleanup(mr.Close)
+	return New(goredis.NewClient(&goredis.Options{Addr: mr.Addr()}))
+}
+
+func TestStore_BlockedAndUnblock(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	_ = s.SetBlock(ctx, "token:b", time.Minute)
+	_ = s.SetBlock(ctx, "ip:10.0.0.1", 2*time.Minute)
+
+	blocked, err := s.Blocked(ctx)
+	if err != nil {
+		t.Fatalf("blocked: %v", err)
+	}
+	if len(blocked) != 2 || blocked[0].Identifier != "ip:10.0.0.1" || blocked[1].Identifier != "token:b" {
+		t.Fatalf("unexpected blocked list %+v", blocked)
+	}
+
+	ok, err := s.Unblock(ctx, "token:b")
+	if err != nil || !ok {
+		t.Fatalf("unblock: ok=%v err=%v", ok, err)
+	}
+	if ok, _ := s.Unblock(ctx, "token:b"); ok {
+		t.Fatalf("expected second unblock to report nothing removed")
+	}
+	if blocked, _, _ := s.IsBlocked(ctx, "token:b"); blocked {
+		t.Fatalf("expected token:b unblocked")
+	}
+}
+
+func TestStore_CountersMatchPrefixLiterally(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	_, _ = s.Incr(ctx, "rl:cnt:ip:1.2.3.4:100", 3, time.Second)
+	_, _ = s.Incr(ctx, "rl:cnt:ip:1.2.3.4:101", 1, time.Second)
+	_, _ = s.Incr(ctx, "rl:cnt:ip:1.2.3.40:100", 5, time.Second)
+	_, _ = s.Incr(ctx, "rl:cnt:ip:1*:100", 7, time.Second)
+
+	counters, err := s.Counters(ctx, "rl:cnt:ip:1.2.3.4")
+	if err != nil {
+		t.Fatalf("counters: %v", err)
+	}
+	if len(counters) != 2 || counters[0].Value != 3 || counters[1].Value != 1 {
+		t.Fatalf("unexpected counters %+v", counters)
+	}
+
+	counters, _ = s.Counters(ctx, "rl:cnt:ip:1*")
+	if len(counters) != 1 || counters[0].Value != 7 {
+		t.Fatalf("expected glob characters to match literally, got %+v", counters)
+	}
+}
+
+func TestStore_NamespaceIsolatesKeys(t *testing.T) {
+	ctx := context.Background()
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis: %v", err)
+	}
+	defer mr.Close()
+	rdb := goredis.NewClient(&goredis.Options{Addr: mr.Addr()})
+	a := New(rdb, WithNamespace("app-a"))
+	b := New(rdb, WithNamespace("app-b"))
+
+	_, _ = a.Incr(ctx, "rl:cnt:ip:1.2.3.4:100", 3, time.Second)
+	_ = a.SetBlock(ctx, "ip:1.2.3.4", time.Minute)
+	if n, _ := b.Incr(ctx, "rl:cnt:ip:1.2.3.4:100", 1, time.Second); n != 1 {
+		t.Fatalf("expected separate counters per namespace, got %d", n)
+	}
+	if blocked, _, _ := b.IsBlocked(ctx, "ip:1.2.3.4"); blocked {
+		t.Fatalf("expected the block to stay in namespace app-a")
+	}
+	if !mr.Exists("app-a:rl:block:ip:1.2.3.4") {
+		t.Fatalf("expected namespaced block key, got %v", mr.Keys())
+	}
+
+	blocked, _ := a.Blocked(ctx)
+	if len(blocked) != 1 || blocked[0].Identifier != "ip:1.2.3.4" {
+		t.Fatalf("unexpected blocked list %+v", blocked)
+	}
+	counters, _ := a.Counters(ctx, "rl:cnt:ip:1.2.3.4")
+	if len(counters) != 1 || counters[0].Key != "rl:cnt:ip:1.2.3.4:100" || counters[0].Value != 3 {
+		t.Fatalf("unexpected counters %+v", counters)
+	}
+}
+
+func TestStore_IncrWindowUsesServerClock(t *testing.T) {
+	ctx := context.Background()
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis: %v", err)
+	}
+	defer mr.Close()
+	mr.SetTime(time.Unix(1_700_000_007, 0))
+	s := New(goredis.NewClient(&goredis.Options{Addr: mr.Addr()}), WithNamespace("app"))
+
+	cases := []struct {
+		window time.Duration
+		amount int64
+		count  int64
+		start  int64
+	}{
+		{time.Second, 2, 2, 1_700_000_007},
+		{time.Second, 3, 5, 1_700_000_007},
+		{10 * time.Second, 1, 1, 1_700_000_000},
+	}
+	for _, tc := range cases {
+		count, start, err := s.IncrWindow(ctx, "rl:cnt:ip:1.2.3.4", tc.amount, tc.window)
+		if err != nil {
+			t.Fatalf("incr: %v", err)
+		}
+		if count != tc.count || start != tc.start {
+			t.Fatalf("window %s: got %d at %d, want %d at %d", tc.window, count, start, tc.count, tc.start)
+		}
+	}
+	if mr.TTL("app:rl:cnt:ip:1.2.3.4:1700000000") != 10*time.Second {
+		t.Fatalf("expected a namespaced window key with TTL, got %v", mr.Keys())
+	}
+}
@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// CounterStore abstracts persistence for rate limiting state.
+type CounterStore interface {
+	// Incr increases counter in the current window by amount; when first increment, it sets window TTL.
+	Incr(ctx context.Context, key string, amount int64, window time.Duration) (count int64, err error)
+
+	// SetBlock marks an identifier as blocked for a certain duration.
+	SetBlock(ctx context.Context, id string, blockFor time.Duration) error
+
+	// IsBlocked returns whether id is currently blocked and the remaining TTL.
+	IsBlocked(ctx context.Context, id string) (blocked bool, ttl time.Duration, err error)
+}
+
+// Pinger is implemented by stores that can report whether their backend is reachable.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// QuotaStore keeps long-period usage per token, one counter per calendar period.
+type QuotaStore interface {
+	// AddUsage adds amount to the token usage in period and keeps the counter for ttl.
+	AddUsage(ctx context.Context, token, period string, amount int64, ttl time.Duration) (used int64, err error)
+
+	// Usage returns the usage of token in each period, in the same order; missing periods are zero.
+	Usage(ctx context.Context, token string, periods []string) ([]int64, error)
+}
+
+// WindowStore is implemented by stores that can place increments in windows of their
+// own clock, so app instances with skewed clocks still share the same buckets.
+type WindowStore interface {
+	// IncrWindow adds amount to the counter "<prefix>:<window start epoch second>" of the
+	// window that contains the store time, returning the count and that window start.
+	IncrWindow(ctx context.Context, prefix string, amount int64, window time.Duration) (count int64, windowStart int64, err error)
+
+	// Now returns the store clock.
+	Now(ctx context.Context) (time.Time, error)
+}